@@ -71,6 +71,16 @@ func main() {
 		log.Fatalf("Error getting config: %v", err)
 	}
 
+	shutdownTracing, err := service.InitTracing(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Error initializing tracing: %v", err)
+	}
+	defer func() {
+		if sErr := shutdownTracing(context.Background()); sErr != nil {
+			log.Warnf("Error shutting down tracing: %v", sErr)
+		}
+	}()
+
 	kubeClient := utils.GetKubeClient()
 	conf := utils.GetConfig()
 	daprClient, err := scheme.NewForConfig(conf)
@@ -102,6 +112,7 @@ func main() {
 		KubeClient:              kubeClient,
 		ControlPlaneNamespace:   security.CurrentNamespace(),
 		ControlPlaneTrustDomain: cfg.ControlPlaneTrustDomain,
+		InjectorVersion:         buildinfo.Version(),
 	})
 	if err != nil {
 		log.Fatalf("Error creating injector: %v", err)
@@ -133,13 +144,7 @@ func main() {
 			)
 		},
 		func(ctx context.Context) error {
-			readyErr := inj.Ready(ctx)
-			if readyErr != nil {
-				return readyErr
-			}
-			healthzServer.Ready()
-			<-ctx.Done()
-			return nil
+			return inj.ReportReadiness(ctx, healthzServer)
 		},
 		func(ctx context.Context) error {
 			healhtzErr := healthzServer.Run(ctx, opts.HealthzPort)
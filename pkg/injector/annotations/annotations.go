@@ -25,11 +25,13 @@ const (
 	KeyAppSSL                           = "dapr.io/app-ssl" // Deprecated. Remove in a future Dapr version. Use "app-protocol" with "https" or "grpcs"
 	KeyAppID                            = "dapr.io/app-id"
 	KeyEnableProfiling                  = "dapr.io/enable-profiling"
+	KeyProfilePort                      = "dapr.io/profile-port"
 	KeyLogLevel                         = "dapr.io/log-level"
 	KeyAPITokenSecret                   = "dapr.io/api-token-secret" /* #nosec */
 	KeyAppTokenSecret                   = "dapr.io/app-token-secret" /* #nosec */
 	KeyLogAsJSON                        = "dapr.io/log-as-json"
 	KeyAppMaxConcurrency                = "dapr.io/app-max-concurrency"
+	KeyAppMaxConcurrencyPerCPU          = "dapr.io/app-max-concurrency-per-cpu"
 	KeyEnableMetrics                    = "dapr.io/enable-metrics"
 	KeyMetricsPort                      = "dapr.io/metrics-port"
 	KeyEnableDebug                      = "dapr.io/enable-debug"
@@ -39,6 +41,7 @@ const (
 	KeyCPULimit                         = "dapr.io/sidecar-cpu-limit"
 	KeyMemoryRequest                    = "dapr.io/sidecar-memory-request"
 	KeyMemoryLimit                      = "dapr.io/sidecar-memory-limit"
+	KeySidecarGOMAXPROCS                = "dapr.io/sidecar-gomaxprocs"
 	KeySidecarListenAddresses           = "dapr.io/sidecar-listen-addresses"
 	KeyLivenessProbeDelaySeconds        = "dapr.io/sidecar-liveness-probe-delay-seconds"
 	KeyLivenessProbeTimeoutSeconds      = "dapr.io/sidecar-liveness-probe-timeout-seconds"
@@ -63,10 +66,28 @@ const (
 	KeyAppHealthProbeInterval           = "dapr.io/app-health-probe-interval"
 	KeyAppHealthProbeTimeout            = "dapr.io/app-health-probe-timeout"
 	KeyAppHealthThreshold               = "dapr.io/app-health-threshold"
+	KeyAppHealthFailureThreshold        = "dapr.io/app-health-failure-threshold"
 	KeyPlacementHostAddresses           = "dapr.io/placement-host-address"
 	KeyPluggableComponents              = "dapr.io/pluggable-components"
 	KeyPluggableComponentsSocketsFolder = "dapr.io/pluggable-components-sockets-folder"
 	KeyPluggableComponentContainer      = "dapr.io/component-container"
 	KeyPluggableComponentsInjection     = "dapr.io/inject-pluggable-components"
 	KeyAppChannel                       = "dapr.io/app-channel-address"
+	KeyAppContainerName                 = "dapr.io/app-container-name"
+	KeySidecarExposePorts               = "dapr.io/sidecar-expose-ports"
+	KeySidecarRunAsUser                 = "dapr.io/sidecar-run-as-user"
+	KeySidecarRunAsGroup                = "dapr.io/sidecar-run-as-group"
+	KeySidecarFSGroup                   = "dapr.io/sidecar-fs-group"
+	KeyControlPlaneNamespace            = "dapr.io/control-plane-namespace"
+	KeySidecarSharedMemorySize          = "dapr.io/sidecar-shm-size"
+	KeyNativeSidecar                    = "dapr.io/native-sidecar"
+	KeyNativeSidecarStartupProbe        = "dapr.io/native-sidecar-startup-probe"
+	KeySidecarGuaranteedQoS             = "dapr.io/sidecar-guaranteed-qos"
+	KeyDisablePlacement                 = "dapr.io/disable-placement"
+	KeyEnableMTLS                       = "dapr.io/enable-mtls"
+	KeySidecarStartupCPUBoost           = "dapr.io/sidecar-startup-cpu-boost"
+	KeySentryAddress                    = "dapr.io/sentry-address"
+	KeyOperatorAddress                  = "dapr.io/operator-address"
+	KeyPodRuntimeClass                  = "dapr.io/pod-runtime-class"
+	KeySidecarDownwardAPIEnv            = "dapr.io/sidecar-downward-api-env"
 )
@@ -27,13 +27,22 @@ const (
 	SidecarInternalGRPCPortName    = "dapr-internal"
 	SidecarMetricsPortName         = "dapr-metrics"
 	SidecarDebugPortName           = "dapr-debug"
+	SidecarExposedPortNamePrefix   = "dapr-ext-" // Prefix for the name of additional ports exposed on the sidecar container.
 	SidecarHealthzPath             = "healthz"
 	SidecarInjectedLabel           = "dapr.io/sidecar-injected"
 	SidecarAppIDLabel              = "dapr.io/app-id"
 	SidecarMetricsEnabledLabel     = "dapr.io/metrics-enabled"
+	SidecarHealthzOutboundPath     = "healthz/outbound"                          // Healthz endpoint that reports the sidecar as healthy once its APIs are ready, without depending on the app.
+	AppIDCollisionAnnotation       = "dapr.io/app-id-collision"                  // Annotation added to pods whose app ID is also used by another workload in the same namespace.
+	SidecarAddedCPUAnnotation      = "dapr.io/sidecar-added-cpu"                 // Annotation with the total CPU requested by the containers added by the injector.
+	SidecarAddedMemoryAnnotation   = "dapr.io/sidecar-added-memory"              // Annotation with the total memory requested by the containers added by the injector.
+	InjectorVersionAnnotation      = "dapr.io/injector-version"                  // Annotation with the version of the injector that added the sidecar.
+	SidecarImageUsedAnnotation     = "dapr.io/sidecar-image-used"                // Annotation with the sidecar image that was injected, after all overrides and digest pinning are applied.
+	SidecarSteadyCPUAnnotation     = "dapr.io/sidecar-cpu-request-after-startup" // Annotation with the CPU request the sidecar is scaled down to once it has started, when a startup CPU boost is set.
 	APIVersionV1                   = "v1.0"
 	UnixDomainSocketVolume         = "dapr-unix-domain-socket"              // Name of the UNIX domain socket volume.
 	UnixDomainSocketDaprdPath      = "/var/run/dapr-sockets"                // Path in the daprd container where UNIX domain sockets are mounted.
+	SidecarGOMAXPROCSEnvVar        = "GOMAXPROCS"                           // Name of the variable that sets GOMAXPROCS in the daprd container.
 	UserContainerAppProtocolName   = "APP_PROTOCOL"                         // Name of the variable exposed to the app containing the app protocol.
 	UserContainerDaprHTTPPortName  = "DAPR_HTTP_PORT"                       // Name of the variable exposed to the app containing the Dapr HTTP port.
 	UserContainerDaprGRPCPortName  = "DAPR_GRPC_PORT"                       // Name of the variable exposed to the app containing the Dapr gRPC port.
@@ -43,6 +52,25 @@ const (
 	ComponentsUDSMountPathEnvVar   = "DAPR_COMPONENT_SOCKETS_FOLDER"
 	ComponentsUDSDefaultFolder     = "/tmp/dapr-components-sockets"
 
+	TrustAnchorsKey = "ca.crt" // Name of the key in the ConfigMap or Secret with the custom trust anchors.
+
+	SharedMemoryVolumeName      = "dapr-shm" // Name of the in-memory volume mounted as shared memory in the daprd container.
+	SharedMemoryVolumeMountPath = "/dev/shm" // Mount path in the daprd container for the shared memory volume.
+
+	TmpVolumeName      = "dapr-tmp" // Name of the volume mounted as a writable /tmp in the daprd container when its root filesystem is read-only.
+	TmpVolumeMountPath = "/tmp"     // Mount path in the daprd container for the writable /tmp volume.
+
+	AdditionalCAVolumeName      = "dapr-additional-ca"                     // Name of the projected volume with additional CA certificates for daprd.
+	AdditionalCAVolumeMountPath = "/var/run/secrets/dapr.io/additional-ca" // Mount path in the daprd container for the volume with additional CA certificates.
+	AdditionalCAFileName        = "ca.crt"                                 // Name of the key in each Secret with an additional CA certificate.
+	SSLCertDirEnvVar            = "SSL_CERT_DIR"                           // Name of the variable with the directories that contain trusted CA certificates.
+
+	CostAllocationLabelsEnvVar = "DAPR_COST_ALLOCATION_LABELS" // Name of the variable with the pod labels used for cost allocation, as comma-separated "key=value" pairs.
+
+	PodNamespaceEnvVar = "POD_NAMESPACE" // Name of the variable with the namespace of the pod, from the downward API.
+	NodeNameEnvVar     = "NODE_NAME"     // Name of the variable with the name of the node the pod runs on, from the downward API.
+	PodIPEnvVar        = "POD_IP"        // Name of the variable with the IP of the pod, from the downward API.
+
 	ModeKubernetes = modes.KubernetesMode // KubernetesMode is a Kubernetes Dapr mode.
 	ModeStandalone = modes.StandaloneMode // StandaloneMode is a Standalone Dapr mode.
 )
@@ -0,0 +1,106 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package namespacednamematcher
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NamespaceValueMatcher maps namespaces to values, where namespaces can be exact names or prefixes.
+type NamespaceValueMatcher struct {
+	equal    map[string]string
+	prefixed map[string]string
+	// Prefixes sorted from the longest to the shortest, so the most specific prefix matches first
+	sortedPrefixes []string
+}
+
+// CreateNamespaceValueMatcherFromString creates a NamespaceValueMatcher from the CSV provided by the user of namespace=value pairs.
+// Namespaces can end with a wildcard to indicate prefix matching, such as "dev-*=small". A single "*" matches all namespaces.
+func CreateNamespaceValueMatcherFromString(s string) (*NamespaceValueMatcher, error) {
+	matcher := &NamespaceValueMatcher{
+		equal:    map[string]string{},
+		prefixed: map[string]string{},
+	}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		nsValue := strings.Split(pair, "=")
+		if len(nsValue) != 2 {
+			return nil, errors.New("namespace and value pair not following expected format 'namespace=value'")
+		}
+		ns := strings.TrimSpace(nsValue[0])
+		value := strings.TrimSpace(nsValue[1])
+		if ns == "" || value == "" {
+			return nil, errors.New("namespace and value cannot be empty")
+		}
+
+		nsPrefix, prefixFound, err := getPrefix(ns)
+		if err != nil {
+			return nil, err
+		}
+		target, key := matcher.equal, ns
+		if prefixFound {
+			target, key = matcher.prefixed, nsPrefix
+		}
+		if existing, ok := target[key]; ok && existing != value {
+			return nil, fmt.Errorf("namespace '%s' is mapped to multiple values", ns)
+		}
+		target[key] = value
+	}
+
+	matcher.sortedPrefixes = make([]string, 0, len(matcher.prefixed))
+	for p := range matcher.prefixed {
+		matcher.sortedPrefixes = append(matcher.sortedPrefixes, p)
+	}
+	sort.Slice(matcher.sortedPrefixes, func(i, j int) bool {
+		a, b := matcher.sortedPrefixes[i], matcher.sortedPrefixes[j]
+		return len(a) > len(b) || (len(a) == len(b) && a < b)
+	})
+	return matcher, nil
+}
+
+// Get returns the value for the namespace.
+// Exact matches take precedence over prefixes, and longer prefixes take precedence over shorter ones.
+func (m *NamespaceValueMatcher) Get(namespace string) (string, bool) {
+	if v, ok := m.equal[namespace]; ok {
+		return v, true
+	}
+	for _, p := range m.sortedPrefixes {
+		if strings.HasPrefix(namespace, p) {
+			return m.prefixed[p], true
+		}
+	}
+	return "", false
+}
+
+// Values returns the list of all values in the matcher, sorted and without duplicates.
+func (m *NamespaceValueMatcher) Values() []string {
+	found := make(map[string]struct{}, len(m.equal)+len(m.prefixed))
+	for _, v := range m.equal {
+		found[v] = struct{}{}
+	}
+	for _, v := range m.prefixed {
+		found[v] = struct{}{}
+	}
+	res := make([]string, 0, len(found))
+	for v := range found {
+		res = append(res, v)
+	}
+	sort.Strings(res)
+	return res
+}
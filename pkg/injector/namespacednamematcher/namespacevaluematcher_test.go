@@ -0,0 +1,85 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package namespacednamematcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateNamespaceValueMatcherFromString(t *testing.T) {
+	tests := []struct {
+		name      string
+		s         string
+		wantError bool
+	}{
+		{name: "empty", s: ""},
+		{name: "exact", s: "ns=a"},
+		{name: "prefix", s: "ns*=a"},
+		{name: "all namespaces", s: "*=a"},
+		{name: "multiple", s: "ns=a, dev-*=b,*=c"},
+		{name: "same value repeated", s: "ns=a,ns=a"},
+		{name: "missing value", s: "ns", wantError: true},
+		{name: "empty value", s: "ns=", wantError: true},
+		{name: "empty namespace", s: "=a", wantError: true},
+		{name: "too many separators", s: "ns=a=b", wantError: true},
+		{name: "wildcard not at the end", s: "n*s=a", wantError: true},
+		{name: "conflicting values", s: "ns=a,ns=b", wantError: true},
+		{name: "conflicting prefix values", s: "ns*=a,ns*=b", wantError: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := CreateNamespaceValueMatcherFromString(tc.s)
+			if tc.wantError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNamespaceValueMatcherGet(t *testing.T) {
+	m, err := CreateNamespaceValueMatcherFromString("prod=large, dev-*=small, dev-team-*=medium, dev-team-a=tiny, *=default")
+	require.NoError(t, err)
+
+	tests := map[string]string{
+		"prod":       "large",
+		"dev-1":      "small",
+		"dev-team-b": "medium",
+		"dev-team-a": "tiny",
+		"other":      "default",
+		"production": "default",
+	}
+	for ns, expect := range tests {
+		t.Run(ns, func(t *testing.T) {
+			v, ok := m.Get(ns)
+			assert.True(t, ok)
+			assert.Equal(t, expect, v)
+		})
+	}
+
+	t.Run("no match", func(t *testing.T) {
+		m, err := CreateNamespaceValueMatcherFromString("prod=large,dev-*=small")
+		require.NoError(t, err)
+		_, ok := m.Get("staging")
+		assert.False(t, ok)
+	})
+
+	t.Run("values", func(t *testing.T) {
+		assert.Equal(t, []string{"default", "large", "medium", "small", "tiny"}, m.Values())
+	})
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"errors"
+)
+
+// DenialReason is a machine-parseable code for the reason why a pod was denied by the injector.
+type DenialReason string
+
+// Reasons for denying a pod.
+const (
+	DenialReasonInternal               DenialReason = "Internal"
+	DenialReasonInvalidAnnotation      DenialReason = "InvalidAnnotation"
+	DenialReasonInvalidAppID           DenialReason = "InvalidAppID"
+	DenialReasonMissingAppID           DenialReason = "MissingAppID"
+	DenialReasonInvalidAppPort         DenialReason = "InvalidAppPort"
+	DenialReasonInvalidAppContainer    DenialReason = "InvalidAppContainer"
+	DenialReasonMissingSidecarImage    DenialReason = "MissingSidecarImage"
+	DenialReasonInsecureAppProtocol    DenialReason = "InsecureAppProtocol"
+	DenialReasonResourceNotFound       DenialReason = "ResourceNotFound"
+	DenialReasonPortConflict           DenialReason = "PortConflict"
+	DenialReasonMissingResourceLimits  DenialReason = "MissingResourceLimits"
+	DenialReasonIDOutOfRange           DenialReason = "IDOutOfRange"
+	DenialReasonComponentsNotAvailable DenialReason = "ComponentsNotAvailable"
+	DenialReasonSidecarQuotaExceeded   DenialReason = "SidecarQuotaExceeded"
+	DenialReasonMalformedRequest       DenialReason = "MalformedRequest"
+	DenialReasonUnexpectedQoSClass     DenialReason = "UnexpectedQoSClass"
+)
+
+// DenialError is an error that causes a pod to be denied, with the reason code.
+type DenialError struct {
+	Reason DenialReason
+	Err    error
+}
+
+// NewDenialError returns an error with the given reason.
+// If the error already has a reason, it's preserved, so the most specific reason is reported.
+func NewDenialError(reason DenialReason, err error) error {
+	if err == nil {
+		return nil
+	}
+	var de *DenialError
+	if errors.As(err, &de) {
+		return err
+	}
+	return &DenialError{
+		Reason: reason,
+		Err:    err,
+	}
+}
+
+// Error implements the error interface.
+func (e *DenialError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *DenialError) Unwrap() error {
+	return e.Err
+}
+
+// GetDenialReason returns the reason for the error, or DenialReasonInternal if the error has no reason.
+func GetDenialReason(err error) DenialReason {
+	var de *DenialError
+	if errors.As(err, &de) {
+		return de.Reason
+	}
+	return DenialReasonInternal
+}
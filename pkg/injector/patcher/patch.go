@@ -16,6 +16,7 @@ package patcher
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	jsonpatch "github.com/evanphx/json-patch/v5"
 	corev1 "k8s.io/api/core/v1"
@@ -26,12 +27,32 @@ import (
 const (
 	// Path for patching containers.
 	PatchPathContainers = "/spec/containers"
+	// Path for patching init containers.
+	PatchPathInitContainers = "/spec/initContainers"
 	// Path for patching volumes.
 	PatchPathVolumes = "/spec/volumes"
 	// Path for patching labels.
 	PatchPathLabels = "/metadata/labels"
+	// Path for patching annotations.
+	PatchPathAnnotations = "/metadata/annotations"
+	// Path for patching the pod's security context.
+	PatchPathSecurityContext = "/spec/securityContext"
+	// Path for patching the pod's host aliases.
+	PatchPathHostAliases = "/spec/hostAliases"
+	// Path for patching the pod's topology spread constraints.
+	PatchPathTopologySpreadConstraints = "/spec/topologySpreadConstraints"
+	// Path for patching the pod's runtime class.
+	PatchPathRuntimeClassName = "/spec/runtimeClassName"
 )
 
+// jsonPointerEscaper escapes the characters that have a special meaning in a JSON pointer, as per RFC 6901.
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// EscapeJSONPointer escapes a value so it can be used as a token in the path of a patch operation, such as the key of a label or annotation.
+func EscapeJSONPointer(val string) string {
+	return jsonPointerEscaper.Replace(val)
+}
+
 // NewPatchOperation returns a jsonpatch.Operation with the provided properties.
 // This patch represents a discrete change to be applied to a Kubernetes resource.
 func NewPatchOperation(op string, path string, value any) jsonpatch.Operation {
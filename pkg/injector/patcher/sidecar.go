@@ -33,17 +33,18 @@ type GetInjectedComponentContainersFn = func(appID string, namespace string) ([]
 // Note: make sure that the annotations defined here are in-sync with the constants in the pkg/injector/annotations package.
 type SidecarConfig struct {
 	GetInjectedComponentContainers GetInjectedComponentContainersFn
+	TrustAnchorsSourceExists       TrustAnchorsSourceExistsFn
 
 	Mode                        injectorConsts.DaprMode `default:"kubernetes"`
 	Namespace                   string
 	CertChain                   string
 	CertKey                     string
 	MTLSEnabled                 bool
+	ForcedMTLSEnabled           *bool
 	Identity                    string
 	IgnoreEntrypointTolerations []corev1.Toleration
 	ImagePullPolicy             corev1.PullPolicy
-	OperatorAddress             string
-	SentryAddress               string
+	TerminationMessagePolicy    corev1.TerminationMessagePolicy `default:"FallbackToLogsOnError"`
 	RunAsNonRoot                bool
 	ReadOnlyRootFilesystem      bool
 	SidecarDropALLCapabilities  bool
@@ -51,10 +52,38 @@ type SidecarConfig struct {
 	CurrentTrustAnchors         []byte
 	ControlPlaneNamespace       string
 	ControlPlaneTrustDomain     string
-	SidecarHTTPPort             int32 `default:"3500"`
-	SidecarAPIGRPCPort          int32 `default:"50001"`
-	SidecarInternalGRPCPort     int32 `default:"50002"`
-	SidecarPublicPort           int32 `default:"3501"`
+	SidecarHTTPPort             int32                     `default:"3500"`
+	SidecarAPIGRPCPort          int32                     `default:"50001"`
+	SidecarInternalGRPCPort     int32                     `default:"50002"`
+	SidecarPublicPort           int32                     `default:"3501"`
+	OnAmbiguousAppContainer     AmbiguousAppContainerMode `default:"all"`
+	OnWindowsPod                WindowsPodMode            `default:"inject"`
+	InjectPortEnvIntoApp        bool                      `default:"true"`
+	SkipGenerateNamePatterns    []string
+	OnlyInjectForOwnerKinds     []string
+	RuntimeClassAdjustments     map[string]corev1.SecurityContext
+	MinAppContainers            int
+	MaxAppContainers            int
+	SidecarAllowedIDRange       IDRange
+	MemoryLimitFractionOfApp    float64
+	AnnotateAddedResources      bool
+	SetPodSeccompRuntimeDefault bool
+	RequireLimits               bool
+	RequiredPodQoS              corev1.PodQOSClass
+	AutoRemapPorts              bool
+	RequireAppID                bool
+	ForbidInsecureAppProtocol   bool
+	DefaultPodAnnotations       map[string]string
+	ForcedAnnotations           map[string]string
+	ImageDigests                map[string]string
+	AdditionalCASecrets         []string
+	CostAllocationLabels        []string
+	SidecarHostAliases          []corev1.HostAlias
+	SidecarTopologySpread       []corev1.TopologySpreadConstraint
+	TrustAnchorsSource          *TrustAnchorsSource
+	EnableNativeSidecars        bool
+	AllowControlPlaneAddresses  bool
+	InjectorVersion             string
 
 	Enabled                             bool   `annotation:"dapr.io/enabled"`
 	AppPort                             int32  `annotation:"dapr.io/app-port"`
@@ -63,11 +92,14 @@ type SidecarConfig struct {
 	AppSSL                              bool   `annotation:"dapr.io/app-ssl"` // TODO: Deprecated in Dapr 1.11; remove in a future Dapr version
 	AppID                               string `annotation:"dapr.io/app-id"`
 	EnableProfiling                     bool   `annotation:"dapr.io/enable-profiling"`
+	SidecarProfilePort                  int32  `annotation:"dapr.io/profile-port" default:"7777"`
 	LogLevel                            string `annotation:"dapr.io/log-level" default:"info"`
 	APITokenSecret                      string `annotation:"dapr.io/api-token-secret"`
 	AppTokenSecret                      string `annotation:"dapr.io/app-token-secret"`
 	LogAsJSON                           bool   `annotation:"dapr.io/log-as-json"`
 	AppMaxConcurrency                   *int   `annotation:"dapr.io/app-max-concurrency"`
+	AppMaxConcurrencyPerCPU             *int   `annotation:"dapr.io/app-max-concurrency-per-cpu"`
+	SidecarGOMAXPROCS                   *int   `annotation:"dapr.io/sidecar-gomaxprocs"`
 	EnableMetrics                       bool   `annotation:"dapr.io/enable-metrics" default:"true"`
 	SidecarMetricsPort                  int32  `annotation:"dapr.io/metrics-port" default:"9090"`
 	EnableDebug                         bool   `annotation:"dapr.io/enable-debug" default:"false"`
@@ -97,16 +129,34 @@ type SidecarConfig struct {
 	VolumeMountsRW                      string `annotation:"dapr.io/volume-mounts-rw"`
 	DisableBuiltinK8sSecretStore        bool   `annotation:"dapr.io/disable-builtin-k8s-secret-store"`
 	EnableAppHealthCheck                bool   `annotation:"dapr.io/enable-app-health-check"`
-	AppHealthCheckPath                  string `annotation:"dapr.io/app-health-check-path" default:"/healthz"`
+	AppHealthCheckPath                  string `annotation:"dapr.io/app-health-check-path"`
 	AppHealthProbeInterval              int32  `annotation:"dapr.io/app-health-probe-interval" default:"5"`  // In seconds
 	AppHealthProbeTimeout               int32  `annotation:"dapr.io/app-health-probe-timeout" default:"500"` // In milliseconds
 	AppHealthThreshold                  int32  `annotation:"dapr.io/app-health-threshold" default:"3"`
+	AppHealthFailureThreshold           *int32 `annotation:"dapr.io/app-health-failure-threshold"`
 	PlacementAddress                    string `annotation:"dapr.io/placement-host-address"`
 	PluggableComponents                 string `annotation:"dapr.io/pluggable-components"`
 	PluggableComponentsSocketsFolder    string `annotation:"dapr.io/pluggable-components-sockets-folder"`
 	ComponentContainer                  string `annotation:"dapr.io/component-container"`
 	InjectPluggableComponents           bool   `annotation:"dapr.io/inject-pluggable-components"`
 	AppChannelAddress                   string `annotation:"dapr.io/app-channel-address"`
+	AppContainerName                    string `annotation:"dapr.io/app-container-name"`
+	SidecarExposePorts                  string `annotation:"dapr.io/sidecar-expose-ports"`
+	SidecarRunAsUser                    *int64 `annotation:"dapr.io/sidecar-run-as-user"`
+	SidecarRunAsGroup                   *int64 `annotation:"dapr.io/sidecar-run-as-group"`
+	SidecarFSGroup                      *int64 `annotation:"dapr.io/sidecar-fs-group"`
+	ControlPlaneNamespaceOverride       string `annotation:"dapr.io/control-plane-namespace"`
+	SidecarSharedMemorySize             string `annotation:"dapr.io/sidecar-shm-size"`
+	NativeSidecar                       *bool  `annotation:"dapr.io/native-sidecar"`
+	NativeSidecarStartupProbe           bool   `annotation:"dapr.io/native-sidecar-startup-probe" default:"true"`
+	SidecarGuaranteedQoS                bool   `annotation:"dapr.io/sidecar-guaranteed-qos"`
+	DisablePlacement                    bool   `annotation:"dapr.io/disable-placement"`
+	EnableMTLS                          *bool  `annotation:"dapr.io/enable-mtls"`
+	SidecarStartupCPUBoost              string `annotation:"dapr.io/sidecar-startup-cpu-boost"`
+	SentryAddress                       string `annotation:"dapr.io/sentry-address"`
+	OperatorAddress                     string `annotation:"dapr.io/operator-address"`
+	PodRuntimeClass                     string `annotation:"dapr.io/pod-runtime-class"`
+	SidecarDownwardAPIEnv               bool   `annotation:"dapr.io/sidecar-downward-api-env"`
 
 	pod *corev1.Pod
 }
@@ -120,8 +170,16 @@ func NewSidecarConfig(pod *corev1.Pod) *SidecarConfig {
 	return c
 }
 
+// SetFromPodAnnotations updates the object with properties from the annotations set on the pod.
+// Default pod annotations are applied first, so the values set on the pod override them, and forced annotations are applied last, so they override the values set on the pod.
 func (c *SidecarConfig) SetFromPodAnnotations() {
+	if len(c.DefaultPodAnnotations) > 0 {
+		c.setFromAnnotations(c.DefaultPodAnnotations)
+	}
 	c.setFromAnnotations(c.pod.Annotations)
+	if len(c.ForcedAnnotations) > 0 {
+		c.setFromAnnotations(c.ForcedAnnotations)
+	}
 }
 
 func (c *SidecarConfig) setDefaultValues() {
@@ -0,0 +1,84 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+)
+
+// AmbiguousAppContainerMode controls what the injector does when a pod contains more than one app container and it can't tell which one is the app.
+type AmbiguousAppContainerMode string
+
+const (
+	// AmbiguousAppContainerAll applies the changes (env vars, UDS mounts) to all app containers. This is the default.
+	AmbiguousAppContainerAll AmbiguousAppContainerMode = "all"
+	// AmbiguousAppContainerFirst applies the changes to the first app container in the pod spec only.
+	AmbiguousAppContainerFirst AmbiguousAppContainerMode = "first"
+	// AmbiguousAppContainerDeny rejects pods whose app container can't be determined.
+	AmbiguousAppContainerDeny AmbiguousAppContainerMode = "deny"
+	// AmbiguousAppContainerAnnotationRequired rejects pods with multiple app containers unless the app container is named with an annotation.
+	AmbiguousAppContainerAnnotationRequired AmbiguousAppContainerMode = "annotation-required"
+)
+
+// ParseAmbiguousAppContainerMode parses a string into an AmbiguousAppContainerMode.
+// An empty string returns the default mode.
+func ParseAmbiguousAppContainerMode(val string) (AmbiguousAppContainerMode, error) {
+	switch m := AmbiguousAppContainerMode(val); m {
+	case "":
+		return AmbiguousAppContainerAll, nil
+	case AmbiguousAppContainerAll, AmbiguousAppContainerFirst, AmbiguousAppContainerDeny, AmbiguousAppContainerAnnotationRequired:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid value for ambiguous app container mode: '%s'", val)
+	}
+}
+
+// selectAppContainers returns the app containers that should receive the Dapr env vars and volume mounts.
+// If the pod has a single app container, or if the app container is named with the "dapr.io/app-container-name" annotation, there's no ambiguity.
+// Otherwise, the behavior depends on OnAmbiguousAppContainer.
+func (c *SidecarConfig) selectAppContainers(appContainers map[int]corev1.Container) (map[int]corev1.Container, error) {
+	if c.AppContainerName != "" {
+		for i, container := range appContainers {
+			if container.Name == c.AppContainerName {
+				return map[int]corev1.Container{i: container}, nil
+			}
+		}
+		return nil, fmt.Errorf("app container '%s' set in annotation %s was not found in the pod", c.AppContainerName, annotations.KeyAppContainerName)
+	}
+
+	if len(appContainers) <= 1 {
+		return appContainers, nil
+	}
+
+	switch c.OnAmbiguousAppContainer {
+	case AmbiguousAppContainerFirst:
+		idx := make([]int, 0, len(appContainers))
+		for i := range appContainers {
+			idx = append(idx, i)
+		}
+		sort.Ints(idx)
+		return map[int]corev1.Container{idx[0]: appContainers[idx[0]]}, nil
+	case AmbiguousAppContainerDeny:
+		return nil, fmt.Errorf("unable to determine the app container: pod has %d candidate containers", len(appContainers))
+	case AmbiguousAppContainerAnnotationRequired:
+		return nil, fmt.Errorf("pod has %d candidate app containers: annotation %s is required to select the app container", len(appContainers), annotations.KeyAppContainerName)
+	default:
+		return appContainers, nil
+	}
+}
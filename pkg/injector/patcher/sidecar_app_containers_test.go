@@ -0,0 +1,164 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+)
+
+func TestParseAmbiguousAppContainerMode(t *testing.T) {
+	testCases := []struct {
+		input  string
+		expect AmbiguousAppContainerMode
+		expErr bool
+	}{
+		{"", AmbiguousAppContainerAll, false},
+		{"all", AmbiguousAppContainerAll, false},
+		{"first", AmbiguousAppContainerFirst, false},
+		{"deny", AmbiguousAppContainerDeny, false},
+		{"annotation-required", AmbiguousAppContainerAnnotationRequired, false},
+		{"random", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			mode, err := ParseAmbiguousAppContainerMode(tc.input)
+			if tc.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, mode)
+		})
+	}
+}
+
+func TestAmbiguousAppContainer(t *testing.T) {
+	getPod := func(an map[string]string, containers ...string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myapp",
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		for _, name := range containers {
+			pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+				Name:  name,
+				Image: name + ":1.0",
+			})
+		}
+		return pod
+	}
+
+	// Returns the names of the containers that received the DAPR_HTTP_PORT env var
+	patchedContainers := func(t *testing.T, pod *corev1.Pod, mode AmbiguousAppContainerMode) ([]string, error) {
+		t.Helper()
+
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		c.OnAmbiguousAppContainer = mode
+		c.SetFromPodAnnotations()
+
+		patch, err := c.GetPatch()
+		if err != nil {
+			return nil, err
+		}
+		newPod, err := PatchPod(pod, patch)
+		require.NoError(t, err)
+
+		res := []string{}
+		for _, container := range newPod.Spec.Containers {
+			for _, env := range container.Env {
+				if env.Name == "DAPR_HTTP_PORT" {
+					res = append(res, container.Name)
+				}
+			}
+		}
+		return res, nil
+	}
+
+	t.Run("single app container is never ambiguous", func(t *testing.T) {
+		for _, mode := range []AmbiguousAppContainerMode{AmbiguousAppContainerAll, AmbiguousAppContainerFirst, AmbiguousAppContainerDeny, AmbiguousAppContainerAnnotationRequired} {
+			res, err := patchedContainers(t, getPod(nil, "app"), mode)
+			require.NoError(t, err, string(mode))
+			assert.Equal(t, []string{"app"}, res, string(mode))
+		}
+	})
+
+	t.Run("all mode patches every app container", func(t *testing.T) {
+		res, err := patchedContainers(t, getPod(nil, "app", "helper"), AmbiguousAppContainerAll)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"app", "helper"}, res)
+	})
+
+	t.Run("first mode patches the first app container", func(t *testing.T) {
+		res, err := patchedContainers(t, getPod(nil, "app", "helper", "other"), AmbiguousAppContainerFirst)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"app"}, res)
+	})
+
+	t.Run("first mode ignores pluggable component containers", func(t *testing.T) {
+		pod := getPod(map[string]string{
+			annotations.KeyPluggableComponents: "component",
+		}, "component", "app", "helper")
+		res, err := patchedContainers(t, pod, AmbiguousAppContainerFirst)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"app"}, res)
+	})
+
+	t.Run("deny mode rejects the pod", func(t *testing.T) {
+		_, err := patchedContainers(t, getPod(nil, "app", "helper"), AmbiguousAppContainerDeny)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unable to determine the app container")
+	})
+
+	t.Run("annotation-required mode rejects the pod without the annotation", func(t *testing.T) {
+		_, err := patchedContainers(t, getPod(nil, "app", "helper"), AmbiguousAppContainerAnnotationRequired)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), annotations.KeyAppContainerName)
+	})
+
+	t.Run("annotation selects the app container in every mode", func(t *testing.T) {
+		for _, mode := range []AmbiguousAppContainerMode{AmbiguousAppContainerAll, AmbiguousAppContainerFirst, AmbiguousAppContainerDeny, AmbiguousAppContainerAnnotationRequired} {
+			pod := getPod(map[string]string{
+				annotations.KeyAppContainerName: "helper",
+			}, "app", "helper")
+			res, err := patchedContainers(t, pod, mode)
+			require.NoError(t, err, string(mode))
+			assert.Equal(t, []string{"helper"}, res, string(mode))
+		}
+	})
+
+	t.Run("annotation referencing a missing container is rejected", func(t *testing.T) {
+		pod := getPod(map[string]string{
+			annotations.KeyAppContainerName: "notfound",
+		}, "app", "helper")
+		_, err := patchedContainers(t, pod, AmbiguousAppContainerAll)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "notfound")
+	})
+}
@@ -15,6 +15,7 @@ package patcher
 
 import (
 	"fmt"
+	"net"
 	"path"
 	"regexp"
 	"strconv"
@@ -25,12 +26,16 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/dapr/dapr/pkg/config/protocol"
+	"github.com/dapr/dapr/pkg/injector/annotations"
 	injectorConsts "github.com/dapr/dapr/pkg/injector/consts"
 	securityConsts "github.com/dapr/dapr/pkg/security/consts"
 	"github.com/dapr/dapr/utils"
 	"github.com/dapr/kit/ptr"
 )
 
+// Default path for the health checks of HTTP apps.
+const defaultAppHealthCheckPath = "/healthz"
+
 type getSidecarContainerOpts struct {
 	VolumeMounts                 []corev1.VolumeMount
 	ComponentsSocketsVolumeMount *corev1.VolumeMount
@@ -52,10 +57,33 @@ func (c *SidecarConfig) getSidecarContainer(opts getSidecarContainerOpts) (*core
 			ContainerPort: c.SidecarInternalGRPCPort,
 			Name:          injectorConsts.SidecarInternalGRPCPortName,
 		},
-		{
+	}
+
+	// The metrics port is exposed only if metrics are enabled
+	if c.EnableMetrics {
+		ports = append(ports, corev1.ContainerPort{
 			ContainerPort: c.SidecarMetricsPort,
 			Name:          injectorConsts.SidecarMetricsPortName,
-		},
+		})
+	}
+
+	// Additional ports exposed on the daprd container, for example for custom middleware listeners
+	exposedPorts, err := c.getExposedPorts()
+	if err != nil {
+		return nil, err
+	}
+	ports = append(ports, exposedPorts...)
+
+	// Validate the port for the profiling server, if profiling is enabled
+	err = c.validateProfilePort()
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the settings for the liveness probe
+	err = c.validateLivenessProbe()
+	if err != nil {
+		return nil, err
 	}
 
 	// Get the command (/daprd) and all CLI flags
@@ -80,11 +108,20 @@ func (c *SidecarConfig) getSidecarContainer(opts getSidecarContainerOpts) (*core
 		args = append(args, "--mode", "standalone")
 	}
 
+	// Addresses of the control plane services, which can be overridden independently in the annotations
 	if c.OperatorAddress != "" {
+		err := ValidateHostAddress(c.OperatorAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for annotation %s: %w", annotations.KeyOperatorAddress, err)
+		}
 		args = append(args, "--control-plane-address", c.OperatorAddress)
 	}
 
 	if c.SentryAddress != "" {
+		err := ValidateHostAddress(c.SentryAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for annotation %s: %w", annotations.KeySentryAddress, err)
+		}
 		args = append(args, "--sentry-address", c.SentryAddress)
 	}
 
@@ -97,6 +134,9 @@ func (c *SidecarConfig) getSidecarContainer(opts getSidecarContainerOpts) (*core
 			"--enable-metrics",
 			"--metrics-port", strconv.FormatInt(int64(c.SidecarMetricsPort), 10),
 		)
+	} else {
+		// Metrics are enabled by default in daprd, so they need to be disabled explicitly
+		args = append(args, "--enable-metrics=false")
 	}
 
 	if c.Config != "" {
@@ -109,6 +149,10 @@ func (c *SidecarConfig) getSidecarContainer(opts getSidecarContainerOpts) (*core
 
 	// Placement address could be empty if placement service is disabled
 	if c.PlacementAddress != "" {
+		err := ValidateHostAddresses(c.PlacementAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for annotation %s: %w", annotations.KeyPlacementHostAddresses, err)
+		}
 		args = append(args, "--placement-host-address", c.PlacementAddress)
 	}
 
@@ -124,12 +168,22 @@ func (c *SidecarConfig) getSidecarContainer(opts getSidecarContainerOpts) (*core
 	}
 
 	if c.EnableAppHealthCheck {
+		// The failure threshold annotation is an alias for the threshold annotation, and it takes precedence
+		failureThreshold := c.AppHealthThreshold
+		if c.AppHealthFailureThreshold != nil {
+			if *c.AppHealthFailureThreshold < 1 {
+				return nil, fmt.Errorf("invalid value for annotation %s: must be a positive number", annotations.KeyAppHealthFailureThreshold)
+			}
+			failureThreshold = *c.AppHealthFailureThreshold
+		}
+		args = append(args, "--enable-app-health-check")
+		if path := c.getAppHealthCheckPath(); path != "" {
+			args = append(args, "--app-health-check-path", path)
+		}
 		args = append(args,
-			"--enable-app-health-check",
-			"--app-health-check-path", c.AppHealthCheckPath,
 			"--app-health-probe-interval", strconv.FormatInt(int64(c.AppHealthProbeInterval), 10),
 			"--app-health-probe-timeout", strconv.FormatInt(int64(c.AppHealthProbeTimeout), 10),
-			"--app-health-threshold", strconv.FormatInt(int64(c.AppHealthThreshold), 10),
+			"--app-health-threshold", strconv.FormatInt(int64(failureThreshold), 10),
 		)
 	}
 
@@ -138,10 +192,13 @@ func (c *SidecarConfig) getSidecarContainer(opts getSidecarContainerOpts) (*core
 	}
 
 	if c.EnableProfiling {
-		args = append(args, "--enable-profiling")
+		args = append(args,
+			"--enable-profiling",
+			"--profile-port", strconv.FormatInt(int64(c.SidecarProfilePort), 10),
+		)
 	}
 
-	if c.MTLSEnabled {
+	if c.isMTLSEnabled() {
 		args = append(args, "--enable-mtls")
 	}
 
@@ -201,6 +258,16 @@ func (c *SidecarConfig) getSidecarContainer(opts getSidecarContainerOpts) (*core
 			Type: corev1.SeccompProfileType(c.SidecarSeccompProfileType),
 		}
 	}
+	err = c.validateSidecarIDs()
+	if err != nil {
+		return nil, NewDenialError(DenialReasonIDOutOfRange, err)
+	}
+	if c.SidecarRunAsUser != nil {
+		securityContext.RunAsUser = ptr.Of(*c.SidecarRunAsUser)
+	}
+	if c.SidecarRunAsGroup != nil {
+		securityContext.RunAsGroup = ptr.Of(*c.SidecarRunAsGroup)
+	}
 	if c.SidecarDropALLCapabilities {
 		securityContext.Capabilities = &corev1.Capabilities{
 			Drop: []corev1.Capability{"ALL"},
@@ -210,12 +277,13 @@ func (c *SidecarConfig) getSidecarContainer(opts getSidecarContainerOpts) (*core
 	// Create the container object
 	probeHTTPHandler := getProbeHTTPHandler(c.SidecarPublicPort, injectorConsts.APIVersionV1, injectorConsts.SidecarHealthzPath)
 	container := &corev1.Container{
-		Name:            injectorConsts.SidecarContainerName,
-		Image:           c.SidecarImage,
-		ImagePullPolicy: c.ImagePullPolicy,
-		SecurityContext: securityContext,
-		Ports:           ports,
-		Args:            append(cmd, args...),
+		Name:                     injectorConsts.SidecarContainerName,
+		Image:                    c.SidecarImage,
+		ImagePullPolicy:          c.ImagePullPolicy,
+		SecurityContext:          securityContext,
+		TerminationMessagePolicy: c.TerminationMessagePolicy,
+		Ports:                    ports,
+		Args:                     append(cmd, args...),
 		Env: []corev1.EnvVar{
 			{
 				Name:  "NAMESPACE",
@@ -229,10 +297,7 @@ func (c *SidecarConfig) getSidecarContainer(opts getSidecarContainerOpts) (*core
 					},
 				},
 			},
-			{
-				Name:  securityConsts.TrustAnchorsEnvVar,
-				Value: string(c.CurrentTrustAnchors),
-			},
+			c.getTrustAnchorsEnv(),
 			// TODO: @joshvanl: In v1.14, this two env vars should be moved to flags.
 			{
 				Name:  securityConsts.ControlPlaneNamespaceEnvVar,
@@ -258,6 +323,7 @@ func (c *SidecarConfig) getSidecarContainer(opts getSidecarContainerOpts) (*core
 			PeriodSeconds:       c.SidecarLivenessProbePeriodSeconds,
 			FailureThreshold:    c.SidecarLivenessProbeThreshold,
 		},
+		StartupProbe: c.getNativeSidecarStartupProbe(),
 	}
 
 	// TODO: @joshvanl: included for backwards compatibility with v1.11 daprd's
@@ -290,35 +356,6 @@ func (c *SidecarConfig) getSidecarContainer(opts getSidecarContainerOpts) (*core
 		container.Args = append(container.Args, args...)
 	}
 
-	// Set env vars if needed
-	containerEnvKeys, containerEnv := c.getEnv()
-	if len(containerEnv) > 0 {
-		container.Env = append(container.Env, containerEnv...)
-		container.Env = append(container.Env, corev1.EnvVar{
-			Name:  securityConsts.EnvKeysEnvVar,
-			Value: strings.Join(containerEnvKeys, " "),
-		})
-	}
-
-	// This is a special case that requires administrator privileges in Windows containers
-	// to install the certificates to the root store. If this environment variable is set,
-	// the container security context should be set to run as administrator.
-	for _, env := range container.Env {
-		if env.Name == "SSL_CERT_DIR" {
-			container.SecurityContext.WindowsOptions = &corev1.WindowsSecurityContextOptions{
-				RunAsUserName: ptr.Of("ContainerAdministrator"),
-			}
-
-			// We also need to set RunAsNonRoot and ReadOnlyRootFilesystem to false, which would impact Linux too.
-			// The injector has no way to know if the pod is going to be deployed on Windows or Linux, so we need to err on the side of most compatibility.
-			// On Linux, our containers run with a non-root user, so the net effect shouldn't change: daprd is running as non-root and has no permission to write on the root FS.
-			// However certain security scanner may complain about this.
-			container.SecurityContext.RunAsNonRoot = ptr.Of(false)
-			container.SecurityContext.ReadOnlyRootFilesystem = ptr.Of(false)
-			break
-		}
-	}
-
 	if opts.ComponentsSocketsVolumeMount != nil {
 		container.VolumeMounts = append(container.VolumeMounts, *opts.ComponentsSocketsVolumeMount)
 		container.Env = append(container.Env, corev1.EnvVar{
@@ -355,6 +392,76 @@ func (c *SidecarConfig) getSidecarContainer(opts getSidecarContainerOpts) (*core
 		})
 	}
 
+	// Trust the additional CA certificates, which are mounted in a volume, in addition to the system's
+	if len(c.AdditionalCASecrets) > 0 {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  injectorConsts.SSLCertDirEnvVar,
+			Value: injectorConsts.AdditionalCAVolumeMountPath,
+		})
+	}
+
+	// Copy the pod labels used for cost allocation, so they can be attached to the sidecar's telemetry
+	if costLabels := c.getCostAllocationLabels(); costLabels != "" {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  injectorConsts.CostAllocationLabelsEnvVar,
+			Value: costLabels,
+		})
+	}
+
+	// Expose fields of the pod from the downward API, which SDKs and observability tools can use
+	// The pod's name is always set, in POD_NAME
+	if c.SidecarDownwardAPIEnv {
+		container.Env = append(container.Env, getDownwardAPIEnv()...)
+	}
+
+	// Set env vars if needed
+	// Env vars managed by the injector take precedence over the ones set by the user
+	containerEnvKeys, containerEnv := c.getEnv()
+	containerEnvKeys, containerEnv = removeReservedEnv(container.Env, containerEnvKeys, containerEnv)
+	if len(containerEnv) > 0 {
+		container.Env = append(container.Env, containerEnv...)
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  securityConsts.EnvKeysEnvVar,
+			Value: strings.Join(containerEnvKeys, " "),
+		})
+	}
+
+	// This is a special case that requires administrator privileges in Windows containers
+	// to install the certificates to the root store. If this environment variable is set,
+	// the container security context should be set to run as administrator.
+	// The SSL_CERT_DIR set by the injector for the additional CA certificates only changes the security context of Windows pods.
+	userSSLCertDir := false
+	for _, env := range containerEnv {
+		if env.Name == injectorConsts.SSLCertDirEnvVar {
+			userSSLCertDir = true
+			break
+		}
+	}
+	if userSSLCertDir || (len(c.AdditionalCASecrets) > 0 && IsWindowsPod(c.pod)) {
+		container.SecurityContext.WindowsOptions = &corev1.WindowsSecurityContextOptions{
+			RunAsUserName: ptr.Of("ContainerAdministrator"),
+		}
+
+		// We also need to set RunAsNonRoot and ReadOnlyRootFilesystem to false, which would impact Linux too.
+		// The injector has no way to know if the pod is going to be deployed on Windows or Linux, so we need to err on the side of most compatibility.
+		// On Linux, our containers run with a non-root user, so the net effect shouldn't change: daprd is running as non-root and has no permission to write on the root FS.
+		// However certain security scanner may complain about this.
+		container.SecurityContext.RunAsNonRoot = ptr.Of(false)
+		container.SecurityContext.ReadOnlyRootFilesystem = ptr.Of(false)
+	}
+
+	// Remove the options that aren't supported on Windows if needed
+	if c.OnWindowsPod == WindowsPodWindows && IsWindowsPod(c.pod) {
+		adjustSecurityContextForWindows(container.SecurityContext)
+	}
+
+	// Apply adjustments for the pod's runtime class (e.g. gVisor or Kata) last, so they take precedence over the defaults
+	if runtimeClass := c.getRuntimeClassName(); runtimeClass != "" {
+		if adj, ok := c.RuntimeClassAdjustments[runtimeClass]; ok {
+			mergeSecurityContext(container.SecurityContext, &adj)
+		}
+	}
+
 	// Resources for the container
 	resources, err := c.getResourceRequirements()
 	if err != nil {
@@ -362,10 +469,158 @@ func (c *SidecarConfig) getSidecarContainer(opts getSidecarContainerOpts) (*core
 	} else if resources != nil {
 		container.Resources = *resources
 	}
+	if c.SidecarGuaranteedQoS {
+		err = setGuaranteedQoSResources(&container.Resources)
+		if err != nil {
+			return nil, NewDenialError(DenialReasonMissingResourceLimits, err)
+		}
+	}
+	if c.SidecarStartupCPUBoost != "" {
+		err = c.setStartupCPUBoost(&container.Resources)
+		if err != nil {
+			return nil, NewDenialError(DenialReasonInvalidAnnotation, err)
+		}
+	}
+	if c.RequireLimits && len(container.Resources.Limits) == 0 {
+		return nil, NewDenialError(DenialReasonMissingResourceLimits, fmt.Errorf("resource limits are required for the sidecar: set annotation %s and/or %s", annotations.KeyCPULimit, annotations.KeyMemoryLimit))
+	}
+
+	// Set GOMAXPROCS, unless it's already set in the env vars from the annotations
+	if !utils.Contains(containerEnvKeys, injectorConsts.SidecarGOMAXPROCSEnvVar) {
+		gomaxprocs, err := c.getGOMAXPROCS(container.Resources)
+		if err != nil {
+			return nil, err
+		}
+		if gomaxprocs > 0 {
+			container.Env = append(container.Env, corev1.EnvVar{
+				Name:  injectorConsts.SidecarGOMAXPROCSEnvVar,
+				Value: strconv.FormatInt(gomaxprocs, 10),
+			})
+		}
+	}
 
 	return container, nil
 }
 
+// getGOMAXPROCS returns the value for the GOMAXPROCS environment variable of the sidecar.
+// This is the value of the SidecarGOMAXPROCS annotation if set, otherwise it's computed from the CPU limit, rounded up to the next whole core.
+// Returns 0 if GOMAXPROCS should not be set.
+func (c *SidecarConfig) getGOMAXPROCS(resources corev1.ResourceRequirements) (int64, error) {
+	if c.SidecarGOMAXPROCS != nil {
+		if *c.SidecarGOMAXPROCS < 1 {
+			return 0, fmt.Errorf("invalid value for annotation %s: must be a positive number", annotations.KeySidecarGOMAXPROCS)
+		}
+		return int64(*c.SidecarGOMAXPROCS), nil
+	}
+
+	cpu, ok := resources.Limits[corev1.ResourceCPU]
+	if !ok || cpu.IsZero() {
+		return 0, nil
+	}
+	return (cpu.MilliValue() + 999) / 1000, nil
+}
+
+// setAppMaxConcurrencyFromCPU sets AppMaxConcurrency from the AppMaxConcurrencyPerCPU annotation, multiplied by the CPU requested by the app containers.
+// The result is rounded down, with a minimum of 1. If an app container doesn't request CPU, its CPU limit is used.
+func (c *SidecarConfig) setAppMaxConcurrencyFromCPU(appContainers map[int]corev1.Container) error {
+	if c.AppMaxConcurrencyPerCPU == nil {
+		return nil
+	}
+	if *c.AppMaxConcurrencyPerCPU < 1 {
+		return fmt.Errorf("invalid value for annotation %s: must be a positive number", annotations.KeyAppMaxConcurrencyPerCPU)
+	}
+	if c.AppMaxConcurrency != nil {
+		return fmt.Errorf("annotation %s cannot be set together with annotation %s", annotations.KeyAppMaxConcurrencyPerCPU, annotations.KeyAppMaxConcurrency)
+	}
+
+	var milliCPU int64
+	for _, container := range appContainers {
+		cpu, ok := container.Resources.Requests[corev1.ResourceCPU]
+		if !ok {
+			cpu = container.Resources.Limits[corev1.ResourceCPU]
+		}
+		milliCPU += cpu.MilliValue()
+	}
+	if milliCPU <= 0 {
+		return fmt.Errorf("annotation %s requires the app containers to request CPU", annotations.KeyAppMaxConcurrencyPerCPU)
+	}
+
+	concurrency := int(int64(*c.AppMaxConcurrencyPerCPU) * milliCPU / 1000)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	c.AppMaxConcurrency = &concurrency
+	return nil
+}
+
+// setMemoryLimitFromApp sets the sidecar's memory limit to MemoryLimitFractionOfApp times the memory limit of the app containers.
+// This applies only if no memory request or limit is set for the sidecar, and if all app containers have a memory limit.
+func (c *SidecarConfig) setMemoryLimitFromApp(appContainers map[int]corev1.Container) {
+	if c.MemoryLimitFractionOfApp <= 0 || c.SidecarMemoryRequest != "" || c.SidecarMemoryLimit != "" || len(appContainers) == 0 {
+		return
+	}
+
+	var appLimit int64
+	for _, container := range appContainers {
+		mem, ok := container.Resources.Limits[corev1.ResourceMemory]
+		if !ok || mem.IsZero() {
+			// The app's memory is unbounded
+			return
+		}
+		appLimit += mem.Value()
+	}
+
+	limit := int64(float64(appLimit) * c.MemoryLimitFractionOfApp)
+	if limit <= 0 {
+		return
+	}
+	c.SidecarMemoryLimit = resource.NewQuantity(limit, resource.BinarySI).String()
+}
+
+// setGuaranteedQoSResources sets the same requests and limits for CPU and memory, so the sidecar doesn't prevent the pod from being in the Guaranteed QoS class.
+// For each resource, the limit is used if set, otherwise the request is used as the limit too.
+func setGuaranteedQoSResources(r *corev1.ResourceRequirements) error {
+	if r.Limits == nil {
+		r.Limits = corev1.ResourceList{}
+	}
+	if r.Requests == nil {
+		r.Requests = corev1.ResourceList{}
+	}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		q, ok := r.Limits[name]
+		if !ok {
+			q, ok = r.Requests[name]
+		}
+		if !ok || q.IsZero() {
+			return fmt.Errorf("annotation %s requires a request or limit for the sidecar's %s: set annotation %s and %s", annotations.KeySidecarGuaranteedQoS, name, annotations.KeyCPULimit, annotations.KeyMemoryLimit)
+		}
+		r.Limits[name] = q
+		r.Requests[name] = q
+	}
+	return nil
+}
+
+// setStartupCPUBoost raises the sidecar's CPU request to the startup boost, so the sidecar gets more CPU while it starts.
+// The boost must be larger than the regular CPU request, which is recorded on the pod so it can be restored once the sidecar has started, and must not exceed the CPU limit.
+func (c *SidecarConfig) setStartupCPUBoost(r *corev1.ResourceRequirements) error {
+	boost, err := resource.ParseQuantity(c.SidecarStartupCPUBoost)
+	if err != nil {
+		return fmt.Errorf("invalid value for annotation %s: %w", annotations.KeySidecarStartupCPUBoost, err)
+	}
+	request, ok := r.Requests[corev1.ResourceCPU]
+	if !ok || request.IsZero() {
+		return fmt.Errorf("annotation %s requires a CPU request for the sidecar: set annotation %s", annotations.KeySidecarStartupCPUBoost, annotations.KeyCPURequest)
+	}
+	if boost.Cmp(request) <= 0 {
+		return fmt.Errorf("invalid value for annotation %s: must be larger than the sidecar's CPU request %s", annotations.KeySidecarStartupCPUBoost, request.String())
+	}
+	if limit, ok := r.Limits[corev1.ResourceCPU]; ok && boost.Cmp(limit) > 0 {
+		return fmt.Errorf("invalid value for annotation %s: must not be larger than the sidecar's CPU limit %s", annotations.KeySidecarStartupCPUBoost, limit.String())
+	}
+	r.Requests[corev1.ResourceCPU] = boost
+	return nil
+}
+
 func (c *SidecarConfig) getResourceRequirements() (*corev1.ResourceRequirements, error) {
 	r := corev1.ResourceRequirements{
 		Limits:   corev1.ResourceList{},
@@ -406,6 +661,106 @@ func (c *SidecarConfig) getResourceRequirements() (*corev1.ResourceRequirements,
 	return &r, nil
 }
 
+// getReservedPorts returns the ports used by the sidecar and by the app, with a description of each.
+// Because all containers in a pod share the same network namespace, these can't be re-used.
+func (c *SidecarConfig) getReservedPorts() map[int32]string {
+	reserved := map[int32]string{
+		c.SidecarHTTPPort:         "Dapr HTTP port",
+		c.SidecarAPIGRPCPort:      "Dapr gRPC port",
+		c.SidecarInternalGRPCPort: "Dapr internal gRPC port",
+		c.SidecarPublicPort:       "Dapr public port",
+	}
+	if c.EnableMetrics {
+		reserved[c.SidecarMetricsPort] = "Dapr metrics port"
+	}
+	if c.EnableDebug {
+		reserved[c.SidecarDebugPort] = "Dapr debug port"
+	}
+	if c.EnableProfiling {
+		reserved[c.SidecarProfilePort] = "Dapr profiling port"
+	}
+	if c.AppPort > 0 {
+		reserved[c.AppPort] = "app port"
+	}
+	return reserved
+}
+
+// validateLivenessProbe returns an error if the settings for the sidecar's liveness probe are not valid.
+// The initial delay may be 0, while the period and the failure threshold must be positive.
+func (c *SidecarConfig) validateLivenessProbe() error {
+	if c.SidecarLivenessProbeDelaySeconds < 0 {
+		return fmt.Errorf("invalid value for annotation %s: must not be negative", annotations.KeyLivenessProbeDelaySeconds)
+	}
+	if c.SidecarLivenessProbePeriodSeconds < 1 {
+		return fmt.Errorf("invalid value for annotation %s: must be a positive number", annotations.KeyLivenessProbePeriodSeconds)
+	}
+	if c.SidecarLivenessProbeThreshold < 1 {
+		return fmt.Errorf("invalid value for annotation %s: must be a positive number", annotations.KeyLivenessProbeThreshold)
+	}
+	return nil
+}
+
+// getExposedPorts returns the list of additional ports to expose on the sidecar container, from the SidecarExposePorts annotation.
+// The format of the annotation is a comma-separated list of port numbers.
+func (c *SidecarConfig) getExposedPorts() ([]corev1.ContainerPort, error) {
+	if c.SidecarExposePorts == "" {
+		return nil, nil
+	}
+
+	reserved := c.getReservedPorts()
+	parts := strings.Split(c.SidecarExposePorts, ",")
+	ports := make([]corev1.ContainerPort, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		port, err := strconv.ParseInt(part, 10, 32)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port '%s' in annotation %s: must be a number between 1 and 65535", part, annotations.KeySidecarExposePorts)
+		}
+		if desc, ok := reserved[int32(port)]; ok {
+			return nil, fmt.Errorf("port %d in annotation %s conflicts with the %s", port, annotations.KeySidecarExposePorts, desc)
+		}
+		reserved[int32(port)] = "port exposed with annotation " + annotations.KeySidecarExposePorts
+		ports = append(ports, corev1.ContainerPort{
+			Name:          injectorConsts.SidecarExposedPortNamePrefix + part,
+			ContainerPort: int32(port),
+		})
+	}
+	return ports, nil
+}
+
+// ValidateHostAddresses validates a comma-separated list of "host:port" addresses, such as the addresses of a service deployed in HA mode.
+func ValidateHostAddresses(val string) error {
+	for _, addr := range strings.Split(val, ",") {
+		err := ValidateHostAddress(strings.TrimSpace(addr))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateHostAddress validates a single "host:port" address, such as the address of the sentry service.
+func ValidateHostAddress(addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("address '%s' is not in the format host:port: %w", addr, err)
+	}
+	if host == "" {
+		return fmt.Errorf("address '%s' has an empty host", addr)
+	}
+	if strings.Contains(host, ",") {
+		return fmt.Errorf("address '%s' must be a single address", addr)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || port == 0 {
+		return fmt.Errorf("address '%s' has an invalid port", addr)
+	}
+	return nil
+}
+
 // GetAppID returns the AppID property, fallinb back to the name of the pod.
 func (c *SidecarConfig) GetAppID() string {
 	if c.AppID == "" {
@@ -417,6 +772,62 @@ func (c *SidecarConfig) GetAppID() string {
 
 var envRegexp = regexp.MustCompile(`(?m)(,)\s*[a-zA-Z\_][a-zA-Z0-9\_]*=`)
 
+// getDownwardAPIEnv returns the env vars with the namespace, node name, and IP of the pod, from the downward API.
+func getDownwardAPIEnv() []corev1.EnvVar {
+	fields := []struct {
+		name string
+		path string
+	}{
+		{injectorConsts.PodNamespaceEnvVar, "metadata.namespace"},
+		{injectorConsts.NodeNameEnvVar, "spec.nodeName"},
+		{injectorConsts.PodIPEnvVar, "status.podIP"},
+	}
+
+	env := make([]corev1.EnvVar, len(fields))
+	for i, f := range fields {
+		env[i] = corev1.EnvVar{
+			Name: f.name,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: f.path,
+				},
+			},
+		}
+	}
+	return env
+}
+
+// removeReservedEnv removes from the env vars set by the user the ones that conflict with env vars managed by the injector, which take precedence.
+// If the user sets the same env var more than once, only the last value is kept.
+func removeReservedEnv(managed []corev1.EnvVar, envKeys []string, envVars []corev1.EnvVar) ([]string, []corev1.EnvVar) {
+	reserved := make(map[string]struct{}, len(managed)+1)
+	for _, e := range managed {
+		reserved[e.Name] = struct{}{}
+	}
+	reserved[securityConsts.EnvKeysEnvVar] = struct{}{}
+
+	// Find the position of the last value for each env var
+	last := make(map[string]int, len(envVars))
+	for i, e := range envVars {
+		last[e.Name] = i
+	}
+
+	resKeys := make([]string, 0, len(envKeys))
+	resVars := make([]corev1.EnvVar, 0, len(envVars))
+	for i, e := range envVars {
+		if _, ok := reserved[e.Name]; ok {
+			log.Warnf("Ignoring env var '%s' set in annotation %s: it is managed by the injector", e.Name, annotations.KeyEnv)
+			continue
+		}
+		if last[e.Name] != i {
+			continue
+		}
+		resKeys = append(resKeys, e.Name)
+		resVars = append(resVars, e)
+	}
+	return resKeys, resVars
+}
+
 // getEnv returns the EnvVar slice from the Env annotation.
 func (c *SidecarConfig) getEnv() (envKeys []string, envVars []corev1.EnvVar) {
 	if c.Env == "" {
@@ -449,6 +860,36 @@ func (c *SidecarConfig) getEnv() (envKeys []string, envVars []corev1.EnvVar) {
 	return envKeys, envVars
 }
 
+// getAppHealthCheckPath returns the path for the app health checks, which is the value of the AppHealthCheckPath annotation if set.
+// Otherwise, the default depends on the app protocol: HTTP apps are probed on "/healthz", while gRPC apps use the gRPC health checking protocol, which has no path.
+func (c *SidecarConfig) getAppHealthCheckPath() string {
+	if c.AppHealthCheckPath != "" {
+		return c.AppHealthCheckPath
+	}
+	if c.isGRPCApp() {
+		return ""
+	}
+	return defaultAppHealthCheckPath
+}
+
+// isMTLSEnabled returns true if mTLS is enabled for the sidecar.
+// The value enforced for the namespace takes precedence over the EnableMTLS annotation, which takes precedence over the Dapr system configuration.
+func (c *SidecarConfig) isMTLSEnabled() bool {
+	if c.ForcedMTLSEnabled != nil {
+		return *c.ForcedMTLSEnabled
+	}
+	if c.EnableMTLS != nil {
+		return *c.EnableMTLS
+	}
+	return c.MTLSEnabled
+}
+
+// isGRPCApp returns true if the app uses gRPC for the app channel.
+func (c *SidecarConfig) isGRPCApp() bool {
+	appProtocol := c.GetAppProtocol()
+	return appProtocol == string(protocol.GRPCProtocol) || appProtocol == string(protocol.GRPCSProtocol)
+}
+
 func (c *SidecarConfig) GetAppProtocol() string {
 	appProtocol := strings.ToLower(c.AppProtocol)
 
@@ -478,6 +919,20 @@ func (c *SidecarConfig) GetAppProtocol() string {
 	}
 }
 
+// checkAppProtocolIsSecure returns an error if ForbidInsecureAppProtocol is set and the sidecar would communicate with the app using a protocol without TLS.
+// Pods that don't have an app port are always allowed, since the sidecar doesn't connect to the app.
+func (c *SidecarConfig) checkAppProtocolIsSecure() error {
+	if !c.ForbidInsecureAppProtocol || c.AppPort <= 0 {
+		return nil
+	}
+	switch appProtocol := c.GetAppProtocol(); appProtocol {
+	case string(protocol.HTTPSProtocol), string(protocol.GRPCSProtocol):
+		return nil
+	default:
+		return fmt.Errorf("app protocol '%s' is not allowed: the injector is configured to require TLS for the app channel, so annotation %s must be set to '%s' or '%s'", appProtocol, annotations.KeyAppProtocol, protocol.HTTPSProtocol, protocol.GRPCSProtocol)
+	}
+}
+
 func getProbeHTTPHandler(port int32, pathElements ...string) corev1.ProbeHandler {
 	return corev1.ProbeHandler{
 		HTTPGet: &corev1.HTTPGetAction{
@@ -495,6 +950,43 @@ func formatProbePath(elements ...string) string {
 	return pathStr
 }
 
+// mergeSecurityContext copies all fields that are set in src into dst.
+func mergeSecurityContext(dst *corev1.SecurityContext, src *corev1.SecurityContext) {
+	if src.Capabilities != nil {
+		dst.Capabilities = src.Capabilities.DeepCopy()
+	}
+	if src.Privileged != nil {
+		dst.Privileged = ptr.Of(*src.Privileged)
+	}
+	if src.SELinuxOptions != nil {
+		dst.SELinuxOptions = src.SELinuxOptions.DeepCopy()
+	}
+	if src.WindowsOptions != nil {
+		dst.WindowsOptions = src.WindowsOptions.DeepCopy()
+	}
+	if src.RunAsUser != nil {
+		dst.RunAsUser = ptr.Of(*src.RunAsUser)
+	}
+	if src.RunAsGroup != nil {
+		dst.RunAsGroup = ptr.Of(*src.RunAsGroup)
+	}
+	if src.RunAsNonRoot != nil {
+		dst.RunAsNonRoot = ptr.Of(*src.RunAsNonRoot)
+	}
+	if src.ReadOnlyRootFilesystem != nil {
+		dst.ReadOnlyRootFilesystem = ptr.Of(*src.ReadOnlyRootFilesystem)
+	}
+	if src.AllowPrivilegeEscalation != nil {
+		dst.AllowPrivilegeEscalation = ptr.Of(*src.AllowPrivilegeEscalation)
+	}
+	if src.ProcMount != nil {
+		dst.ProcMount = ptr.Of(*src.ProcMount)
+	}
+	if src.SeccompProfile != nil {
+		dst.SeccompProfile = src.SeccompProfile.DeepCopy()
+	}
+}
+
 // podContainsTolerations returns true if the pod contains any of the tolerations specified in ts.
 func podContainsTolerations(ts []corev1.Toleration, podTolerations []corev1.Toleration) bool {
 	if len(ts) == 0 || len(podTolerations) == 0 {
@@ -510,3 +1002,17 @@ func podContainsTolerations(ts []corev1.Toleration, podTolerations []corev1.Tole
 
 	return false
 }
+
+// getCostAllocationLabels returns the pod labels listed in CostAllocationLabels, as comma-separated "key=value" pairs.
+// Labels that aren't set on the pod are skipped.
+func (c *SidecarConfig) getCostAllocationLabels() string {
+	pairs := make([]string, 0, len(c.CostAllocationLabels))
+	for _, key := range c.CostAllocationLabels {
+		val, ok := c.pod.Labels[key]
+		if !ok {
+			continue
+		}
+		pairs = append(pairs, key+"="+val)
+	}
+	return strings.Join(pairs, ",")
+}
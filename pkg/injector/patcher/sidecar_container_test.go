@@ -14,18 +14,21 @@ limitations under the License.
 package patcher
 
 import (
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/dapr/dapr/pkg/injector/annotations"
 	injectorConsts "github.com/dapr/dapr/pkg/injector/consts"
 	securityConsts "github.com/dapr/dapr/pkg/security/consts"
+	"github.com/dapr/kit/ptr"
 )
 
 func TestParseEnvString(t *testing.T) {
@@ -216,6 +219,189 @@ func TestGetResourceRequirements(t *testing.T) {
 	})
 }
 
+func TestSetAppMaxConcurrencyFromCPU(t *testing.T) {
+	appContainers := func(cpus ...string) map[int]corev1.Container {
+		res := make(map[int]corev1.Container, len(cpus))
+		for i, cpu := range cpus {
+			res[i] = corev1.Container{
+				Name: "app" + strconv.Itoa(i),
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU: resource.MustParse(cpu),
+					},
+				},
+			}
+		}
+		return res
+	}
+
+	getConcurrency := func(t *testing.T, perCPU string, containers map[int]corev1.Container) (*int, error) {
+		c := NewSidecarConfig(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					annotations.KeyAppMaxConcurrencyPerCPU: perCPU,
+				},
+			},
+		})
+		c.SetFromPodAnnotations()
+		err := c.setAppMaxConcurrencyFromCPU(containers)
+		return c.AppMaxConcurrency, err
+	}
+
+	t.Run("not set", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{})
+		require.NoError(t, c.setAppMaxConcurrencyFromCPU(appContainers("2")))
+		assert.Nil(t, c.AppMaxConcurrency)
+	})
+
+	for _, tc := range []struct {
+		name     string
+		perCPU   string
+		cpus     []string
+		expected int
+	}{
+		{name: "whole cores", perCPU: "10", cpus: []string{"2"}, expected: 20},
+		{name: "fractional cores are rounded down", perCPU: "10", cpus: []string{"250m"}, expected: 2},
+		{name: "minimum is 1", perCPU: "1", cpus: []string{"100m"}, expected: 1},
+		{name: "multiple app containers", perCPU: "4", cpus: []string{"500m", "1500m"}, expected: 8},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			concurrency, err := getConcurrency(t, tc.perCPU, appContainers(tc.cpus...))
+			require.NoError(t, err)
+			require.NotNil(t, concurrency)
+			assert.Equal(t, tc.expected, *concurrency)
+		})
+	}
+
+	t.Run("CPU limit is used if there's no request", func(t *testing.T) {
+		concurrency, err := getConcurrency(t, "3", map[int]corev1.Container{
+			0: {
+				Name: "app",
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{
+						corev1.ResourceCPU: resource.MustParse("2"),
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, concurrency)
+		assert.Equal(t, 6, *concurrency)
+	})
+
+	t.Run("not positive", func(t *testing.T) {
+		_, err := getConcurrency(t, "0", appContainers("1"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), annotations.KeyAppMaxConcurrencyPerCPU)
+	})
+
+	t.Run("no CPU requested", func(t *testing.T) {
+		_, err := getConcurrency(t, "10", map[int]corev1.Container{0: {Name: "app"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "requires the app containers to request CPU")
+	})
+
+	t.Run("conflicts with app-max-concurrency", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					annotations.KeyAppMaxConcurrencyPerCPU: "10",
+					annotations.KeyAppMaxConcurrency:       "5",
+				},
+			},
+		})
+		c.SetFromPodAnnotations()
+		require.Error(t, c.setAppMaxConcurrencyFromCPU(appContainers("1")))
+	})
+
+	t.Run("computed value is passed to the sidecar", func(t *testing.T) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled:                 "true",
+					annotations.KeyAppMaxConcurrencyPerCPU: "8",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{appContainers("1500m")[0]},
+			},
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		c.SetFromPodAnnotations()
+
+		patch, err := c.GetPatch()
+		require.NoError(t, err)
+		newPod, err := PatchPod(pod, patch)
+		require.NoError(t, err)
+		require.Len(t, newPod.Spec.Containers, 2)
+		assert.Contains(t, strings.Join(newPod.Spec.Containers[1].Args, " "), "--app-max-concurrency 12")
+	})
+}
+
+func TestSetMemoryLimitFromApp(t *testing.T) {
+	appContainers := func(limits ...string) map[int]corev1.Container {
+		res := make(map[int]corev1.Container, len(limits))
+		for i, limit := range limits {
+			container := corev1.Container{
+				Name: "app" + strconv.Itoa(i),
+			}
+			if limit != "" {
+				container.Resources.Limits = corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse(limit),
+				}
+			}
+			res[i] = container
+		}
+		return res
+	}
+
+	getMemoryLimit := func(fraction float64, an map[string]string, containers map[int]corev1.Container) string {
+		c := NewSidecarConfig(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: an,
+			},
+		})
+		c.MemoryLimitFractionOfApp = fraction
+		c.SetFromPodAnnotations()
+		c.setMemoryLimitFromApp(containers)
+		return c.SidecarMemoryLimit
+	}
+
+	t.Run("computed from the app's limit", func(t *testing.T) {
+		assert.Equal(t, "256Mi", getMemoryLimit(0.25, nil, appContainers("1Gi")))
+	})
+
+	t.Run("computed from the limits of multiple app containers", func(t *testing.T) {
+		assert.Equal(t, "384Mi", getMemoryLimit(0.25, nil, appContainers("1Gi", "512Mi")))
+	})
+
+	t.Run("not set by default", func(t *testing.T) {
+		assert.Empty(t, getMemoryLimit(0, nil, appContainers("1Gi")))
+	})
+
+	t.Run("no-op if the app has no limit", func(t *testing.T) {
+		assert.Empty(t, getMemoryLimit(0.25, nil, appContainers("")))
+	})
+
+	t.Run("no-op if one of the app containers has no limit", func(t *testing.T) {
+		assert.Empty(t, getMemoryLimit(0.25, nil, appContainers("1Gi", "")))
+	})
+
+	t.Run("explicit memory limit is preserved", func(t *testing.T) {
+		assert.Equal(t, "100Mi", getMemoryLimit(0.25, map[string]string{
+			annotations.KeyMemoryLimit: "100Mi",
+		}, appContainers("1Gi")))
+	})
+
+	t.Run("no-op if a memory request is set", func(t *testing.T) {
+		assert.Empty(t, getMemoryLimit(0.25, map[string]string{
+			annotations.KeyMemoryRequest: "100Mi",
+		}, appContainers("1Gi")))
+	})
+}
+
 func TestGetProbeHttpHandler(t *testing.T) {
 	pathElements := []string{"api", "v1", "healthz"}
 	expectedPath := "/api/v1/healthz"
@@ -385,6 +571,80 @@ func TestGetSidecarContainer(t *testing.T) {
 		assert.Equal(t, corev1.PullAlways, container.ImagePullPolicy)
 	})
 
+	t.Run("sidecar container args have a stable order", func(t *testing.T) {
+		getArgs := func() []string {
+			c := NewSidecarConfig(&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotations.KeyAppID:                "app_id",
+						annotations.KeyConfig:               "config",
+						annotations.KeyAppPort:              "5000",
+						annotations.KeyAppProtocol:          "grpc",
+						annotations.KeyLogLevel:             "debug",
+						annotations.KeyLogAsJSON:            "true",
+						annotations.KeyEnableProfiling:      "true",
+						annotations.KeyEnableAPILogging:     "true",
+						annotations.KeyEnableAppHealthCheck: "true",
+						annotations.KeyAppMaxConcurrency:    "10",
+						annotations.KeyHTTPMaxRequestSize:   "8",
+						annotations.KeyHTTPReadBufferSize:   "16",
+						annotations.KeyAppChannel:           "10.0.0.1",
+						annotations.KeyUnixDomainSocketPath: "/tmp",
+					},
+				},
+			})
+			c.OperatorAddress = "controlplane:9000"
+			c.PlacementAddress = "placement:50000"
+			c.SentryAddress = "sentry:50000"
+			c.MTLSEnabled = true
+			c.SetFromPodAnnotations()
+
+			container, err := c.getSidecarContainer(getSidecarContainerOpts{})
+			require.NoError(t, err)
+			return container.Args
+		}
+
+		expectedArgs := []string{
+			"/daprd",
+			"--dapr-http-port", "3500",
+			"--dapr-grpc-port", "50001",
+			"--dapr-internal-grpc-port", "50002",
+			"--dapr-listen-addresses", "[::1],127.0.0.1",
+			"--dapr-public-port", "3501",
+			"--app-id", "app_id",
+			"--app-protocol", "grpc",
+			"--log-level", "debug",
+			"--dapr-graceful-shutdown-seconds", "-1",
+			"--mode", "kubernetes",
+			"--control-plane-address", "controlplane:9000",
+			"--sentry-address", "sentry:50000",
+			"--app-port", "5000",
+			"--enable-metrics",
+			"--metrics-port", "9090",
+			"--config", "config",
+			"--app-channel-address", "10.0.0.1",
+			"--placement-host-address", "placement:50000",
+			"--enable-api-logging=true",
+			"--enable-app-health-check",
+			"--app-health-probe-interval", "5",
+			"--app-health-probe-timeout", "500",
+			"--app-health-threshold", "3",
+			"--log-as-json",
+			"--enable-profiling",
+			"--profile-port", "7777",
+			"--enable-mtls",
+			"--app-max-concurrency", "10",
+			"--dapr-http-max-request-size", "8",
+			"--dapr-http-read-buffer-size", "16",
+			"--unix-domain-socket", "/var/run/dapr-sockets",
+		}
+
+		// Annotations are stored in a map, so generate the args multiple times to make sure the order doesn't depend on iteration order
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, expectedArgs, getArgs())
+		}
+	})
+
 	t.Run("get sidecar container with debugging", func(t *testing.T) {
 		c := NewSidecarConfig(&corev1.Pod{
 			ObjectMeta: metav1.ObjectMeta{
@@ -601,6 +861,26 @@ func TestGetSidecarContainer(t *testing.T) {
 		},
 	}))
 
+	t.Run("termination message policy", testSuiteGenerator([]testCase{
+		{
+			name:        "default",
+			annotations: map[string]string{},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Equal(t, corev1.TerminationMessageFallbackToLogsOnError, container.TerminationMessagePolicy)
+			},
+		},
+		{
+			name:        "file",
+			annotations: map[string]string{},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Equal(t, corev1.TerminationMessageReadFile, container.TerminationMessagePolicy)
+			},
+		},
+	}))
+
 	t.Run("unix domain socket", testSuiteGenerator([]testCase{
 		{
 			name:        "default does not use UDS",
@@ -636,6 +916,79 @@ func TestGetSidecarContainer(t *testing.T) {
 		},
 	}))
 
+	t.Run("downward API env", func(t *testing.T) {
+		getFieldRefs := func(container *corev1.Container) map[string]string {
+			res := map[string]string{}
+			for _, e := range container.Env {
+				if e.ValueFrom != nil && e.ValueFrom.FieldRef != nil {
+					res[e.Name] = e.ValueFrom.FieldRef.FieldPath
+				}
+			}
+			return res
+		}
+
+		expected := map[string]string{
+			"POD_NAME":      "metadata.name",
+			"POD_NAMESPACE": "metadata.namespace",
+			"NODE_NAME":     "spec.nodeName",
+			"POD_IP":        "status.podIP",
+		}
+
+		testSuiteGenerator([]testCase{
+			{
+				name:        "only the pod name by default",
+				annotations: map[string]string{},
+				assertFn: func(t *testing.T, container *corev1.Container) {
+					assert.Equal(t, map[string]string{"POD_NAME": "metadata.name"}, getFieldRefs(container))
+				},
+			},
+			{
+				name: "enabled with annotation",
+				annotations: map[string]string{
+					annotations.KeySidecarDownwardAPIEnv: "true",
+				},
+				assertFn: func(t *testing.T, container *corev1.Container) {
+					assert.Equal(t, expected, getFieldRefs(container))
+				},
+			},
+			{
+				name:        "enabled by default value",
+				annotations: map[string]string{},
+				sidecarConfigModifierFn: func(c *SidecarConfig) {
+					c.SidecarDownwardAPIEnv = true
+				},
+				assertFn: func(t *testing.T, container *corev1.Container) {
+					assert.Equal(t, expected, getFieldRefs(container))
+				},
+			},
+			{
+				name: "default value overridden by annotation",
+				annotations: map[string]string{
+					annotations.KeySidecarDownwardAPIEnv: "false",
+				},
+				sidecarConfigModifierFn: func(c *SidecarConfig) {
+					c.SidecarDownwardAPIEnv = true
+				},
+				assertFn: func(t *testing.T, container *corev1.Container) {
+					assert.Equal(t, map[string]string{"POD_NAME": "metadata.name"}, getFieldRefs(container))
+				},
+			},
+			{
+				name: "not overridden by the env annotation",
+				annotations: map[string]string{
+					annotations.KeySidecarDownwardAPIEnv: "true",
+					annotations.KeyEnv:                   "POD_IP=127.0.0.1",
+				},
+				assertFn: func(t *testing.T, container *corev1.Container) {
+					assert.Equal(t, expected, getFieldRefs(container))
+					for _, e := range container.Env {
+						assert.NotEqual(t, "127.0.0.1", e.Value)
+					}
+				},
+			},
+		})(t)
+	})
+
 	t.Run("test enable-api-logging", testSuiteGenerator([]testCase{
 		{
 			name:        "not set by default",
@@ -673,84 +1026,345 @@ func TestGetSidecarContainer(t *testing.T) {
 		},
 	}))
 
-	t.Run("sidecar container should have the correct security context on Windows", testSuiteGenerator([]testCase{
+	t.Run("liveness probe", testSuiteGenerator([]testCase{
 		{
-			name:        "windows security context is nil by default",
+			name:        "default values",
 			annotations: map[string]string{},
 			assertFn: func(t *testing.T, container *corev1.Container) {
-				assert.Nil(t, container.SecurityContext.WindowsOptions)
+				require.NotNil(t, container.LivenessProbe)
+				assert.Equal(t, int32(3), container.LivenessProbe.InitialDelaySeconds)
+				assert.Equal(t, int32(6), container.LivenessProbe.PeriodSeconds)
+				assert.Equal(t, int32(3), container.LivenessProbe.FailureThreshold)
 			},
 		},
 		{
-			name: "setting SSL_CERT_DIR should set security context",
+			name: "initial delay override",
 			annotations: map[string]string{
-				annotations.KeyEnv: "SSL_CERT_DIR=/tmp/certificates",
+				annotations.KeyLivenessProbeDelaySeconds: "30",
 			},
 			assertFn: func(t *testing.T, container *corev1.Container) {
-				assert.NotNil(t, container.SecurityContext.WindowsOptions, "SecurityContext.WindowsOptions should not be nil")
-				assert.Equal(t, "ContainerAdministrator", *container.SecurityContext.WindowsOptions.RunAsUserName, "SecurityContext.WindowsOptions.RunAsUserName should be ContainerAdministrator")
+				assert.Equal(t, int32(30), container.LivenessProbe.InitialDelaySeconds)
 			},
 		},
 		{
-			name: "setting SSL_CERT_FILE should not set security context",
+			name: "initial delay may be zero",
 			annotations: map[string]string{
-				annotations.KeyEnv: "SSL_CERT_FILE=/tmp/certificates/cert.pem",
+				annotations.KeyLivenessProbeDelaySeconds: "0",
 			},
 			assertFn: func(t *testing.T, container *corev1.Container) {
-				assert.Nil(t, container.SecurityContext.WindowsOptions)
+				assert.Equal(t, int32(0), container.LivenessProbe.InitialDelaySeconds)
+			},
+		},
+		{
+			name: "period override",
+			annotations: map[string]string{
+				annotations.KeyLivenessProbePeriodSeconds: "20",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Equal(t, int32(20), container.LivenessProbe.PeriodSeconds)
+			},
+		},
+		{
+			name: "failure threshold override",
+			annotations: map[string]string{
+				annotations.KeyLivenessProbeThreshold: "10",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Equal(t, int32(10), container.LivenessProbe.FailureThreshold)
 			},
 		},
 	}))
 
-	t.Run("sidecar container should have env vars injected", testCaseFn(testCase{
-		annotations: map[string]string{
-			annotations.KeyEnv: `HELLO=world, CIAO=mondo, BONJOUR=monde`,
-		},
-		assertFn: func(t *testing.T, container *corev1.Container) {
-			expect := map[string]string{
-				"HELLO":                      "world",
-				"CIAO":                       "mondo",
-				"BONJOUR":                    "monde",
-				securityConsts.EnvKeysEnvVar: "HELLO CIAO BONJOUR",
-			}
+	t.Run("invalid liveness probe", func(t *testing.T) {
+		for key, val := range map[string]string{
+			annotations.KeyLivenessProbeDelaySeconds:  "-1",
+			annotations.KeyLivenessProbePeriodSeconds: "0",
+			annotations.KeyLivenessProbeThreshold:     "-3",
+		} {
+			c := NewSidecarConfig(&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						key: val,
+					},
+				},
+			})
+			c.SetFromPodAnnotations()
 
-			found := map[string]string{}
-			for _, env := range container.Env {
-				switch env.Name {
-				case "HELLO", "CIAO", "BONJOUR", securityConsts.EnvKeysEnvVar:
-					found[env.Name] = env.Value
-				}
-			}
+			_, err := c.getSidecarContainer(getSidecarContainerOpts{})
+			require.Error(t, err, key)
+			assert.Contains(t, err.Error(), key)
+		}
+	})
 
-			assert.Equal(t, expect, found)
+	t.Run("profile-port", testSuiteGenerator([]testCase{
+		{
+			name: "default port",
+			annotations: map[string]string{
+				annotations.KeyEnableProfiling: "true",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Contains(t, strings.Join(container.Args, " "), "--enable-profiling --profile-port 7777")
+			},
+		},
+		{
+			name: "custom port",
+			annotations: map[string]string{
+				annotations.KeyEnableProfiling: "true",
+				annotations.KeyProfilePort:     "7070",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Contains(t, strings.Join(container.Args, " "), "--profile-port 7070")
+			},
+		},
+		{
+			name: "ignored when profiling is disabled",
+			annotations: map[string]string{
+				annotations.KeyProfilePort: "3500",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.NotContains(t, container.Args, "--profile-port")
+			},
 		},
 	}))
 
-	t.Run("sidecar container should specify commands only when ignoreEntrypointTolerations match with the pod", func(t *testing.T) {
-		testCases := []struct {
-			name                        string
-			tolerations                 []corev1.Toleration
-			ignoreEntrypointTolerations []corev1.Toleration
-			explicitCommandSpecified    bool
-		}{
-			{
-				"no tolerations",
-				[]corev1.Toleration{},
-				[]corev1.Toleration{},
-				false,
-			},
-			{
-				"pod contains tolerations from ignoreEntrypointTolerations (single)",
-				[]corev1.Toleration{
-					{
-						Key:    "foo.com/bar",
-						Effect: "NoSchedule",
-					},
+	t.Run("invalid profile-port", func(t *testing.T) {
+		for name, an := range map[string]map[string]string{
+			"out of range":              {annotations.KeyProfilePort: "70000"},
+			"conflicts with HTTP port":  {annotations.KeyProfilePort: "3500"},
+			"conflicts with debug port": {annotations.KeyProfilePort: "40000", annotations.KeyEnableDebug: "true"},
+			"conflicts with app port":   {annotations.KeyProfilePort: "8080", annotations.KeyAppPort: "8080"},
+		} {
+			an[annotations.KeyEnableProfiling] = "true"
+			c := NewSidecarConfig(&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: an,
 				},
-				[]corev1.Toleration{
-					{
-						Key:    "foo.com/bar",
-						Effect: "NoSchedule",
+			})
+			c.SetFromPodAnnotations()
+
+			_, err := c.getSidecarContainer(getSidecarContainerOpts{})
+			require.Error(t, err, name)
+			assert.Contains(t, err.Error(), annotations.KeyProfilePort, name)
+		}
+	})
+
+	t.Run("sidecar container should have the correct security context on Windows", testSuiteGenerator([]testCase{
+		{
+			name:        "windows security context is nil by default",
+			annotations: map[string]string{},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Nil(t, container.SecurityContext.WindowsOptions)
+			},
+		},
+		{
+			name: "setting SSL_CERT_DIR should set security context",
+			annotations: map[string]string{
+				annotations.KeyEnv: "SSL_CERT_DIR=/tmp/certificates",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.NotNil(t, container.SecurityContext.WindowsOptions, "SecurityContext.WindowsOptions should not be nil")
+				assert.Equal(t, "ContainerAdministrator", *container.SecurityContext.WindowsOptions.RunAsUserName, "SecurityContext.WindowsOptions.RunAsUserName should be ContainerAdministrator")
+			},
+		},
+		{
+			name: "SSL_CERT_DIR for the additional CA certificates should set security context on Windows pods",
+			podModifierFn: func(pod *corev1.Pod) {
+				pod.Spec.OS = &corev1.PodOS{Name: corev1.Windows}
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.AdditionalCASecrets = []string{"corp-ca"}
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Contains(t, container.Env, corev1.EnvVar{
+					Name:  injectorConsts.SSLCertDirEnvVar,
+					Value: injectorConsts.AdditionalCAVolumeMountPath,
+				})
+				require.NotNil(t, container.SecurityContext.WindowsOptions)
+				assert.Equal(t, "ContainerAdministrator", *container.SecurityContext.WindowsOptions.RunAsUserName)
+			},
+		},
+		{
+			name: "SSL_CERT_DIR set by the user and replaced for the additional CA certificates should not set security context",
+			annotations: map[string]string{
+				annotations.KeyEnv: "SSL_CERT_DIR=/tmp/certificates",
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.AdditionalCASecrets = []string{"corp-ca"}
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.NotContains(t, container.Env, corev1.EnvVar{
+					Name:  injectorConsts.SSLCertDirEnvVar,
+					Value: "/tmp/certificates",
+				})
+				assert.Nil(t, container.SecurityContext.WindowsOptions)
+			},
+		},
+		{
+			name: "SSL_CERT_DIR for the additional CA certificates should not set security context",
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.AdditionalCASecrets = []string{"corp-ca"}
+				c.ReadOnlyRootFilesystem = true
+				c.RunAsNonRoot = true
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Contains(t, container.Env, corev1.EnvVar{
+					Name:  injectorConsts.SSLCertDirEnvVar,
+					Value: injectorConsts.AdditionalCAVolumeMountPath,
+				})
+				assert.Nil(t, container.SecurityContext.WindowsOptions)
+				assert.True(t, *container.SecurityContext.RunAsNonRoot)
+				assert.True(t, *container.SecurityContext.ReadOnlyRootFilesystem)
+			},
+		},
+		{
+			name: "setting SSL_CERT_FILE should not set security context",
+			annotations: map[string]string{
+				annotations.KeyEnv: "SSL_CERT_FILE=/tmp/certificates/cert.pem",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Nil(t, container.SecurityContext.WindowsOptions)
+			},
+		},
+	}))
+
+	t.Run("sidecar container should have env vars injected", testCaseFn(testCase{
+		annotations: map[string]string{
+			annotations.KeyEnv: `HELLO=world, CIAO=mondo, BONJOUR=monde`,
+		},
+		assertFn: func(t *testing.T, container *corev1.Container) {
+			expect := map[string]string{
+				"HELLO":                      "world",
+				"CIAO":                       "mondo",
+				"BONJOUR":                    "monde",
+				securityConsts.EnvKeysEnvVar: "HELLO CIAO BONJOUR",
+			}
+
+			found := map[string]string{}
+			for _, env := range container.Env {
+				switch env.Name {
+				case "HELLO", "CIAO", "BONJOUR", securityConsts.EnvKeysEnvVar:
+					found[env.Name] = env.Value
+				}
+			}
+
+			assert.Equal(t, expect, found)
+		},
+	}))
+
+	t.Run("env vars managed by the injector take precedence", testCaseFn(testCase{
+		annotations: map[string]string{
+			annotations.KeyEnv:            `NAMESPACE=hack, HELLO=world, DAPR_API_TOKEN=foo, ` + securityConsts.EnvKeysEnvVar + `=bar`,
+			annotations.KeyAPITokenSecret: "secret",
+		},
+		sidecarConfigModifierFn: func(c *SidecarConfig) {
+			c.Namespace = "myns"
+		},
+		assertFn: func(t *testing.T, container *corev1.Container) {
+			found := map[string][]corev1.EnvVar{}
+			for _, env := range container.Env {
+				found[env.Name] = append(found[env.Name], env)
+			}
+
+			for name, envs := range found {
+				assert.Lenf(t, envs, 1, "env var %s is set more than once", name)
+			}
+			assert.Equal(t, "myns", found["NAMESPACE"][0].Value)
+			assert.Equal(t, "world", found["HELLO"][0].Value)
+			require.NotNil(t, found[securityConsts.APITokenEnvVar][0].ValueFrom)
+			assert.Equal(t, "secret", found[securityConsts.APITokenEnvVar][0].ValueFrom.SecretKeyRef.Name)
+			assert.Equal(t, "HELLO", found[securityConsts.EnvKeysEnvVar][0].Value)
+		},
+	}))
+
+	t.Run("duplicate env vars set by the user keep the last value", testCaseFn(testCase{
+		annotations: map[string]string{
+			annotations.KeyEnv: `HELLO=world, CIAO=mondo, HELLO=there`,
+		},
+		assertFn: func(t *testing.T, container *corev1.Container) {
+			found := map[string][]string{}
+			for _, env := range container.Env {
+				found[env.Name] = append(found[env.Name], env.Value)
+			}
+
+			assert.Equal(t, []string{"there"}, found["HELLO"])
+			assert.Equal(t, []string{"mondo"}, found["CIAO"])
+			assert.Equal(t, []string{"CIAO HELLO"}, found[securityConsts.EnvKeysEnvVar])
+		},
+	}))
+
+	t.Run("cost allocation labels", testSuiteGenerator([]testCase{
+		{
+			name:        "not set by default",
+			annotations: map[string]string{},
+			podModifierFn: func(pod *corev1.Pod) {
+				pod.Labels = map[string]string{"team": "payments"}
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				for _, env := range container.Env {
+					assert.NotEqual(t, injectorConsts.CostAllocationLabelsEnvVar, env.Name)
+				}
+			},
+		},
+		{
+			name:        "copies the labels set on the pod",
+			annotations: map[string]string{},
+			podModifierFn: func(pod *corev1.Pod) {
+				pod.Labels = map[string]string{
+					"team":                "payments",
+					"example.com/project": "checkout",
+					"app":                 "myapp",
+				}
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.CostAllocationLabels = []string{"team", "cost-center", "example.com/project"}
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				found := map[string]string{}
+				for _, env := range container.Env {
+					found[env.Name] = env.Value
+				}
+				assert.Equal(t, "team=payments,example.com/project=checkout", found[injectorConsts.CostAllocationLabelsEnvVar])
+			},
+		},
+		{
+			name:        "no matching labels on the pod",
+			annotations: map[string]string{},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.CostAllocationLabels = []string{"team"}
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				for _, env := range container.Env {
+					assert.NotEqual(t, injectorConsts.CostAllocationLabelsEnvVar, env.Name)
+				}
+			},
+		},
+	}))
+
+	t.Run("sidecar container should specify commands only when ignoreEntrypointTolerations match with the pod", func(t *testing.T) {
+		testCases := []struct {
+			name                        string
+			tolerations                 []corev1.Toleration
+			ignoreEntrypointTolerations []corev1.Toleration
+			explicitCommandSpecified    bool
+		}{
+			{
+				"no tolerations",
+				[]corev1.Toleration{},
+				[]corev1.Toleration{},
+				false,
+			},
+			{
+				"pod contains tolerations from ignoreEntrypointTolerations (single)",
+				[]corev1.Toleration{
+					{
+						Key:    "foo.com/bar",
+						Effect: "NoSchedule",
+					},
+				},
+				[]corev1.Toleration{
+					{
+						Key:    "foo.com/bar",
+						Effect: "NoSchedule",
 					},
 				},
 				true,
@@ -913,8 +1527,106 @@ func TestGetSidecarContainer(t *testing.T) {
 				assert.Contains(t, args, "--app-health-threshold 2")
 			},
 		},
+		{
+			name: "default path for https apps",
+			annotations: map[string]string{
+				annotations.KeyEnableAppHealthCheck: "1",
+				annotations.KeyAppProtocol:          "https",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				args := strings.Join(container.Args, " ")
+				assert.Contains(t, args, "--app-health-check-path /healthz")
+			},
+		},
+		{
+			name: "no default path for grpc apps",
+			annotations: map[string]string{
+				annotations.KeyEnableAppHealthCheck: "1",
+				annotations.KeyAppProtocol:          "grpc",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Contains(t, container.Args, "--enable-app-health-check")
+				assert.NotContains(t, container.Args, "--app-health-check-path")
+			},
+		},
+		{
+			name: "no default path for grpcs apps",
+			annotations: map[string]string{
+				annotations.KeyEnableAppHealthCheck: "1",
+				annotations.KeyAppProtocol:          "grpcs",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.NotContains(t, container.Args, "--app-health-check-path")
+			},
+		},
+		{
+			name: "path overridden for grpc apps",
+			annotations: map[string]string{
+				annotations.KeyEnableAppHealthCheck: "1",
+				annotations.KeyAppProtocol:          "grpc",
+				annotations.KeyAppHealthCheckPath:   "/grpc.health.v1.Health/Check",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				args := strings.Join(container.Args, " ")
+				assert.Contains(t, args, "--app-health-check-path /grpc.health.v1.Health/Check")
+			},
+		},
+		{
+			name: "enabled with failure threshold",
+			annotations: map[string]string{
+				annotations.KeyEnableAppHealthCheck:      "1",
+				annotations.KeyAppHealthFailureThreshold: "5",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				args := strings.Join(container.Args, " ")
+				assert.Contains(t, args, "--app-health-threshold 5")
+			},
+		},
+		{
+			name: "failure threshold takes precedence over the threshold",
+			annotations: map[string]string{
+				annotations.KeyEnableAppHealthCheck:      "1",
+				annotations.KeyAppHealthThreshold:        "4",
+				annotations.KeyAppHealthFailureThreshold: "6",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				args := strings.Join(container.Args, " ")
+				assert.Contains(t, args, "--app-health-threshold 6")
+			},
+		},
 	}))
 
+	t.Run("app health checks for gRPC apps", testSuiteGenerator([]testCase{
+		{
+			name: "enabled for a gRPC app",
+			annotations: map[string]string{
+				annotations.KeyEnableAppHealthCheck: "1",
+				annotations.KeyAppProtocol:          "grpc",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Contains(t, container.Args, "--enable-app-health-check")
+			},
+		},
+	}))
+
+	t.Run("app health check threshold errors", func(t *testing.T) {
+		for _, val := range []string{"0", "-1"} {
+			c := NewSidecarConfig(&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotations.KeyEnableAppHealthCheck:      "1",
+						annotations.KeyAppHealthFailureThreshold: val,
+					},
+				},
+			})
+			c.SetFromPodAnnotations()
+
+			_, err := c.getSidecarContainer(getSidecarContainerOpts{})
+			require.Error(t, err, val)
+			assert.Contains(t, err.Error(), annotations.KeyAppHealthFailureThreshold)
+		}
+	})
+
 	t.Run("sidecar container should have env vars injected", testCaseFn(testCase{
 		annotations: map[string]string{
 			annotations.KeyEnableProfiling: "true",
@@ -1077,6 +1789,472 @@ func TestGetSidecarContainer(t *testing.T) {
 		},
 	}))
 
+	t.Run("expose additional ports", testSuiteGenerator([]testCase{
+		{
+			name:        "no additional ports by default",
+			annotations: map[string]string{},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Len(t, container.Ports, 4)
+			},
+		},
+		{
+			name: "additional ports are exposed",
+			annotations: map[string]string{
+				annotations.KeySidecarExposePorts: "9001, 9002",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				require.Len(t, container.Ports, 6)
+				assert.Equal(t, corev1.ContainerPort{Name: "dapr-ext-9001", ContainerPort: 9001}, container.Ports[4])
+				assert.Equal(t, corev1.ContainerPort{Name: "dapr-ext-9002", ContainerPort: 9002}, container.Ports[5])
+			},
+		},
+	}))
+
+	t.Run("expose additional ports errors", func(t *testing.T) {
+		testCases := map[string]map[string]string{
+			"not a number": {
+				annotations.KeySidecarExposePorts: "9001,foo",
+			},
+			"out of range": {
+				annotations.KeySidecarExposePorts: "70000",
+			},
+			"collision with the Dapr HTTP port": {
+				annotations.KeySidecarExposePorts: "3500",
+			},
+			"collision with the metrics port": {
+				annotations.KeySidecarExposePorts: "9001,9090",
+			},
+			"collision with the app port": {
+				annotations.KeyAppPort:            "8080",
+				annotations.KeySidecarExposePorts: "8080",
+			},
+			"collision with the debug port": {
+				annotations.KeyEnableDebug:        "true",
+				annotations.KeySidecarExposePorts: "40000",
+			},
+			"duplicate port": {
+				annotations.KeySidecarExposePorts: "9001,9001",
+			},
+		}
+
+		for name, an := range testCases {
+			t.Run(name, func(t *testing.T) {
+				c := NewSidecarConfig(&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: an,
+					},
+				})
+				c.SetFromPodAnnotations()
+
+				_, err := c.getSidecarContainer(getSidecarContainerOpts{})
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), annotations.KeySidecarExposePorts)
+			})
+		}
+	})
+
+	t.Run("required resource limits", testSuiteGenerator([]testCase{
+		{
+			name: "cpu limit",
+			annotations: map[string]string{
+				annotations.KeyCPULimit: "100m",
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.RequireLimits = true
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Equal(t, "100m", container.Resources.Limits.Cpu().String())
+			},
+		},
+		{
+			name: "cpu and memory limits",
+			annotations: map[string]string{
+				annotations.KeyCPULimit:    "100m",
+				annotations.KeyMemoryLimit: "1Gi",
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.RequireLimits = true
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Equal(t, "100m", container.Resources.Limits.Cpu().String())
+				assert.Equal(t, "1Gi", container.Resources.Limits.Memory().String())
+			},
+		},
+	}))
+
+	t.Run("required resource limits errors", func(t *testing.T) {
+		testCases := map[string]map[string]string{
+			"no resources": {},
+			"only requests": {
+				annotations.KeyCPURequest:    "100m",
+				annotations.KeyMemoryRequest: "64Mi",
+			},
+			"invalid limit": {
+				annotations.KeyCPULimit: "invalid",
+			},
+		}
+
+		for name, an := range testCases {
+			t.Run(name, func(t *testing.T) {
+				c := NewSidecarConfig(&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: an,
+					},
+				})
+				c.RequireLimits = true
+				c.SetFromPodAnnotations()
+
+				_, err := c.getSidecarContainer(getSidecarContainerOpts{})
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "resource limits are required")
+			})
+		}
+	})
+
+	t.Run("guaranteed QoS", testSuiteGenerator([]testCase{
+		{
+			name: "requests set from limits",
+			annotations: map[string]string{
+				annotations.KeySidecarGuaranteedQoS: "true",
+				annotations.KeyCPULimit:             "2",
+				annotations.KeyMemoryLimit:          "1Gi",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Equal(t, "2", container.Resources.Requests.Cpu().String())
+				assert.Equal(t, "1Gi", container.Resources.Requests.Memory().String())
+				assert.Equal(t, container.Resources.Limits, container.Resources.Requests)
+			},
+		},
+		{
+			name: "limits set from requests",
+			annotations: map[string]string{
+				annotations.KeySidecarGuaranteedQoS: "true",
+				annotations.KeyCPURequest:           "1",
+				annotations.KeyMemoryRequest:        "256Mi",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Equal(t, "1", container.Resources.Limits.Cpu().String())
+				assert.Equal(t, "256Mi", container.Resources.Limits.Memory().String())
+				assert.Equal(t, container.Resources.Limits, container.Resources.Requests)
+			},
+		},
+		{
+			name: "limits take precedence over different requests",
+			annotations: map[string]string{
+				annotations.KeySidecarGuaranteedQoS: "true",
+				annotations.KeyCPURequest:           "500m",
+				annotations.KeyCPULimit:             "1",
+				annotations.KeyMemoryRequest:        "128Mi",
+				annotations.KeyMemoryLimit:          "256Mi",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Equal(t, "1", container.Resources.Requests.Cpu().String())
+				assert.Equal(t, "256Mi", container.Resources.Requests.Memory().String())
+				assert.Equal(t, container.Resources.Limits, container.Resources.Requests)
+			},
+		},
+		{
+			name: "requests and limits not changed when disabled",
+			annotations: map[string]string{
+				annotations.KeyCPURequest:  "500m",
+				annotations.KeyCPULimit:    "1",
+				annotations.KeyMemoryLimit: "256Mi",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Equal(t, "500m", container.Resources.Requests.Cpu().String())
+				assert.Equal(t, "1", container.Resources.Limits.Cpu().String())
+				assert.NotContains(t, container.Resources.Requests, corev1.ResourceMemory)
+			},
+		},
+	}))
+
+	t.Run("guaranteed QoS errors", func(t *testing.T) {
+		testCases := map[string]map[string]string{
+			"no resources": {},
+			"only cpu": {
+				annotations.KeyCPULimit: "1",
+			},
+			"only memory": {
+				annotations.KeyMemoryRequest: "64Mi",
+			},
+		}
+
+		for name, an := range testCases {
+			t.Run(name, func(t *testing.T) {
+				an[annotations.KeySidecarGuaranteedQoS] = "true"
+				c := NewSidecarConfig(&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: an,
+					},
+				})
+				c.SetFromPodAnnotations()
+
+				_, err := c.getSidecarContainer(getSidecarContainerOpts{})
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), annotations.KeySidecarGuaranteedQoS)
+				assert.Equal(t, DenialReasonMissingResourceLimits, GetDenialReason(err))
+			})
+		}
+	})
+
+	t.Run("startup CPU boost", testSuiteGenerator([]testCase{
+		{
+			name: "request raised to the boost",
+			annotations: map[string]string{
+				annotations.KeyCPURequest:             "250m",
+				annotations.KeyCPULimit:               "2",
+				annotations.KeySidecarStartupCPUBoost: "1",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Equal(t, "1", container.Resources.Requests.Cpu().String())
+				assert.Equal(t, "2", container.Resources.Limits.Cpu().String())
+			},
+		},
+		{
+			name: "boost equal to the limit",
+			annotations: map[string]string{
+				annotations.KeyCPURequest:             "250m",
+				annotations.KeyCPULimit:               "1",
+				annotations.KeySidecarStartupCPUBoost: "1000m",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Equal(t, "1", container.Resources.Requests.Cpu().String())
+			},
+		},
+		{
+			name: "request not changed without a boost",
+			annotations: map[string]string{
+				annotations.KeyCPURequest: "250m",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Equal(t, "250m", container.Resources.Requests.Cpu().String())
+			},
+		},
+	}))
+
+	t.Run("startup CPU boost errors", func(t *testing.T) {
+		testCases := map[string]map[string]string{
+			"invalid quantity": {
+				annotations.KeyCPURequest:             "250m",
+				annotations.KeySidecarStartupCPUBoost: "lots",
+			},
+			"no cpu request": {
+				annotations.KeySidecarStartupCPUBoost: "1",
+			},
+			"not larger than the request": {
+				annotations.KeyCPURequest:             "500m",
+				annotations.KeySidecarStartupCPUBoost: "500m",
+			},
+			"larger than the limit": {
+				annotations.KeyCPURequest:             "250m",
+				annotations.KeyCPULimit:               "500m",
+				annotations.KeySidecarStartupCPUBoost: "1",
+			},
+		}
+
+		for name, an := range testCases {
+			t.Run(name, func(t *testing.T) {
+				c := NewSidecarConfig(&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: an,
+					},
+				})
+				c.SetFromPodAnnotations()
+
+				_, err := c.getSidecarContainer(getSidecarContainerOpts{})
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), annotations.KeySidecarStartupCPUBoost)
+				assert.Equal(t, DenialReasonInvalidAnnotation, GetDenialReason(err))
+			})
+		}
+	})
+
+	t.Run("GOMAXPROCS", testSuiteGenerator([]testCase{
+		{
+			name:        "not set without a cpu limit",
+			annotations: map[string]string{},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				for _, env := range container.Env {
+					assert.NotEqual(t, "GOMAXPROCS", env.Name)
+				}
+			},
+		},
+		{
+			name: "computed from a whole cpu limit",
+			annotations: map[string]string{
+				annotations.KeyCPULimit: "2",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Contains(t, container.Env, corev1.EnvVar{Name: "GOMAXPROCS", Value: "2"})
+			},
+		},
+		{
+			name: "computed from a fractional cpu limit",
+			annotations: map[string]string{
+				annotations.KeyCPULimit: "1500m",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Contains(t, container.Env, corev1.EnvVar{Name: "GOMAXPROCS", Value: "2"})
+			},
+		},
+		{
+			name: "computed from a cpu limit below one core",
+			annotations: map[string]string{
+				annotations.KeyCPULimit: "100m",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Contains(t, container.Env, corev1.EnvVar{Name: "GOMAXPROCS", Value: "1"})
+			},
+		},
+		{
+			name: "explicit annotation",
+			annotations: map[string]string{
+				annotations.KeyCPULimit:          "2",
+				annotations.KeySidecarGOMAXPROCS: "4",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Contains(t, container.Env, corev1.EnvVar{Name: "GOMAXPROCS", Value: "4"})
+			},
+		},
+		{
+			name: "set in env annotation",
+			annotations: map[string]string{
+				annotations.KeyCPULimit: "2",
+				annotations.KeyEnv:      "GOMAXPROCS=3",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				found := 0
+				for _, env := range container.Env {
+					if env.Name == "GOMAXPROCS" {
+						found++
+						assert.Equal(t, "3", env.Value)
+					}
+				}
+				assert.Equal(t, 1, found)
+			},
+		},
+	}))
+
+	t.Run("invalid GOMAXPROCS", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					annotations.KeySidecarGOMAXPROCS: "0",
+				},
+			},
+		})
+		c.SetFromPodAnnotations()
+
+		_, err := c.getSidecarContainer(getSidecarContainerOpts{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), annotations.KeySidecarGOMAXPROCS)
+	})
+
+	t.Run("metrics", testSuiteGenerator([]testCase{
+		{
+			name:        "enabled by default",
+			annotations: map[string]string{},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				args := strings.Join(container.Args, " ")
+				assert.Contains(t, args, "--enable-metrics --metrics-port 9090")
+				assert.NotContains(t, args, "--enable-metrics=false")
+				assert.Contains(t, container.Ports, corev1.ContainerPort{Name: "dapr-metrics", ContainerPort: 9090})
+			},
+		},
+		{
+			name: "disabled with annotation",
+			annotations: map[string]string{
+				annotations.KeyEnableMetrics: "false",
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Contains(t, container.Args, "--enable-metrics=false")
+				assert.NotContains(t, container.Args, "--metrics-port")
+				for _, p := range container.Ports {
+					assert.NotEqual(t, "dapr-metrics", p.Name)
+				}
+			},
+		},
+		{
+			name: "disabled in configuration",
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.EnableMetrics = false
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.Contains(t, container.Args, "--enable-metrics=false")
+				assert.Len(t, container.Ports, 3)
+			},
+		},
+		{
+			name: "annotation overrides the configuration",
+			annotations: map[string]string{
+				annotations.KeyEnableMetrics: "true",
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.EnableMetrics = false
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.NotContains(t, container.Args, "--enable-metrics=false")
+				assert.Len(t, container.Ports, 4)
+			},
+		},
+	}))
+
+	t.Run("runtime class adjustments", testSuiteGenerator([]testCase{
+		{
+			name:        "no adjustments without a runtime class",
+			annotations: map[string]string{},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.ReadOnlyRootFilesystem = true
+				c.RuntimeClassAdjustments = map[string]corev1.SecurityContext{
+					"gvisor": {ReadOnlyRootFilesystem: ptr.Of(false)},
+				}
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.True(t, *container.SecurityContext.ReadOnlyRootFilesystem)
+			},
+		},
+		{
+			name:        "gVisor pod gets adjusted security context",
+			annotations: map[string]string{},
+			podModifierFn: func(pod *corev1.Pod) {
+				pod.Spec.RuntimeClassName = ptr.Of("gvisor")
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.RunAsNonRoot = true
+				c.ReadOnlyRootFilesystem = true
+				c.RuntimeClassAdjustments = map[string]corev1.SecurityContext{
+					"gvisor": {
+						ReadOnlyRootFilesystem: ptr.Of(false),
+						Capabilities:           &corev1.Capabilities{Drop: []corev1.Capability{"NET_RAW"}},
+					},
+				}
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.False(t, *container.SecurityContext.ReadOnlyRootFilesystem)
+				assert.Equal(t, []corev1.Capability{"NET_RAW"}, container.SecurityContext.Capabilities.Drop)
+				// Fields not set in the adjustment are unchanged
+				assert.True(t, *container.SecurityContext.RunAsNonRoot)
+				assert.False(t, *container.SecurityContext.AllowPrivilegeEscalation)
+			},
+		},
+		{
+			name:        "runtime class without adjustments",
+			annotations: map[string]string{},
+			podModifierFn: func(pod *corev1.Pod) {
+				pod.Spec.RuntimeClassName = ptr.Of("kata")
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.ReadOnlyRootFilesystem = true
+				c.RuntimeClassAdjustments = map[string]corev1.SecurityContext{
+					"gvisor": {ReadOnlyRootFilesystem: ptr.Of(false)},
+				}
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				assert.True(t, *container.SecurityContext.ReadOnlyRootFilesystem)
+			},
+		},
+	}))
+
 	t.Run("sentry address", testSuiteGenerator([]testCase{
 		{
 			name: "omitted if empty",
@@ -1100,6 +2278,85 @@ func TestGetSidecarContainer(t *testing.T) {
 		},
 	}))
 
+	t.Run("control plane addresses overridden in annotations", testSuiteGenerator([]testCase{
+		{
+			name: "sentry address",
+			annotations: map[string]string{
+				annotations.KeySentryAddress: "sentry.custom:4000",
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.SentryAddress = "sentry:50000"
+				c.OperatorAddress = "controlplane:9000"
+				c.PlacementAddress = "placement:50005"
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				args := strings.Join(container.Args, " ")
+				assert.Contains(t, args, "--sentry-address sentry.custom:4000")
+				assert.Contains(t, args, "--control-plane-address controlplane:9000")
+				assert.Contains(t, args, "--placement-host-address placement:50005")
+			},
+		},
+		{
+			name: "operator address",
+			annotations: map[string]string{
+				annotations.KeyOperatorAddress: "operator.custom:4001",
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.SentryAddress = "sentry:50000"
+				c.OperatorAddress = "controlplane:9000"
+				c.PlacementAddress = "placement:50005"
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				args := strings.Join(container.Args, " ")
+				assert.Contains(t, args, "--sentry-address sentry:50000")
+				assert.Contains(t, args, "--control-plane-address operator.custom:4001")
+				assert.Contains(t, args, "--placement-host-address placement:50005")
+			},
+		},
+		{
+			name: "placement address",
+			annotations: map[string]string{
+				annotations.KeyPlacementHostAddresses: "placement-0.custom:4002,placement-1.custom:4002",
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.SentryAddress = "sentry:50000"
+				c.OperatorAddress = "controlplane:9000"
+				c.PlacementAddress = "placement:50005"
+			},
+			assertFn: func(t *testing.T, container *corev1.Container) {
+				args := strings.Join(container.Args, " ")
+				assert.Contains(t, args, "--sentry-address sentry:50000")
+				assert.Contains(t, args, "--control-plane-address controlplane:9000")
+				assert.Contains(t, args, "--placement-host-address placement-0.custom:4002,placement-1.custom:4002")
+			},
+		},
+	}))
+
+	t.Run("control plane addresses with invalid value", func(t *testing.T) {
+		testCases := map[string][]string{
+			annotations.KeySentryAddress:          {"sentry", "sentry:0", ":4000", "sentry-0:4000,sentry-1:4000"},
+			annotations.KeyOperatorAddress:        {"operator", "operator:99999", "operator:http"},
+			annotations.KeyPlacementHostAddresses: {"placement", "placement:50005,placement-1"},
+		}
+
+		for key, values := range testCases {
+			for _, val := range values {
+				c := NewSidecarConfig(&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							key: val,
+						},
+					},
+				})
+				c.SetFromPodAnnotations()
+
+				_, err := c.getSidecarContainer(getSidecarContainerOpts{})
+				require.Error(t, err, val)
+				assert.Contains(t, err.Error(), key, val)
+			}
+		}
+	})
+
 	t.Run("operator address", testSuiteGenerator([]testCase{
 		{
 			name: "omitted if empty",
@@ -0,0 +1,33 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"fmt"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+)
+
+// validateControlPlaneAddressAnnotations returns an error if the pod sets the address of the sentry or operator services and the injector doesn't allow it.
+func (c *SidecarConfig) validateControlPlaneAddressAnnotations() error {
+	if c.AllowControlPlaneAddresses {
+		return nil
+	}
+	for _, key := range []string{annotations.KeySentryAddress, annotations.KeyOperatorAddress} {
+		if c.pod.Annotations[key] != "" {
+			return fmt.Errorf("annotation %s is not allowed: the injector is configured to not let pods override the control plane addresses", key)
+		}
+	}
+	return nil
+}
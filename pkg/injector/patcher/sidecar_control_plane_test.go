@@ -0,0 +1,67 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+)
+
+func TestControlPlaneAddressAnnotations(t *testing.T) {
+	getPatch := func(allow bool, an map[string]string) error {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		c.AllowControlPlaneAddresses = allow
+		c.SetFromPodAnnotations()
+
+		_, err := c.GetPatch()
+		return err
+	}
+
+	for _, key := range []string{annotations.KeySentryAddress, annotations.KeyOperatorAddress} {
+		t.Run(key+" is denied by default", func(t *testing.T) {
+			err := getPatch(false, map[string]string{key: "custom:4000"})
+			require.Error(t, err)
+			assert.Equal(t, DenialReasonInvalidAnnotation, GetDenialReason(err))
+			assert.Contains(t, err.Error(), key)
+		})
+
+		t.Run(key+" is accepted if allowed", func(t *testing.T) {
+			require.NoError(t, getPatch(true, map[string]string{key: "custom:4000"}))
+		})
+	}
+}
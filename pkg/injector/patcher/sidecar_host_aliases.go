@@ -0,0 +1,81 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// getHostAliasesPatchOps returns the patch operations that merge SidecarHostAliases into the pod's host aliases, so the sidecar can resolve internal hostnames.
+// Host aliases are shared by all containers in the pod.
+// Hostnames that the pod already resolves are not added again, even if they map to a different IP, so the values set on the pod are preserved.
+func (c *SidecarConfig) getHostAliasesPatchOps() jsonpatch.Patch {
+	if len(c.SidecarHostAliases) == 0 {
+		return nil
+	}
+
+	// Copy the pod's host aliases, so they can be modified
+	merged := make([]corev1.HostAlias, len(c.pod.Spec.HostAliases))
+	existing := map[string]struct{}{}
+	for i, alias := range c.pod.Spec.HostAliases {
+		merged[i] = corev1.HostAlias{
+			IP:        alias.IP,
+			Hostnames: append([]string(nil), alias.Hostnames...),
+		}
+		for _, h := range alias.Hostnames {
+			existing[h] = struct{}{}
+		}
+	}
+
+	changed := false
+	for _, alias := range c.SidecarHostAliases {
+		hostnames := make([]string, 0, len(alias.Hostnames))
+		for _, h := range alias.Hostnames {
+			if _, ok := existing[h]; ok {
+				continue
+			}
+			existing[h] = struct{}{}
+			hostnames = append(hostnames, h)
+		}
+		if len(hostnames) == 0 {
+			continue
+		}
+		changed = true
+
+		// Add the hostnames to the alias with the same IP if there's one, or add a new alias
+		found := false
+		for i := range merged {
+			if merged[i].IP == alias.IP {
+				merged[i].Hostnames = append(merged[i].Hostnames, hostnames...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, corev1.HostAlias{
+				IP:        alias.IP,
+				Hostnames: hostnames,
+			})
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	// "add" replaces the list if the pod already has host aliases
+	return jsonpatch.Patch{
+		NewPatchOperation("add", PatchPathHostAliases, merged),
+	}
+}
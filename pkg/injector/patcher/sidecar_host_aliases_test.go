@@ -0,0 +1,106 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetHostAliasesPatchOps(t *testing.T) {
+	sidecarAliases := []corev1.HostAlias{
+		{IP: "10.0.0.10", Hostnames: []string{"vault.internal", "kafka.internal"}},
+		{IP: "10.0.0.20", Hostnames: []string{"redis.internal"}},
+	}
+
+	getHostAliases := func(t *testing.T, podAliases []corev1.HostAlias, aliases []corev1.HostAlias) []corev1.HostAlias {
+		pod := &corev1.Pod{
+			Spec: corev1.PodSpec{
+				HostAliases: podAliases,
+			},
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarHostAliases = aliases
+
+		patch := c.getHostAliasesPatchOps()
+		if len(patch) == 0 {
+			return pod.Spec.HostAliases
+		}
+		newPod, err := PatchPod(pod, patch)
+		require.NoError(t, err)
+		return newPod.Spec.HostAliases
+	}
+
+	t.Run("no aliases", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{})
+		assert.Empty(t, c.getHostAliasesPatchOps())
+	})
+
+	t.Run("pod without aliases", func(t *testing.T) {
+		aliases := getHostAliases(t, nil, sidecarAliases)
+		assert.Equal(t, sidecarAliases, aliases)
+	})
+
+	t.Run("merged with the pod's aliases", func(t *testing.T) {
+		aliases := getHostAliases(t, []corev1.HostAlias{
+			{IP: "192.168.1.1", Hostnames: []string{"legacy.internal"}},
+			{IP: "10.0.0.20", Hostnames: []string{"cache.internal"}},
+		}, sidecarAliases)
+		assert.Equal(t, []corev1.HostAlias{
+			{IP: "192.168.1.1", Hostnames: []string{"legacy.internal"}},
+			{IP: "10.0.0.20", Hostnames: []string{"cache.internal", "redis.internal"}},
+			{IP: "10.0.0.10", Hostnames: []string{"vault.internal", "kafka.internal"}},
+		}, aliases)
+	})
+
+	t.Run("existing hostnames are not duplicated", func(t *testing.T) {
+		aliases := getHostAliases(t, []corev1.HostAlias{
+			{IP: "10.0.0.10", Hostnames: []string{"vault.internal"}},
+			// The pod's value is preserved even if it maps to a different IP
+			{IP: "10.0.0.99", Hostnames: []string{"kafka.internal"}},
+		}, sidecarAliases)
+		assert.Equal(t, []corev1.HostAlias{
+			{IP: "10.0.0.10", Hostnames: []string{"vault.internal"}},
+			{IP: "10.0.0.99", Hostnames: []string{"kafka.internal"}},
+			{IP: "10.0.0.20", Hostnames: []string{"redis.internal"}},
+		}, aliases)
+	})
+
+	t.Run("no patch if all hostnames are already present", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{
+			Spec: corev1.PodSpec{
+				HostAliases: sidecarAliases,
+			},
+		})
+		c.SidecarHostAliases = sidecarAliases
+		assert.Empty(t, c.getHostAliasesPatchOps())
+	})
+
+	t.Run("pod's aliases are not modified", func(t *testing.T) {
+		podAliases := []corev1.HostAlias{
+			{IP: "10.0.0.20", Hostnames: []string{"cache.internal"}},
+		}
+		c := NewSidecarConfig(&corev1.Pod{
+			Spec: corev1.PodSpec{
+				HostAliases: podAliases,
+			},
+		})
+		c.SidecarHostAliases = sidecarAliases
+		require.Len(t, c.getHostAliasesPatchOps(), 1)
+		assert.Equal(t, []string{"cache.internal"}, podAliases[0].Hostnames)
+	})
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+)
+
+// IDRange is an inclusive range of user or group IDs.
+type IDRange struct {
+	Min int64
+	Max int64
+}
+
+// DefaultIDRange is the range of IDs allowed when no range is configured.
+var DefaultIDRange = IDRange{Min: 0, Max: math.MaxInt32}
+
+// ParseIDRange parses a range of IDs in the format "min-max".
+// An empty string returns DefaultIDRange.
+func ParseIDRange(val string) (IDRange, error) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return DefaultIDRange, nil
+	}
+
+	minStr, maxStr, ok := strings.Cut(val, "-")
+	if !ok {
+		return IDRange{}, fmt.Errorf("ID range '%s' is not in the format min-max", val)
+	}
+	minID, err := strconv.ParseInt(strings.TrimSpace(minStr), 10, 64)
+	if err != nil {
+		return IDRange{}, fmt.Errorf("ID range '%s' has an invalid minimum: %w", val, err)
+	}
+	maxID, err := strconv.ParseInt(strings.TrimSpace(maxStr), 10, 64)
+	if err != nil {
+		return IDRange{}, fmt.Errorf("ID range '%s' has an invalid maximum: %w", val, err)
+	}
+
+	r := IDRange{Min: minID, Max: maxID}
+	if !DefaultIDRange.Contains(r.Min) || !DefaultIDRange.Contains(r.Max) {
+		return IDRange{}, fmt.Errorf("ID range '%s' must be within %s", val, DefaultIDRange)
+	}
+	if r.Min > r.Max {
+		return IDRange{}, fmt.Errorf("ID range '%s' has a minimum that is greater than the maximum", val)
+	}
+	return r, nil
+}
+
+// Contains returns true if the ID is within the range.
+func (r IDRange) Contains(id int64) bool {
+	return id >= r.Min && id <= r.Max
+}
+
+// String implements fmt.Stringer.
+func (r IDRange) String() string {
+	return strconv.FormatInt(r.Min, 10) + "-" + strconv.FormatInt(r.Max, 10)
+}
+
+// validateSidecarIDs returns an error if any of the user or group IDs for the sidecar is outside of the allowed range.
+func (c *SidecarConfig) validateSidecarIDs() error {
+	allowed := c.SidecarAllowedIDRange
+	if allowed == (IDRange{}) {
+		allowed = DefaultIDRange
+	}
+
+	ids := []struct {
+		key string
+		val *int64
+	}{
+		{annotations.KeySidecarRunAsUser, c.SidecarRunAsUser},
+		{annotations.KeySidecarRunAsGroup, c.SidecarRunAsGroup},
+		{annotations.KeySidecarFSGroup, c.SidecarFSGroup},
+	}
+	for _, id := range ids {
+		if id.val != nil && !allowed.Contains(*id.val) {
+			return fmt.Errorf("value %d for %s is outside of the allowed range %s", *id.val, id.key, allowed)
+		}
+	}
+	return nil
+}
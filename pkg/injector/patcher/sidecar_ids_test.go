@@ -0,0 +1,172 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+	"github.com/dapr/kit/ptr"
+)
+
+func TestParseIDRange(t *testing.T) {
+	testCases := []struct {
+		input  string
+		expect IDRange
+		expErr bool
+	}{
+		{"", DefaultIDRange, false},
+		{"1000-2000", IDRange{Min: 1000, Max: 2000}, false},
+		{" 1000 - 1000 ", IDRange{Min: 1000, Max: 1000}, false},
+		{"1000", IDRange{}, true},
+		{"a-2000", IDRange{}, true},
+		{"1000-b", IDRange{}, true},
+		{"2000-1000", IDRange{}, true},
+		{"0-4294967296", IDRange{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			r, err := ParseIDRange(tc.input)
+			if tc.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, r)
+		})
+	}
+}
+
+func TestSidecarIDs(t *testing.T) {
+	getPod := func(an map[string]string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "app", Image: "app:1.0"},
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		return pod
+	}
+
+	patchPod := func(t *testing.T, pod *corev1.Pod, modifierFn func(c *SidecarConfig)) (*corev1.Pod, error) {
+		t.Helper()
+
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		c.SidecarAllowedIDRange = IDRange{Min: 1000, Max: 2000}
+		if modifierFn != nil {
+			modifierFn(c)
+		}
+		c.SetFromPodAnnotations()
+
+		patch, err := c.GetPatch()
+		if err != nil {
+			return nil, err
+		}
+		newPod, err := PatchPod(pod, patch)
+		require.NoError(t, err)
+		return newPod, nil
+	}
+
+	t.Run("not set by default", func(t *testing.T) {
+		newPod, err := patchPod(t, getPod(nil), nil)
+		require.NoError(t, err)
+		require.Len(t, newPod.Spec.Containers, 2)
+		assert.Nil(t, newPod.Spec.Containers[1].SecurityContext.RunAsUser)
+		assert.Nil(t, newPod.Spec.Containers[1].SecurityContext.RunAsGroup)
+		assert.Nil(t, newPod.Spec.SecurityContext)
+	})
+
+	t.Run("defaults from configuration are applied", func(t *testing.T) {
+		newPod, err := patchPod(t, getPod(nil), func(c *SidecarConfig) {
+			c.SidecarRunAsUser = ptr.Of(int64(1000))
+			c.SidecarRunAsGroup = ptr.Of(int64(1001))
+			c.SidecarFSGroup = ptr.Of(int64(1002))
+		})
+		require.NoError(t, err)
+		require.Len(t, newPod.Spec.Containers, 2)
+		assert.Equal(t, int64(1000), *newPod.Spec.Containers[1].SecurityContext.RunAsUser)
+		assert.Equal(t, int64(1001), *newPod.Spec.Containers[1].SecurityContext.RunAsGroup)
+		require.NotNil(t, newPod.Spec.SecurityContext)
+		assert.Equal(t, int64(1002), *newPod.Spec.SecurityContext.FSGroup)
+	})
+
+	t.Run("annotations override the defaults", func(t *testing.T) {
+		pod := getPod(map[string]string{
+			annotations.KeySidecarRunAsUser:  "1500",
+			annotations.KeySidecarRunAsGroup: "1501",
+			annotations.KeySidecarFSGroup:    "1502",
+		})
+		newPod, err := patchPod(t, pod, func(c *SidecarConfig) {
+			c.SidecarRunAsUser = ptr.Of(int64(1000))
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1500), *newPod.Spec.Containers[1].SecurityContext.RunAsUser)
+		assert.Equal(t, int64(1501), *newPod.Spec.Containers[1].SecurityContext.RunAsGroup)
+		assert.Equal(t, int64(1502), *newPod.Spec.SecurityContext.FSGroup)
+	})
+
+	t.Run("fsGroup set on the pod is not changed", func(t *testing.T) {
+		pod := getPod(map[string]string{
+			annotations.KeySidecarFSGroup: "1502",
+		})
+		pod.Spec.SecurityContext = &corev1.PodSecurityContext{
+			FSGroup:    ptr.Of(int64(3000)),
+			RunAsGroup: ptr.Of(int64(3000)),
+		}
+		newPod, err := patchPod(t, pod, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3000), *newPod.Spec.SecurityContext.FSGroup)
+	})
+
+	t.Run("fsGroup is added to an existing pod security context", func(t *testing.T) {
+		pod := getPod(map[string]string{
+			annotations.KeySidecarFSGroup: "1502",
+		})
+		pod.Spec.SecurityContext = &corev1.PodSecurityContext{
+			RunAsGroup: ptr.Of(int64(3000)),
+		}
+		newPod, err := patchPod(t, pod, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1502), *newPod.Spec.SecurityContext.FSGroup)
+		assert.Equal(t, int64(3000), *newPod.Spec.SecurityContext.RunAsGroup)
+	})
+
+	t.Run("out of range values are denied", func(t *testing.T) {
+		for _, key := range []string{annotations.KeySidecarRunAsUser, annotations.KeySidecarRunAsGroup, annotations.KeySidecarFSGroup} {
+			_, err := patchPod(t, getPod(map[string]string{
+				key: "0",
+			}), nil)
+			require.Error(t, err, key)
+			assert.Contains(t, err.Error(), key)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"regexp"
+	"strings"
+)
+
+var imageDigestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// IsValidImageDigest returns true if the value is a SHA-256 digest of an image, such as "sha256:<64 hex characters>".
+func IsValidImageDigest(digest string) bool {
+	return imageDigestRegexp.MatchString(digest)
+}
+
+// ImageHasTag returns true if the image reference has a tag and isn't already pinned by digest.
+// The port of a registry, as in "localhost:5000/daprd", is not a tag.
+func ImageHasTag(image string) bool {
+	if strings.Contains(image, "@") {
+		return false
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	return strings.Contains(name, ":")
+}
+
+// getPinnedSidecarImage returns the sidecar image pinned by the digest in ImageDigests, if the image is in the map.
+// The tag is preserved for readability, so "daprio/daprd:1.12.0" becomes "daprio/daprd:1.12.0@sha256:...", and the container runtime pulls the image by digest.
+// Images that are not in the map, including the ones already pinned by digest, are returned as-is.
+func (c *SidecarConfig) getPinnedSidecarImage() string {
+	image := strings.TrimSpace(c.SidecarImage)
+	digest, ok := c.ImageDigests[image]
+	if !ok {
+		return c.SidecarImage
+	}
+	return image + "@" + digest
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	corev1 "k8s.io/api/core/v1"
+
+	injectorConsts "github.com/dapr/dapr/pkg/injector/consts"
+)
+
+// Settings for the startup probe of native sidecars, which allow daprd up to 1 minute to start.
+const (
+	nativeSidecarStartupProbePeriodSeconds    = 1
+	nativeSidecarStartupProbeFailureThreshold = 60
+)
+
+// useNativeSidecar returns true if the sidecar is injected as a native sidecar, i.e. an init container that keeps running alongside the app.
+// Native sidecars are used only if enabled in the injector, because they require Kubernetes 1.29 or newer.
+// Unless set with the annotation, pods that are not restarted when their containers exit, such as the pods of Jobs, use native sidecars, as otherwise the pod never completes while daprd is running.
+func (c *SidecarConfig) useNativeSidecar() bool {
+	if !c.EnableNativeSidecars {
+		return false
+	}
+	if c.NativeSidecar != nil {
+		return *c.NativeSidecar
+	}
+	switch c.pod.Spec.RestartPolicy {
+	case corev1.RestartPolicyNever, corev1.RestartPolicyOnFailure:
+		return true
+	default:
+		return false
+	}
+}
+
+// getNativeSidecarPatchOps returns the patch operations that add the sidecar container as a native sidecar.
+// The sidecar is added as the first init container, so the other init containers can use Dapr too.
+// The restart policy of the container is set with a separate operation, as the field is not part of the Kubernetes API version the injector is built with; native sidecars require Kubernetes 1.29 or newer.
+func (c *SidecarConfig) getNativeSidecarPatchOps(sidecarContainer *corev1.Container) jsonpatch.Patch {
+	patchOps := make(jsonpatch.Patch, 0, 3)
+	if len(c.pod.Spec.InitContainers) == 0 {
+		// Set to empty to support add operations individually
+		patchOps = append(patchOps,
+			NewPatchOperation("add", PatchPathInitContainers, []corev1.Container{}),
+		)
+	}
+	patchOps = append(patchOps,
+		NewPatchOperation("add", PatchPathInitContainers+"/0", sidecarContainer),
+		NewPatchOperation("add", PatchPathInitContainers+"/0/restartPolicy", string(corev1.RestartPolicyAlways)),
+	)
+	return patchOps
+}
+
+// getNativeSidecarStartupProbe returns the startup probe for the sidecar when it's injected as a native sidecar, or nil otherwise.
+// Kubernetes starts the other init containers and the app containers only after the startup probe of a native sidecar succeeds, so they can use Dapr as soon as they start.
+// The probe uses the outbound healthz endpoint, which doesn't include the app's health: the app isn't running yet when the probe is performed, and waiting for it would block the pod forever.
+func (c *SidecarConfig) getNativeSidecarStartupProbe() *corev1.Probe {
+	if !c.NativeSidecarStartupProbe || !c.useNativeSidecar() {
+		return nil
+	}
+	return &corev1.Probe{
+		ProbeHandler:        getProbeHTTPHandler(c.SidecarPublicPort, injectorConsts.APIVersionV1, injectorConsts.SidecarHealthzOutboundPath),
+		InitialDelaySeconds: c.SidecarReadinessProbeDelaySeconds,
+		TimeoutSeconds:      c.SidecarReadinessProbeTimeoutSeconds,
+		PeriodSeconds:       nativeSidecarStartupProbePeriodSeconds,
+		FailureThreshold:    nativeSidecarStartupProbeFailureThreshold,
+	}
+}
@@ -14,18 +14,29 @@ limitations under the License.
 package patcher
 
 import (
+	"fmt"
+	"path"
+	"sort"
 	"strconv"
+	"strings"
 
 	jsonpatch "github.com/evanphx/json-patch/v5"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 
+	"github.com/dapr/dapr/pkg/injector/annotations"
 	injectorConsts "github.com/dapr/dapr/pkg/injector/consts"
 	"github.com/dapr/dapr/pkg/validation"
 )
 
 // NeedsPatching returns true if patching is needed.
 func (c *SidecarConfig) NeedsPatching() bool {
-	return c.Enabled && !c.podContainsSidecarContainer()
+	return c.Enabled &&
+		!c.podContainsSidecarContainer() &&
+		!c.podMatchesSkipGenerateName() &&
+		c.podHasAllowedOwnerKind() &&
+		!c.podIsSkippedWindowsPod() &&
+		c.podContainerCountInRange()
 }
 
 // GetPatch returns the patch to apply to a Pod to inject the Dapr sidecar
@@ -35,20 +46,83 @@ func (c *SidecarConfig) GetPatch() (patchOps jsonpatch.Patch, err error) {
 		return nil, nil
 	}
 
+	// Deny pods without an explicit app ID if required, rather than falling back to the pod name, which is often not stable
+	if c.RequireAppID && c.AppID == "" {
+		return nil, NewDenialError(DenialReasonMissingAppID, fmt.Errorf("annotation %s is required: the injector is configured to not derive the app ID from the name of the pod", annotations.KeyAppID))
+	}
+
 	// Validate AppID
 	err = validation.ValidateKubernetesAppID(c.GetAppID())
 	if err != nil {
-		return nil, err
+		return nil, NewDenialError(DenialReasonInvalidAppID, err)
+	}
+
+	// Validate the app port
+	err = c.validateAppPort()
+	if err != nil {
+		return nil, NewDenialError(DenialReasonInvalidAppPort, err)
+	}
+
+	// Validate the runtime class to set on the pod, if any
+	err = c.validatePodRuntimeClass()
+	if err != nil {
+		return nil, NewDenialError(DenialReasonInvalidAnnotation, err)
+	}
+
+	// Deny annotations that point the sidecar at a different control plane, unless the injector allows them
+	err = c.validateControlPlaneAddressAnnotations()
+	if err != nil {
+		return nil, NewDenialError(DenialReasonInvalidAnnotation, err)
+	}
+
+	// Deny the pod if the sidecar image couldn't be resolved, rather than injecting a broken container
+	if strings.TrimSpace(c.SidecarImage) == "" {
+		return nil, NewDenialError(DenialReasonMissingSidecarImage, fmt.Errorf("unable to determine the sidecar image: the injector has no default image configured and annotation %s is not set", annotations.KeySidecarImage))
+	}
+	// Pin the image by digest, if the tag is mapped to one
+	c.SidecarImage = c.getPinnedSidecarImage()
+
+	// Apps that don't use actors can opt out of connecting to the placement service, even if the address is set in the annotations
+	if c.DisablePlacement {
+		c.PlacementAddress = ""
+	}
+
+	// Deny plaintext protocols for the app channel, if required
+	err = c.checkAppProtocolIsSecure()
+	if err != nil {
+		return nil, NewDenialError(DenialReasonInsecureAppProtocol, err)
+	}
+
+	// Check that the source of the custom trust anchors exists, if enabled
+	err = c.checkTrustAnchorsSource()
+	if err != nil {
+		return nil, NewDenialError(DenialReasonResourceNotFound, err)
+	}
+
+	// Check that no container in the pod declares a port used by the sidecar, remapping the sidecar ports if enabled
+	err = c.resolvePortConflicts()
+	if err != nil {
+		return nil, NewDenialError(DenialReasonPortConflict, err)
 	}
 
 	patchOps = jsonpatch.Patch{}
 
 	// Get the list of app and component containers
 	appContainers, componentContainers := c.splitContainers()
+	appContainers, err = c.selectAppContainers(appContainers)
 	if err != nil {
-		return nil, err
+		return nil, NewDenialError(DenialReasonInvalidAppContainer, err)
 	}
 
+	// Compute the max concurrency from the CPU requested by the app containers, if configured
+	err = c.setAppMaxConcurrencyFromCPU(appContainers)
+	if err != nil {
+		return nil, NewDenialError(DenialReasonInvalidAnnotation, err)
+	}
+
+	// Derive the memory limit from the memory limit of the app containers, if configured
+	c.setMemoryLimitFromApp(appContainers)
+
 	// Get volume mounts and add the UDS volume mount if needed
 	volumeMounts := c.getVolumeMounts()
 	volumes := make([]corev1.Volume, 0, 2)
@@ -66,12 +140,29 @@ func (c *SidecarConfig) GetPatch() (patchOps jsonpatch.Patch, err error) {
 		containerVolumeMounts = append(containerVolumeMounts, appMount)
 	}
 
+	// Mount the additional CA certificates if needed
+	if len(c.AdditionalCASecrets) > 0 {
+		volume, daprdMount := c.getAdditionalCAVolumeMount()
+		volumes = append(volumes, volume)
+		volumeMounts = append(volumeMounts, daprdMount)
+	}
+
+	// Mount a sized in-memory volume as shared memory if needed
+	if c.SidecarSharedMemorySize != "" {
+		volume, daprdMount, err := c.getSharedMemoryVolumeMount()
+		if err != nil {
+			return nil, NewDenialError(DenialReasonInvalidAnnotation, err)
+		}
+		volumes = append(volumes, volume)
+		volumeMounts = append(volumeMounts, daprdMount)
+	}
+
 	// Pluggable components
 	var injectedComponentContainers []corev1.Container
 	if c.GetInjectedComponentContainers != nil && c.InjectPluggableComponents {
 		injectedComponentContainers, err = c.GetInjectedComponentContainers(c.GetAppID(), c.Namespace)
 		if err != nil {
-			return nil, err
+			return nil, NewDenialError(DenialReasonComponentsNotAvailable, err)
 		}
 	}
 	componentPatchOps, componentsSocketVolumeMount := c.componentsPatchOps(componentContainers, injectedComponentContainers)
@@ -97,7 +188,21 @@ func (c *SidecarConfig) GetPatch() (patchOps jsonpatch.Patch, err error) {
 		VolumeMounts:                 volumeMounts,
 	})
 	if err != nil {
-		return nil, err
+		// Most errors are caused by invalid annotations; errors with a more specific reason keep it
+		return nil, NewDenialError(DenialReasonInvalidAnnotation, err)
+	}
+
+	// Mount a writable /tmp if the root filesystem of the sidecar is read-only
+	if needsTmpVolume(sidecarContainer) {
+		volume, daprdMount := c.getTmpVolumeMount()
+		volumes = append(volumes, volume)
+		sidecarContainer.VolumeMounts = append(sidecarContainer.VolumeMounts, daprdMount)
+	}
+
+	// Check that the pod ends up in the required QoS class, if configured
+	err = c.checkPodQOSClass(append([]corev1.Container{*sidecarContainer}, injectedComponentContainers...))
+	if err != nil {
+		return nil, NewDenialError(DenialReasonUnexpectedQoSClass, err)
 	}
 
 	// Create the list of patch operations
@@ -113,15 +218,29 @@ func (c *SidecarConfig) GetPatch() (patchOps jsonpatch.Patch, err error) {
 			NewPatchOperation("add", PatchPathLabels, map[string]string{}),
 		)
 	}
+	if len(c.pod.Annotations) == 0 {
+		// Set to empty to support add operations individually
+		patchOps = append(patchOps,
+			NewPatchOperation("add", PatchPathAnnotations, map[string]string{}),
+		)
+	}
 
 	// Add all volumes
 	if len(volumes) > 0 {
 		patchOps = append(patchOps, c.getVolumesPatchOperations(volumes, PatchPathVolumes)...)
 	}
 
+	// Add the sidecar container, as a native sidecar if needed
+	if c.useNativeSidecar() {
+		patchOps = append(patchOps, c.getNativeSidecarPatchOps(sidecarContainer)...)
+	} else {
+		patchOps = append(patchOps,
+			NewPatchOperation("add", PatchPathContainers+"/-", sidecarContainer),
+		)
+	}
+
 	// Other patch operations
 	patchOps = append(patchOps,
-		NewPatchOperation("add", PatchPathContainers+"/-", sidecarContainer),
 		NewPatchOperation("add", PatchPathLabels+"/dapr.io~1sidecar-injected", "true"),
 		NewPatchOperation("add", PatchPathLabels+"/dapr.io~1app-id", c.GetAppID()),
 		NewPatchOperation("add", PatchPathLabels+"/dapr.io~1metrics-enabled", strconv.FormatBool(c.EnableMetrics)),
@@ -135,33 +254,229 @@ func (c *SidecarConfig) GetPatch() (patchOps jsonpatch.Patch, err error) {
 		)
 	}
 	patchOps = append(patchOps, componentPatchOps...)
+	patchOps = append(patchOps, c.getPodSecurityContextPatchOps()...)
+	patchOps = append(patchOps, c.getHostAliasesPatchOps()...)
+	patchOps = append(patchOps, c.getTopologySpreadPatchOps()...)
+	patchOps = append(patchOps, c.getRuntimeClassPatchOps()...)
+	patchOps = append(patchOps,
+		NewPatchOperation("add", PatchPathAnnotations+"/"+EscapeJSONPointer(injectorConsts.SidecarImageUsedAnnotation), sidecarContainer.Image),
+	)
+	if c.InjectorVersion != "" {
+		patchOps = append(patchOps,
+			NewPatchOperation("add", PatchPathAnnotations+"/"+EscapeJSONPointer(injectorConsts.InjectorVersionAnnotation), c.InjectorVersion),
+		)
+	}
+	patchOps = append(patchOps, c.getStartupCPUBoostPatchOps()...)
+	patchOps = append(patchOps, c.getDefaultAnnotationsPatchOps()...)
+	patchOps = append(patchOps, c.getForcedAnnotationsPatchOps()...)
+	if c.AnnotateAddedResources {
+		patchOps = append(patchOps, c.getAddedResourcesPatchOps(append([]corev1.Container{*sidecarContainer}, injectedComponentContainers...))...)
+	}
 
 	return patchOps, nil
 }
 
+// getDefaultAnnotationsPatchOps returns the patch operations that add the default annotations to the pod.
+// Annotations that are already set on the pod, or that are forced, are not overwritten.
+func (c *SidecarConfig) getDefaultAnnotationsPatchOps() jsonpatch.Patch {
+	if len(c.DefaultPodAnnotations) == 0 {
+		return nil
+	}
+
+	// Sort the keys so the patch is deterministic
+	keys := make([]string, 0, len(c.DefaultPodAnnotations))
+	for k := range c.DefaultPodAnnotations {
+		if _, ok := c.pod.Annotations[k]; ok {
+			continue
+		}
+		if _, ok := c.ForcedAnnotations[k]; ok {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	patchOps := make(jsonpatch.Patch, len(keys))
+	for i, k := range keys {
+		patchOps[i] = NewPatchOperation("add", PatchPathAnnotations+"/"+EscapeJSONPointer(k), c.DefaultPodAnnotations[k])
+	}
+	return patchOps
+}
+
+// getForcedAnnotationsPatchOps returns the patch operations that set the forced annotations on the pod, so the pod reflects the values the sidecar is configured with.
+// Unlike the default annotations, values set on the pod are overwritten.
+func (c *SidecarConfig) getForcedAnnotationsPatchOps() jsonpatch.Patch {
+	if len(c.ForcedAnnotations) == 0 {
+		return nil
+	}
+
+	// Sort the keys so the patch is deterministic
+	keys := make([]string, 0, len(c.ForcedAnnotations))
+	for k, v := range c.ForcedAnnotations {
+		if existing, ok := c.pod.Annotations[k]; ok && existing == v {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	patchOps := make(jsonpatch.Patch, len(keys))
+	for i, k := range keys {
+		// "add" replaces the value if the annotation already exists
+		patchOps[i] = NewPatchOperation("add", PatchPathAnnotations+"/"+EscapeJSONPointer(k), c.ForcedAnnotations[k])
+	}
+	return patchOps
+}
+
+// getStartupCPUBoostPatchOps returns the patch operations that annotate the pod with the sidecar's regular CPU request, when a startup CPU boost is set.
+// The boosted request is set on the container, and the platform uses the annotation to scale it down once the sidecar has started.
+func (c *SidecarConfig) getStartupCPUBoostPatchOps() jsonpatch.Patch {
+	if c.SidecarStartupCPUBoost == "" {
+		return nil
+	}
+	// The request was validated when the container was created
+	request, err := resource.ParseQuantity(c.SidecarCPURequest)
+	if err != nil {
+		return nil
+	}
+	return jsonpatch.Patch{
+		NewPatchOperation("add", PatchPathAnnotations+"/"+EscapeJSONPointer(injectorConsts.SidecarSteadyCPUAnnotation), request.String()),
+	}
+}
+
+// getAddedResourcesPatchOps returns the patch operations that annotate the pod with the total resources requested by the containers added by the injector.
+// This can be used by cost-attribution tooling.
+func (c *SidecarConfig) getAddedResourcesPatchOps(added []corev1.Container) jsonpatch.Patch {
+	cpu := resource.Quantity{}
+	memory := resource.Quantity{}
+	for _, container := range added {
+		if q, ok := container.Resources.Requests[corev1.ResourceCPU]; ok {
+			cpu.Add(q)
+		}
+		if q, ok := container.Resources.Requests[corev1.ResourceMemory]; ok {
+			memory.Add(q)
+		}
+	}
+
+	if cpu.IsZero() && memory.IsZero() {
+		return nil
+	}
+
+	patchOps := make(jsonpatch.Patch, 0, 2)
+	if !cpu.IsZero() {
+		patchOps = append(patchOps,
+			NewPatchOperation("add", PatchPathAnnotations+"/"+EscapeJSONPointer(injectorConsts.SidecarAddedCPUAnnotation), cpu.String()),
+		)
+	}
+	if !memory.IsZero() {
+		patchOps = append(patchOps,
+			NewPatchOperation("add", PatchPathAnnotations+"/"+EscapeJSONPointer(injectorConsts.SidecarAddedMemoryAnnotation), memory.String()),
+		)
+	}
+	return patchOps
+}
+
 // podContainsSidecarContainer returns true if the pod contains a sidecar container (i.e. a container named "daprd").
+// Init containers are checked too, as that's where native sidecars are injected.
 func (c *SidecarConfig) podContainsSidecarContainer() bool {
 	for _, c := range c.pod.Spec.Containers {
 		if c.Name == injectorConsts.SidecarContainerName {
 			return true
 		}
 	}
+	for _, c := range c.pod.Spec.InitContainers {
+		if c.Name == injectorConsts.SidecarContainerName {
+			return true
+		}
+	}
+	return false
+}
+
+// podMatchesSkipGenerateName returns true if the pod's generateName matches any of the patterns in SkipGenerateNamePatterns.
+func (c *SidecarConfig) podMatchesSkipGenerateName() bool {
+	generateName := c.pod.GetGenerateName()
+	if generateName == "" {
+		return false
+	}
+	for _, p := range c.SkipGenerateNamePatterns {
+		// Patterns are validated when the injector is created, so errors can be ignored here
+		if ok, _ := path.Match(p, generateName); ok {
+			log.Debugf("Skipping injection for pod with generateName '%s' matching pattern '%s'", generateName, p)
+			return true
+		}
+	}
 	return false
 }
 
+// podHasAllowedOwnerKind returns true if the pod is owned by a controller of one of the kinds in OnlyInjectForOwnerKinds, or if the list is empty.
+func (c *SidecarConfig) podHasAllowedOwnerKind() bool {
+	if len(c.OnlyInjectForOwnerKinds) == 0 {
+		return true
+	}
+	kinds := c.getPodOwnerKinds()
+	for _, kind := range kinds {
+		for _, allowed := range c.OnlyInjectForOwnerKinds {
+			if strings.EqualFold(kind, allowed) {
+				return true
+			}
+		}
+	}
+	if len(kinds) == 0 {
+		log.Debugf("Skipping injection for pod without a controller: allowed owner kinds are %v", c.OnlyInjectForOwnerKinds)
+	} else {
+		log.Debugf("Skipping injection for pod owned by %v: allowed owner kinds are %v", kinds, c.OnlyInjectForOwnerKinds)
+	}
+	return false
+}
+
+// getPodOwnerKinds returns the kinds of the controllers that own the pod, walking its owner references.
+// Pods created by a Deployment are owned by a ReplicaSet, which is identified by the pod-template-hash label set by the Deployment controller: both ReplicaSet and Deployment are returned for those pods.
+func (c *SidecarConfig) getPodOwnerKinds() []string {
+	kinds := []string{}
+	for _, ref := range c.pod.OwnerReferences {
+		if ref.Controller == nil || !*ref.Controller {
+			continue
+		}
+		kinds = append(kinds, ref.Kind)
+		if ref.Kind == "ReplicaSet" && c.pod.Labels["pod-template-hash"] != "" {
+			kinds = append(kinds, "Deployment")
+		}
+	}
+	return kinds
+}
+
+// podContainerCountInRange returns true if the number of containers in the pod is within MinAppContainers and MaxAppContainers.
+// A value of 0 for either means no limit.
+func (c *SidecarConfig) podContainerCountInRange() bool {
+	count := len(c.pod.Spec.Containers)
+	if c.MinAppContainers > 0 && count < c.MinAppContainers {
+		log.Debugf("Skipping injection for pod with %d containers: minimum is %d", count, c.MinAppContainers)
+		return false
+	}
+	if c.MaxAppContainers > 0 && count > c.MaxAppContainers {
+		log.Debugf("Skipping injection for pod with %d containers: maximum is %d", count, c.MaxAppContainers)
+		return false
+	}
+	return true
+}
+
 // addDaprEnvVarsToContainers adds Dapr environment variables to all the containers in any Dapr-enabled pod.
 // The containers can be injected or user-defined.
 func (c *SidecarConfig) addDaprEnvVarsToContainers(containers map[int]corev1.Container, appProtocol string) jsonpatch.Patch {
 	envPatchOps := make(jsonpatch.Patch, 0, len(containers)*2)
-	envVars := []corev1.EnvVar{
-		{
-			Name:  injectorConsts.UserContainerDaprHTTPPortName,
-			Value: strconv.FormatInt(int64(c.SidecarHTTPPort), 10),
-		},
-		{
-			Name:  injectorConsts.UserContainerDaprGRPCPortName,
-			Value: strconv.FormatInt(int64(c.SidecarAPIGRPCPort), 10),
-		},
+	envVars := make([]corev1.EnvVar, 0, 3)
+	// The ports are exposed so SDKs can find the sidecar without hardcoding them
+	if c.InjectPortEnvIntoApp {
+		envVars = append(envVars,
+			corev1.EnvVar{
+				Name:  injectorConsts.UserContainerDaprHTTPPortName,
+				Value: strconv.FormatInt(int64(c.SidecarHTTPPort), 10),
+			},
+			corev1.EnvVar{
+				Name:  injectorConsts.UserContainerDaprGRPCPortName,
+				Value: strconv.FormatInt(int64(c.SidecarAPIGRPCPort), 10),
+			},
+		)
 	}
 	if appProtocol != "" {
 		envVars = append(envVars, corev1.EnvVar{
@@ -169,6 +484,9 @@ func (c *SidecarConfig) addDaprEnvVarsToContainers(containers map[int]corev1.Con
 			Value: appProtocol,
 		})
 	}
+	if len(envVars) == 0 {
+		return envPatchOps
+	}
 	for i, container := range containers {
 		patchOps := GetEnvPatchOperations(container.Env, envVars, i)
 		envPatchOps = append(envPatchOps, patchOps...)
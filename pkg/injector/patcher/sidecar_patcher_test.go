@@ -14,6 +14,9 @@ limitations under the License.
 package patcher
 
 import (
+	"encoding/json"
+	"errors"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -21,10 +24,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/dapr/dapr/pkg/injector/annotations"
 	injectorConsts "github.com/dapr/dapr/pkg/injector/consts"
+	"github.com/dapr/kit/ptr"
 )
 
 func TestAddDaprEnvVarsToContainers(t *testing.T) {
@@ -207,6 +212,22 @@ func TestPodNeedsPatching(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "false if daprd native sidecar already exists",
+			want: false,
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotations.KeyEnabled: "yes",
+					},
+				},
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{
+						{Name: "daprd"},
+					},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -221,6 +242,613 @@ func TestPodNeedsPatching(t *testing.T) {
 	}
 }
 
+func TestPodNeedsPatchingSkipGenerateName(t *testing.T) {
+	patterns := []string{"kube-*", "*-job-?????-"}
+
+	tests := []struct {
+		name         string
+		generateName string
+		want         bool
+	}{
+		{name: "no generateName", generateName: "", want: true},
+		{name: "non-matching generateName", generateName: "myapp-7d4b9c5f8-", want: true},
+		{name: "matching prefix pattern", generateName: "kube-proxy-", want: false},
+		{name: "matching wildcard pattern", generateName: "backup-job-12345-", want: false},
+		{name: "partially matching pattern", generateName: "backup-job-123-", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewSidecarConfig(&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: tt.generateName,
+					Annotations: map[string]string{
+						annotations.KeyEnabled: "true",
+					},
+				},
+			})
+			c.SkipGenerateNamePatterns = patterns
+			c.SetFromPodAnnotations()
+
+			assert.Equal(t, tt.want, c.NeedsPatching())
+		})
+	}
+}
+
+func TestPodNeedsPatchingOwnerKinds(t *testing.T) {
+	allowed := []string{"Deployment", "StatefulSet"}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		owners []metav1.OwnerReference
+		want   bool
+	}{
+		{
+			name: "bare pod",
+			want: false,
+		},
+		{
+			name:   "pod created by a Deployment",
+			labels: map[string]string{"pod-template-hash": "5d8f9c7b6"},
+			owners: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: "myapp-5d8f9c7b6", Controller: ptr.Of(true)},
+			},
+			want: true,
+		},
+		{
+			name: "pod created by a bare ReplicaSet",
+			owners: []metav1.OwnerReference{
+				{Kind: "ReplicaSet", Name: "myapp", Controller: ptr.Of(true)},
+			},
+			want: false,
+		},
+		{
+			name: "pod created by a StatefulSet",
+			owners: []metav1.OwnerReference{
+				{Kind: "StatefulSet", Name: "myapp", Controller: ptr.Of(true)},
+			},
+			want: true,
+		},
+		{
+			name: "pod created by a DaemonSet",
+			owners: []metav1.OwnerReference{
+				{Kind: "DaemonSet", Name: "myapp", Controller: ptr.Of(true)},
+			},
+			want: false,
+		},
+		{
+			name: "owner that is not a controller is ignored",
+			owners: []metav1.OwnerReference{
+				{Kind: "StatefulSet", Name: "myapp"},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewSidecarConfig(&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:          tt.labels,
+					OwnerReferences: tt.owners,
+					Annotations: map[string]string{
+						annotations.KeyEnabled: "true",
+					},
+				},
+			})
+			c.OnlyInjectForOwnerKinds = allowed
+			c.SetFromPodAnnotations()
+
+			assert.Equal(t, tt.want, c.NeedsPatching())
+		})
+	}
+
+	t.Run("all pods are injected by default", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+				},
+			},
+		})
+		c.SetFromPodAnnotations()
+
+		assert.True(t, c.NeedsPatching())
+	})
+}
+
+func TestPodNeedsPatchingContainerCount(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers int
+		min        int
+		max        int
+		want       bool
+	}{
+		{name: "no limits", containers: 1, want: true},
+		{name: "below minimum", containers: 1, min: 2, want: false},
+		{name: "at minimum", containers: 2, min: 2, want: true},
+		{name: "at maximum", containers: 3, max: 3, want: true},
+		{name: "above maximum", containers: 4, max: 3, want: false},
+		{name: "within range", containers: 2, min: 1, max: 3, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annotations.KeyEnabled: "true",
+					},
+				},
+			}
+			for i := 0; i < tt.containers; i++ {
+				pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+					Name: "container" + strconv.Itoa(i),
+				})
+			}
+			c := NewSidecarConfig(pod)
+			c.MinAppContainers = tt.min
+			c.MaxAppContainers = tt.max
+			c.SetFromPodAnnotations()
+
+			assert.Equal(t, tt.want, c.NeedsPatching())
+		})
+	}
+}
+
+func TestSidecarImageResolution(t *testing.T) {
+	getPatch := func(defaultImage string, an map[string]string, digests map[string]string) (*corev1.Pod, error) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myapp",
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = defaultImage
+		c.ImageDigests = digests
+		c.SetFromPodAnnotations()
+
+		patch, err := c.GetPatch()
+		if err != nil {
+			return nil, err
+		}
+		newPod, err := PatchPod(pod, patch)
+		require.NoError(t, err)
+		return newPod, nil
+	}
+
+	t.Run("denied when no image can be resolved", func(t *testing.T) {
+		_, err := getPatch("", nil, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unable to determine the sidecar image")
+	})
+
+	t.Run("denied when the image annotation is blank", func(t *testing.T) {
+		_, err := getPatch("", map[string]string{
+			annotations.KeySidecarImage: " ",
+		}, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("default image", func(t *testing.T) {
+		pod, err := getPatch("daprio/daprd:default", nil, nil)
+		require.NoError(t, err)
+		require.Len(t, pod.Spec.Containers, 1)
+		assert.Equal(t, "daprio/daprd:default", pod.Spec.Containers[0].Image)
+	})
+
+	t.Run("image from annotation", func(t *testing.T) {
+		pod, err := getPatch("", map[string]string{
+			annotations.KeySidecarImage: "daprio/daprd:custom",
+		}, nil)
+		require.NoError(t, err)
+		require.Len(t, pod.Spec.Containers, 1)
+		assert.Equal(t, "daprio/daprd:custom", pod.Spec.Containers[0].Image)
+	})
+
+	digest := "sha256:" + strings.Repeat("ab", 32)
+	digests := map[string]string{
+		"daprio/daprd:default": digest,
+		"daprio/daprd:custom":  digest,
+	}
+
+	t.Run("tag resolved to digest", func(t *testing.T) {
+		pod, err := getPatch("daprio/daprd:default", nil, digests)
+		require.NoError(t, err)
+		require.Len(t, pod.Spec.Containers, 1)
+		assert.Equal(t, "daprio/daprd:default@"+digest, pod.Spec.Containers[0].Image)
+	})
+
+	t.Run("tag from annotation resolved to digest", func(t *testing.T) {
+		pod, err := getPatch("daprio/daprd:default", map[string]string{
+			annotations.KeySidecarImage: "daprio/daprd:custom",
+		}, digests)
+		require.NoError(t, err)
+		require.Len(t, pod.Spec.Containers, 1)
+		assert.Equal(t, "daprio/daprd:custom@"+digest, pod.Spec.Containers[0].Image)
+	})
+
+	t.Run("unmapped tag is not changed", func(t *testing.T) {
+		pod, err := getPatch("daprio/daprd:other", nil, digests)
+		require.NoError(t, err)
+		require.Len(t, pod.Spec.Containers, 1)
+		assert.Equal(t, "daprio/daprd:other", pod.Spec.Containers[0].Image)
+	})
+
+	t.Run("image already pinned by digest is not changed", func(t *testing.T) {
+		pod, err := getPatch("daprio/daprd@"+digest, nil, digests)
+		require.NoError(t, err)
+		require.Len(t, pod.Spec.Containers, 1)
+		assert.Equal(t, "daprio/daprd@"+digest, pod.Spec.Containers[0].Image)
+	})
+}
+
+func TestRequireAppID(t *testing.T) {
+	getPatch := func(requireAppID bool, an map[string]string) (jsonpatch.Patch, error) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp-7d9c5b6f4-abcde",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		c.RequireAppID = requireAppID
+		c.SetFromPodAnnotations()
+
+		return c.GetPatch()
+	}
+
+	t.Run("denied when the app ID is missing", func(t *testing.T) {
+		_, err := getPatch(true, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "annotation dapr.io/app-id is required")
+	})
+
+	t.Run("allowed when the app ID is set", func(t *testing.T) {
+		patch, err := getPatch(true, map[string]string{
+			annotations.KeyAppID: "myapp",
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, patch)
+	})
+
+	t.Run("falls back to the pod name when not required", func(t *testing.T) {
+		patch, err := getPatch(false, nil)
+		require.NoError(t, err)
+		assert.NotEmpty(t, patch)
+	})
+}
+
+func TestDenialReasons(t *testing.T) {
+	getPatch := func(an map[string]string, modifierFn func(c *SidecarConfig)) error {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		if modifierFn != nil {
+			modifierFn(c)
+		}
+		c.SetFromPodAnnotations()
+
+		_, err := c.GetPatch()
+		return err
+	}
+
+	testCases := []struct {
+		name       string
+		an         map[string]string
+		modifierFn func(c *SidecarConfig)
+		expect     DenialReason
+	}{
+		{
+			name:   "invalid app ID",
+			an:     map[string]string{annotations.KeyAppID: "my_app"},
+			expect: DenialReasonInvalidAppID,
+		},
+		{
+			name: "missing app ID",
+			modifierFn: func(c *SidecarConfig) {
+				c.RequireAppID = true
+			},
+			expect: DenialReasonMissingAppID,
+		},
+		{
+			name:   "invalid app port",
+			an:     map[string]string{annotations.KeyAppPort: "70000"},
+			expect: DenialReasonInvalidAppPort,
+		},
+		{
+			name:   "app container not found",
+			an:     map[string]string{annotations.KeyAppContainerName: "notfound"},
+			expect: DenialReasonInvalidAppContainer,
+		},
+		{
+			name:   "invalid annotation in the sidecar container",
+			an:     map[string]string{annotations.KeySidecarGOMAXPROCS: "-1"},
+			expect: DenialReasonInvalidAnnotation,
+		},
+		{
+			name: "missing resource limits",
+			modifierFn: func(c *SidecarConfig) {
+				c.RequireLimits = true
+			},
+			expect: DenialReasonMissingResourceLimits,
+		},
+		{
+			name: "ID out of range",
+			an:   map[string]string{annotations.KeySidecarRunAsUser: "0"},
+			modifierFn: func(c *SidecarConfig) {
+				c.SidecarAllowedIDRange = IDRange{Min: 1000, Max: 2000}
+			},
+			expect: DenialReasonIDOutOfRange,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := getPatch(tc.an, tc.modifierFn)
+			require.Error(t, err)
+			assert.Equal(t, tc.expect, GetDenialReason(err))
+		})
+	}
+
+	t.Run("errors without a reason are internal", func(t *testing.T) {
+		assert.Equal(t, DenialReasonInternal, GetDenialReason(errors.New("test")))
+	})
+
+	t.Run("the most specific reason is preserved", func(t *testing.T) {
+		err := NewDenialError(DenialReasonIDOutOfRange, errors.New("test"))
+		err = NewDenialError(DenialReasonInvalidAnnotation, err)
+		assert.Equal(t, DenialReasonIDOutOfRange, GetDenialReason(err))
+		assert.Equal(t, "test", err.Error())
+	})
+}
+
+func TestForbidInsecureAppProtocol(t *testing.T) {
+	getPatch := func(forbid bool, an map[string]string) (jsonpatch.Patch, error) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myapp",
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		c.ForbidInsecureAppProtocol = forbid
+		c.SetFromPodAnnotations()
+
+		return c.GetPatch()
+	}
+
+	t.Run("denied", func(t *testing.T) {
+		for _, an := range []map[string]string{
+			{annotations.KeyAppPort: "8080"},
+			{annotations.KeyAppPort: "8080", annotations.KeyAppProtocol: "http"},
+			{annotations.KeyAppPort: "8080", annotations.KeyAppProtocol: "grpc"},
+			{annotations.KeyAppPort: "8080", annotations.KeyAppProtocol: "h2c"},
+		} {
+			_, err := getPatch(true, an)
+			require.Error(t, err, an)
+			assert.Contains(t, err.Error(), "require TLS for the app channel")
+		}
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		for _, an := range []map[string]string{
+			{annotations.KeyAppPort: "8080", annotations.KeyAppProtocol: "https"},
+			{annotations.KeyAppPort: "8080", annotations.KeyAppProtocol: "grpcs"},
+			{annotations.KeyAppPort: "8080", annotations.KeyAppProtocol: "grpc", annotations.KeyAppSSL: "true"},
+			{annotations.KeyAppProtocol: "http"},
+		} {
+			patch, err := getPatch(true, an)
+			require.NoError(t, err, an)
+			assert.NotEmpty(t, patch)
+		}
+	})
+
+	t.Run("not enforced by default", func(t *testing.T) {
+		patch, err := getPatch(false, map[string]string{
+			annotations.KeyAppPort:     "8080",
+			annotations.KeyAppProtocol: "http",
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, patch)
+	})
+}
+
+func TestNativeSidecar(t *testing.T) {
+	enabled := true
+	getPatchedPod := func(t *testing.T, restartPolicy corev1.RestartPolicy, initContainers []corev1.Container, an map[string]string) map[string]any {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myjob",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myjob",
+				},
+			},
+			Spec: corev1.PodSpec{
+				RestartPolicy:  restartPolicy,
+				InitContainers: initContainers,
+				Containers: []corev1.Container{
+					{Name: "main", Image: "job:latest"},
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		c.EnableNativeSidecars = enabled
+		c.SetFromPodAnnotations()
+
+		patch, err := c.GetPatch()
+		require.NoError(t, err)
+
+		// The restart policy of containers is not in the Kubernetes API types, so the result is inspected as JSON
+		podJSON, err := json.Marshal(pod)
+		require.NoError(t, err)
+		newJSON, err := patch.Apply(podJSON)
+		require.NoError(t, err)
+		res := map[string]any{}
+		require.NoError(t, json.Unmarshal(newJSON, &res))
+		return res["spec"].(map[string]any)
+	}
+	containerNames := func(spec map[string]any, key string) []string {
+		list, _ := spec[key].([]any)
+		names := make([]string, len(list))
+		for i, c := range list {
+			names[i] = c.(map[string]any)["name"].(string)
+		}
+		return names
+	}
+
+	t.Run("regular sidecar for pods that are always restarted", func(t *testing.T) {
+		for _, policy := range []corev1.RestartPolicy{"", corev1.RestartPolicyAlways} {
+			spec := getPatchedPod(t, policy, nil, nil)
+			assert.Equal(t, []string{"main", "daprd"}, containerNames(spec, "containers"))
+			assert.Empty(t, containerNames(spec, "initContainers"))
+		}
+	})
+
+	t.Run("native sidecar for Job pods", func(t *testing.T) {
+		for _, policy := range []corev1.RestartPolicy{corev1.RestartPolicyNever, corev1.RestartPolicyOnFailure} {
+			spec := getPatchedPod(t, policy, nil, nil)
+			assert.Equal(t, []string{"main"}, containerNames(spec, "containers"))
+			require.Equal(t, []string{"daprd"}, containerNames(spec, "initContainers"))
+			sidecar := spec["initContainers"].([]any)[0].(map[string]any)
+			assert.Equal(t, "Always", sidecar["restartPolicy"])
+		}
+	})
+
+	t.Run("native sidecar is the first init container", func(t *testing.T) {
+		spec := getPatchedPod(t, corev1.RestartPolicyNever, []corev1.Container{{Name: "migrate", Image: "migrate:latest"}}, nil)
+		assert.Equal(t, []string{"daprd", "migrate"}, containerNames(spec, "initContainers"))
+		assert.Equal(t, "Always", spec["initContainers"].([]any)[0].(map[string]any)["restartPolicy"])
+		assert.Nil(t, spec["initContainers"].([]any)[1].(map[string]any)["restartPolicy"])
+	})
+
+	t.Run("disabled with annotation", func(t *testing.T) {
+		spec := getPatchedPod(t, corev1.RestartPolicyNever, nil, map[string]string{
+			annotations.KeyNativeSidecar: "false",
+		})
+		assert.Equal(t, []string{"main", "daprd"}, containerNames(spec, "containers"))
+		assert.Empty(t, containerNames(spec, "initContainers"))
+	})
+
+	t.Run("enabled with annotation", func(t *testing.T) {
+		spec := getPatchedPod(t, corev1.RestartPolicyAlways, nil, map[string]string{
+			annotations.KeyNativeSidecar: "true",
+		})
+		assert.Equal(t, []string{"main"}, containerNames(spec, "containers"))
+		assert.Equal(t, []string{"daprd"}, containerNames(spec, "initContainers"))
+	})
+
+	t.Run("not enabled in the injector", func(t *testing.T) {
+		enabled = false
+		defer func() {
+			enabled = true
+		}()
+
+		for _, an := range []map[string]string{nil, {annotations.KeyNativeSidecar: "true"}} {
+			spec := getPatchedPod(t, corev1.RestartPolicyNever, nil, an)
+			assert.Equal(t, []string{"main", "daprd"}, containerNames(spec, "containers"))
+			assert.Empty(t, containerNames(spec, "initContainers"))
+		}
+	})
+}
+
+func TestNativeSidecarStartupProbe(t *testing.T) {
+	getSidecarContainer := func(t *testing.T, restartPolicy corev1.RestartPolicy, an map[string]string) *corev1.Container {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myjob",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myjob",
+				},
+			},
+			Spec: corev1.PodSpec{
+				RestartPolicy: restartPolicy,
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		c.EnableNativeSidecars = true
+		c.SetFromPodAnnotations()
+
+		container, err := c.getSidecarContainer(getSidecarContainerOpts{})
+		require.NoError(t, err)
+		return container
+	}
+
+	t.Run("native sidecar waits for the Dapr APIs", func(t *testing.T) {
+		container := getSidecarContainer(t, corev1.RestartPolicyNever, nil)
+		require.NotNil(t, container.StartupProbe)
+		require.NotNil(t, container.StartupProbe.HTTPGet)
+		assert.Equal(t, "/v1.0/healthz/outbound", container.StartupProbe.HTTPGet.Path)
+		assert.Equal(t, int32(3501), container.StartupProbe.HTTPGet.Port.IntVal)
+		assert.Equal(t, int32(1), container.StartupProbe.PeriodSeconds)
+		assert.Equal(t, int32(60), container.StartupProbe.FailureThreshold)
+	})
+
+	t.Run("startup probe doesn't depend on the app's health", func(t *testing.T) {
+		container := getSidecarContainer(t, corev1.RestartPolicyNever, map[string]string{
+			annotations.KeyEnableAppHealthCheck: "true",
+			annotations.KeyAppPort:              "3000",
+		})
+		require.NotNil(t, container.StartupProbe)
+		assert.Equal(t, "/v1.0/healthz/outbound", container.StartupProbe.HTTPGet.Path)
+	})
+
+	t.Run("no startup probe for regular sidecars", func(t *testing.T) {
+		container := getSidecarContainer(t, corev1.RestartPolicyAlways, nil)
+		assert.Nil(t, container.StartupProbe)
+	})
+
+	t.Run("disabled with annotation", func(t *testing.T) {
+		container := getSidecarContainer(t, corev1.RestartPolicyNever, map[string]string{
+			annotations.KeyNativeSidecarStartupProbe: "false",
+		})
+		assert.Nil(t, container.StartupProbe)
+	})
+}
+
 func TestPatching(t *testing.T) {
 	assertDaprdContainerFn := func(t *testing.T, pod *corev1.Pod) {
 		t.Helper()
@@ -269,6 +897,7 @@ func TestPatching(t *testing.T) {
 			c.Identity = "pod:identity"
 			c.CertChain = "certchain"
 			c.CertKey = "certkey"
+			c.SidecarImage = "daprio/daprd"
 
 			if tc.sidecarConfigModifierFn != nil {
 				tc.sidecarConfigModifierFn(c)
@@ -369,8 +998,277 @@ func TestPatching(t *testing.T) {
 				assert.Contains(t, args, "--unix-domain-socket /var/run/dapr-sockets")
 			},
 		},
+		{
+			name: "port env vars reflect the sidecar ports",
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.SidecarHTTPPort = 3600
+				c.SidecarAPIGRPCPort = 60001
+			},
+			assertFn: func(t *testing.T, pod *corev1.Pod) {
+				assertDaprdContainerFn(t, pod)
+
+				appEnvVars := map[string]string{}
+				for _, env := range pod.Spec.Containers[0].Env {
+					appEnvVars[env.Name] = env.Value
+				}
+				assert.Equal(t, "3600", appEnvVars["DAPR_HTTP_PORT"])
+				assert.Equal(t, "60001", appEnvVars["DAPR_GRPC_PORT"])
+			},
+		},
+		{
+			name: "port env vars are not injected when disabled",
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.InjectPortEnvIntoApp = false
+			},
+			assertFn: func(t *testing.T, pod *corev1.Pod) {
+				assertDaprdContainerFn(t, pod)
+
+				appEnvVars := map[string]string{}
+				for _, env := range pod.Spec.Containers[0].Env {
+					appEnvVars[env.Name] = env.Value
+				}
+				assert.Equal(t, "mondo", appEnvVars["CIAO"])
+				assert.NotContains(t, appEnvVars, "DAPR_HTTP_PORT")
+				assert.NotContains(t, appEnvVars, "DAPR_GRPC_PORT")
+				assert.Equal(t, "http", appEnvVars["APP_PROTOCOL"])
+			},
+		},
+		{
+			name: "added resources are not annotated by default",
+			podModifierFn: func(pod *corev1.Pod) {
+				pod.Annotations["dapr.io/sidecar-cpu-request"] = "100m"
+			},
+			assertFn: func(t *testing.T, pod *corev1.Pod) {
+				assertDaprdContainerFn(t, pod)
+				assert.NotContains(t, pod.Annotations, injectorConsts.SidecarAddedCPUAnnotation)
+				assert.NotContains(t, pod.Annotations, injectorConsts.SidecarAddedMemoryAnnotation)
+			},
+		},
+		{
+			name: "added resources are annotated",
+			podModifierFn: func(pod *corev1.Pod) {
+				pod.Annotations["dapr.io/sidecar-cpu-request"] = "100m"
+				pod.Annotations["dapr.io/sidecar-memory-request"] = "64Mi"
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.AnnotateAddedResources = true
+			},
+			assertFn: func(t *testing.T, pod *corev1.Pod) {
+				assertDaprdContainerFn(t, pod)
+				assert.Equal(t, "100m", pod.Annotations[injectorConsts.SidecarAddedCPUAnnotation])
+				assert.Equal(t, "64Mi", pod.Annotations[injectorConsts.SidecarAddedMemoryAnnotation])
+			},
+		},
+		{
+			name: "added resources include injected component containers",
+			podModifierFn: func(pod *corev1.Pod) {
+				pod.Annotations["dapr.io/sidecar-cpu-request"] = "100m"
+				pod.Annotations["dapr.io/inject-pluggable-components"] = "true"
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.AnnotateAddedResources = true
+				c.GetInjectedComponentContainers = func(appID string, namespace string) ([]corev1.Container, error) {
+					return []corev1.Container{
+						{
+							Name:  "component",
+							Image: "component:1.0",
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("250m"),
+									corev1.ResourceMemory: resource.MustParse("32Mi"),
+								},
+							},
+						},
+					}, nil
+				}
+			},
+			assertFn: func(t *testing.T, pod *corev1.Pod) {
+				require.Len(t, pod.Spec.Containers, 3)
+				assert.Equal(t, "350m", pod.Annotations[injectorConsts.SidecarAddedCPUAnnotation])
+				assert.Equal(t, "32Mi", pod.Annotations[injectorConsts.SidecarAddedMemoryAnnotation])
+			},
+		},
+		{
+			name: "injector version is not annotated when unset",
+			assertFn: func(t *testing.T, pod *corev1.Pod) {
+				assertDaprdContainerFn(t, pod)
+				assert.NotContains(t, pod.Annotations, injectorConsts.InjectorVersionAnnotation)
+			},
+		},
+		{
+			name: "injector version is annotated",
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.InjectorVersion = "1.12.0"
+			},
+			assertFn: func(t *testing.T, pod *corev1.Pod) {
+				assertDaprdContainerFn(t, pod)
+				assert.Equal(t, "1.12.0", pod.Annotations[injectorConsts.InjectorVersionAnnotation])
+			},
+		},
+		{
+			name: "default annotations are added",
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.DefaultPodAnnotations = map[string]string{
+					"sidecar.istio.io/inject": "false",
+					"team":                    "payments",
+				}
+			},
+			assertFn: func(t *testing.T, pod *corev1.Pod) {
+				assertDaprdContainerFn(t, pod)
+				assert.Equal(t, "false", pod.Annotations["sidecar.istio.io/inject"])
+				assert.Equal(t, "payments", pod.Annotations["team"])
+				assert.Equal(t, "myapp", pod.Annotations[annotations.KeyAppID])
+			},
+		},
+		{
+			name: "default annotations do not overwrite user values",
+			podModifierFn: func(pod *corev1.Pod) {
+				pod.Annotations["team"] = "checkout"
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.DefaultPodAnnotations = map[string]string{
+					"sidecar.istio.io/inject": "false",
+					"team":                    "payments",
+				}
+			},
+			assertFn: func(t *testing.T, pod *corev1.Pod) {
+				assertDaprdContainerFn(t, pod)
+				assert.Equal(t, "false", pod.Annotations["sidecar.istio.io/inject"])
+				assert.Equal(t, "checkout", pod.Annotations["team"])
+			},
+		},
+		{
+			name: "forced annotations overwrite user values",
+			podModifierFn: func(pod *corev1.Pod) {
+				pod.Annotations["team"] = "checkout"
+			},
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.DefaultPodAnnotations = map[string]string{
+					"sidecar.istio.io/inject": "false",
+				}
+				c.ForcedAnnotations = map[string]string{
+					"sidecar.istio.io/inject": "true",
+					"team":                    "payments",
+				}
+			},
+			assertFn: func(t *testing.T, pod *corev1.Pod) {
+				assertDaprdContainerFn(t, pod)
+				assert.Equal(t, "true", pod.Annotations["sidecar.istio.io/inject"])
+				assert.Equal(t, "payments", pod.Annotations["team"])
+			},
+		},
+		{
+			name: "added resources without requests are not annotated",
+			sidecarConfigModifierFn: func(c *SidecarConfig) {
+				c.AnnotateAddedResources = true
+			},
+			assertFn: func(t *testing.T, pod *corev1.Pod) {
+				assertDaprdContainerFn(t, pod)
+				assert.NotContains(t, pod.Annotations, injectorConsts.SidecarAddedCPUAnnotation)
+				assert.NotContains(t, pod.Annotations, injectorConsts.SidecarAddedMemoryAnnotation)
+			},
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, testCaseFn(tc))
 	}
 }
+
+func TestDisablePlacement(t *testing.T) {
+	getArgs := func(t *testing.T, an map[string]string) []string {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myapp",
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		c.PlacementAddress = "placement:50005"
+		c.SetFromPodAnnotations()
+
+		patch, err := c.GetPatch()
+		require.NoError(t, err)
+		newPod, err := PatchPod(pod, patch)
+		require.NoError(t, err)
+		require.Len(t, newPod.Spec.Containers, 1)
+		return newPod.Spec.Containers[0].Args
+	}
+
+	t.Run("placement enabled by default", func(t *testing.T) {
+		args := strings.Join(getArgs(t, nil), " ")
+		assert.Contains(t, args, "--placement-host-address placement:50005")
+	})
+
+	t.Run("placement disabled", func(t *testing.T) {
+		args := getArgs(t, map[string]string{
+			annotations.KeyDisablePlacement: "true",
+		})
+		assert.NotContains(t, args, "--placement-host-address")
+	})
+
+	t.Run("placement disabled ignores the address in the annotations", func(t *testing.T) {
+		args := getArgs(t, map[string]string{
+			annotations.KeyDisablePlacement:       "true",
+			annotations.KeyPlacementHostAddresses: "other-placement:50005",
+		})
+		assert.NotContains(t, args, "--placement-host-address")
+	})
+
+	t.Run("placement not disabled when false", func(t *testing.T) {
+		args := strings.Join(getArgs(t, map[string]string{
+			annotations.KeyDisablePlacement: "false",
+		}), " ")
+		assert.Contains(t, args, "--placement-host-address placement:50005")
+	})
+}
+
+func TestStartupCPUBoost(t *testing.T) {
+	getPod := func(t *testing.T, an map[string]string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myapp",
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		c.SetFromPodAnnotations()
+
+		patch, err := c.GetPatch()
+		require.NoError(t, err)
+		newPod, err := PatchPod(pod, patch)
+		require.NoError(t, err)
+		require.Len(t, newPod.Spec.Containers, 1)
+		return newPod
+	}
+
+	t.Run("pod annotated with the regular request", func(t *testing.T) {
+		pod := getPod(t, map[string]string{
+			annotations.KeyCPURequest:             "0.25",
+			annotations.KeySidecarStartupCPUBoost: "2",
+		})
+		assert.Equal(t, "250m", pod.Annotations[injectorConsts.SidecarSteadyCPUAnnotation])
+		assert.Equal(t, "2", pod.Spec.Containers[0].Resources.Requests.Cpu().String())
+	})
+
+	t.Run("pod not annotated without a boost", func(t *testing.T) {
+		pod := getPod(t, map[string]string{
+			annotations.KeyCPURequest: "250m",
+		})
+		assert.NotContains(t, pod.Annotations, injectorConsts.SidecarSteadyCPUAnnotation)
+		assert.Equal(t, "250m", pod.Spec.Containers[0].Resources.Requests.Cpu().String())
+	})
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// getPodSecurityContextPatchOps returns the patch operations that set fields in the pod's security context.
+// These are pod-level settings, so fields that the pod already sets are not changed:
+//   - fsGroup is set to SidecarFSGroup, so the sidecar can access its volumes.
+//   - seccompProfile is set to RuntimeDefault if SetPodSeccompRuntimeDefault is enabled, as required by the "restricted" Pod Security Standard.
+func (c *SidecarConfig) getPodSecurityContextPatchOps() jsonpatch.Patch {
+	podSC := c.pod.Spec.SecurityContext
+	if podSC == nil {
+		podSC = &corev1.PodSecurityContext{}
+	}
+
+	fields := make(map[string]any, 2)
+	if c.SidecarFSGroup != nil {
+		if podSC.FSGroup != nil {
+			log.Debugf("Pod already sets fsGroup %d: sidecar fsGroup %d is ignored", *podSC.FSGroup, *c.SidecarFSGroup)
+		} else {
+			fields["fsGroup"] = *c.SidecarFSGroup
+		}
+	}
+	if c.SetPodSeccompRuntimeDefault && podSC.SeccompProfile == nil {
+		fields["seccompProfile"] = corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// If the pod has no security context, it's added with all the fields at once
+	if c.pod.Spec.SecurityContext == nil {
+		return jsonpatch.Patch{
+			NewPatchOperation("add", PatchPathSecurityContext, fields),
+		}
+	}
+
+	patchOps := make(jsonpatch.Patch, 0, len(fields))
+	for _, key := range []string{"fsGroup", "seccompProfile"} {
+		if val, ok := fields[key]; ok {
+			patchOps = append(patchOps, NewPatchOperation("add", PatchPathSecurityContext+"/"+key, val))
+		}
+	}
+	return patchOps
+}
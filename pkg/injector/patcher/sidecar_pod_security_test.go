@@ -0,0 +1,110 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+	"github.com/dapr/kit/ptr"
+)
+
+func TestPodSeccompRuntimeDefault(t *testing.T) {
+	getPod := func(sc *corev1.PodSecurityContext) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				SecurityContext: sc,
+				Containers: []corev1.Container{
+					{Name: "app", Image: "app:1.0"},
+				},
+			},
+		}
+	}
+
+	patchPod := func(t *testing.T, pod *corev1.Pod, modifierFn func(c *SidecarConfig)) *corev1.Pod {
+		t.Helper()
+
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		if modifierFn != nil {
+			modifierFn(c)
+		}
+		c.SetFromPodAnnotations()
+
+		patch, err := c.GetPatch()
+		require.NoError(t, err)
+		newPod, err := PatchPod(pod, patch)
+		require.NoError(t, err)
+		return newPod
+	}
+	enable := func(c *SidecarConfig) {
+		c.SetPodSeccompRuntimeDefault = true
+	}
+
+	t.Run("not set by default", func(t *testing.T) {
+		newPod := patchPod(t, getPod(nil), nil)
+		assert.Nil(t, newPod.Spec.SecurityContext)
+	})
+
+	t.Run("set when the pod has no security context", func(t *testing.T) {
+		newPod := patchPod(t, getPod(nil), enable)
+		require.NotNil(t, newPod.Spec.SecurityContext)
+		require.NotNil(t, newPod.Spec.SecurityContext.SeccompProfile)
+		assert.Equal(t, corev1.SeccompProfileTypeRuntimeDefault, newPod.Spec.SecurityContext.SeccompProfile.Type)
+	})
+
+	t.Run("set when the pod's security context has no profile", func(t *testing.T) {
+		newPod := patchPod(t, getPod(&corev1.PodSecurityContext{
+			RunAsNonRoot: ptr.Of(true),
+		}), enable)
+		require.NotNil(t, newPod.Spec.SecurityContext.SeccompProfile)
+		assert.Equal(t, corev1.SeccompProfileTypeRuntimeDefault, newPod.Spec.SecurityContext.SeccompProfile.Type)
+		assert.True(t, *newPod.Spec.SecurityContext.RunAsNonRoot)
+	})
+
+	t.Run("existing profile is not changed", func(t *testing.T) {
+		newPod := patchPod(t, getPod(&corev1.PodSecurityContext{
+			SeccompProfile: &corev1.SeccompProfile{
+				Type:             corev1.SeccompProfileTypeLocalhost,
+				LocalhostProfile: ptr.Of("profiles/app.json"),
+			},
+		}), enable)
+		assert.Equal(t, corev1.SeccompProfileTypeLocalhost, newPod.Spec.SecurityContext.SeccompProfile.Type)
+		assert.Equal(t, "profiles/app.json", *newPod.Spec.SecurityContext.SeccompProfile.LocalhostProfile)
+	})
+
+	t.Run("combined with fsGroup", func(t *testing.T) {
+		for _, sc := range []*corev1.PodSecurityContext{nil, {RunAsNonRoot: ptr.Of(true)}} {
+			newPod := patchPod(t, getPod(sc), func(c *SidecarConfig) {
+				c.SetPodSeccompRuntimeDefault = true
+				c.SidecarFSGroup = ptr.Of(int64(1000))
+			})
+			require.NotNil(t, newPod.Spec.SecurityContext)
+			assert.Equal(t, int64(1000), *newPod.Spec.SecurityContext.FSGroup)
+			assert.Equal(t, corev1.SeccompProfileTypeRuntimeDefault, newPod.Spec.SecurityContext.SeccompProfile.Type)
+		}
+	})
+}
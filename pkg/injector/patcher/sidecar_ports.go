@@ -0,0 +1,132 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"fmt"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+)
+
+// sidecarPort is a port used by the sidecar, with a pointer to the property that configures it.
+type sidecarPort struct {
+	port *int32
+	desc string
+}
+
+// getSidecarPorts returns the ports the sidecar listens on.
+// The order is stable, so ports are remapped deterministically.
+func (c *SidecarConfig) getSidecarPorts() []sidecarPort {
+	ports := []sidecarPort{
+		{port: &c.SidecarHTTPPort, desc: "Dapr HTTP port"},
+		{port: &c.SidecarAPIGRPCPort, desc: "Dapr gRPC port"},
+		{port: &c.SidecarInternalGRPCPort, desc: "Dapr internal gRPC port"},
+		{port: &c.SidecarPublicPort, desc: "Dapr public port"},
+	}
+	if c.EnableMetrics {
+		ports = append(ports, sidecarPort{port: &c.SidecarMetricsPort, desc: "Dapr metrics port"})
+	}
+	if c.EnableDebug {
+		ports = append(ports, sidecarPort{port: &c.SidecarDebugPort, desc: "Dapr debug port"})
+	}
+	if c.EnableProfiling {
+		ports = append(ports, sidecarPort{port: &c.SidecarProfilePort, desc: "Dapr profiling port"})
+	}
+	return ports
+}
+
+// validateAppPort returns an error if the app port is set to an invalid port number.
+func (c *SidecarConfig) validateAppPort() error {
+	if c.AppPort < 0 || c.AppPort > 65535 {
+		return fmt.Errorf("invalid port %d in annotation %s: must be a number between 1 and 65535", c.AppPort, annotations.KeyAppPort)
+	}
+	return nil
+}
+
+// validateProfilePort returns an error if the port for the profiling server is invalid or conflicts with another port used by the sidecar or by the app.
+func (c *SidecarConfig) validateProfilePort() error {
+	if !c.EnableProfiling {
+		return nil
+	}
+	if c.SidecarProfilePort < 1 || c.SidecarProfilePort > 65535 {
+		return fmt.Errorf("invalid port %d in annotation %s: must be a number between 1 and 65535", c.SidecarProfilePort, annotations.KeyProfilePort)
+	}
+	if c.AppPort == c.SidecarProfilePort {
+		return fmt.Errorf("port %d in annotation %s conflicts with the app port", c.SidecarProfilePort, annotations.KeyProfilePort)
+	}
+	for _, sp := range c.getSidecarPorts() {
+		if sp.port != &c.SidecarProfilePort && *sp.port == c.SidecarProfilePort {
+			return fmt.Errorf("port %d in annotation %s conflicts with the %s", c.SidecarProfilePort, annotations.KeyProfilePort, sp.desc)
+		}
+	}
+	return nil
+}
+
+// resolvePortConflicts checks that no container in the pod declares a port the sidecar listens on.
+// Because all containers in a pod share the same network namespace, the sidecar would fail to start.
+// If AutoRemapPorts is enabled, conflicting sidecar ports are moved to the next free port; otherwise, an error is returned.
+func (c *SidecarConfig) resolvePortConflicts() error {
+	// Ports declared by the containers in the pod
+	declared := map[int32]string{}
+	for _, container := range c.pod.Spec.Containers {
+		for _, p := range container.Ports {
+			if p.ContainerPort > 0 {
+				declared[p.ContainerPort] = container.Name
+			}
+		}
+	}
+	if len(declared) == 0 {
+		return nil
+	}
+
+	ports := c.getSidecarPorts()
+
+	// All ports that are taken, to find free ones when remapping
+	taken := make(map[int32]struct{}, len(declared)+len(ports)+1)
+	for p := range declared {
+		taken[p] = struct{}{}
+	}
+	for _, sp := range ports {
+		taken[*sp.port] = struct{}{}
+	}
+	if c.AppPort > 0 {
+		taken[c.AppPort] = struct{}{}
+	}
+
+	for _, sp := range ports {
+		containerName, ok := declared[*sp.port]
+		if !ok {
+			continue
+		}
+		if !c.AutoRemapPorts {
+			return fmt.Errorf("port %d declared by container '%s' conflicts with the %s: change the port of the container or enable automatic remapping of the sidecar ports", *sp.port, containerName, sp.desc)
+		}
+
+		newPort := *sp.port
+		for {
+			newPort++
+			if newPort > 65535 {
+				return fmt.Errorf("port %d declared by container '%s' conflicts with the %s, and no free port was found to remap it to", *sp.port, containerName, sp.desc)
+			}
+			if _, ok := taken[newPort]; !ok {
+				break
+			}
+		}
+		log.Infof("Remapping the %s from %d to %d because it conflicts with a port declared by container '%s'", sp.desc, *sp.port, newPort, containerName)
+		taken[newPort] = struct{}{}
+		*sp.port = newPort
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestResolvePortConflicts(t *testing.T) {
+	newPod := func(ports ...int32) *corev1.Pod {
+		containerPorts := make([]corev1.ContainerPort, len(ports))
+		for i, p := range ports {
+			containerPorts[i] = corev1.ContainerPort{ContainerPort: p}
+		}
+		return &corev1.Pod{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "app", Ports: containerPorts},
+				},
+			},
+		}
+	}
+
+	t.Run("no conflicts", func(t *testing.T) {
+		c := NewSidecarConfig(newPod(8080, 9000))
+		c.AppPort = 8080
+
+		require.NoError(t, c.resolvePortConflicts())
+		assert.Equal(t, int32(3500), c.SidecarHTTPPort)
+		assert.Equal(t, int32(50001), c.SidecarAPIGRPCPort)
+	})
+
+	t.Run("conflict is denied", func(t *testing.T) {
+		c := NewSidecarConfig(newPod(8080, 3500))
+
+		err := c.resolvePortConflicts()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "port 3500 declared by container 'app' conflicts with the Dapr HTTP port")
+	})
+
+	t.Run("conflict with the metrics port is ignored when metrics are disabled", func(t *testing.T) {
+		c := NewSidecarConfig(newPod(9090))
+
+		require.Error(t, c.resolvePortConflicts())
+
+		c.EnableMetrics = false
+		require.NoError(t, c.resolvePortConflicts())
+	})
+
+	t.Run("conflicts are remapped", func(t *testing.T) {
+		c := NewSidecarConfig(newPod(3500, 3501, 3502, 50001))
+		c.AutoRemapPorts = true
+		c.AppPort = 3503
+
+		require.NoError(t, c.resolvePortConflicts())
+		assert.Equal(t, int32(3504), c.SidecarHTTPPort)
+		assert.Equal(t, int32(50003), c.SidecarAPIGRPCPort)
+		assert.Equal(t, int32(50002), c.SidecarInternalGRPCPort)
+		assert.Equal(t, int32(3505), c.SidecarPublicPort)
+		assert.Equal(t, int32(9090), c.SidecarMetricsPort)
+	})
+
+	t.Run("no free port to remap to", func(t *testing.T) {
+		c := NewSidecarConfig(newPod(65535))
+		c.AutoRemapPorts = true
+		c.SidecarHTTPPort = 65535
+
+		require.Error(t, c.resolvePortConflicts())
+	})
+}
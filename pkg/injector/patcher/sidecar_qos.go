@@ -0,0 +1,107 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+)
+
+// ParsePodQOSClass parses a string into a QoS class, ignoring the case.
+// An empty string returns an empty class, which means that any QoS class is allowed.
+func ParsePodQOSClass(val string) (corev1.PodQOSClass, error) {
+	for _, class := range []corev1.PodQOSClass{corev1.PodQOSGuaranteed, corev1.PodQOSBurstable, corev1.PodQOSBestEffort} {
+		if strings.EqualFold(val, string(class)) {
+			return class, nil
+		}
+	}
+	if val == "" {
+		return "", nil
+	}
+	return "", fmt.Errorf("invalid value for required pod QoS class: '%s'", val)
+}
+
+// checkPodQOSClass returns an error if the pod, with the containers added by the injector, would not be in the RequiredPodQoS class.
+func (c *SidecarConfig) checkPodQOSClass(added []corev1.Container) error {
+	if c.RequiredPodQoS == "" {
+		return nil
+	}
+
+	containers := make([]corev1.Container, 0, len(c.pod.Spec.InitContainers)+len(c.pod.Spec.Containers)+len(added))
+	containers = append(containers, c.pod.Spec.InitContainers...)
+	containers = append(containers, c.pod.Spec.Containers...)
+	containers = append(containers, added...)
+
+	class := getPodQOSClass(containers)
+	if class != c.RequiredPodQoS {
+		return fmt.Errorf("the pod with the sidecar would be in the %s QoS class, but the %s QoS class is required: set the resources of the sidecar with annotations %s and %s", class, c.RequiredPodQoS, annotations.KeyCPULimit, annotations.KeyMemoryLimit)
+	}
+	return nil
+}
+
+// getPodQOSClass returns the QoS class of a pod with the given containers, following the rules of Kubernetes.
+// Requests that aren't set default to the limits, as done by the API server.
+func getPodQOSClass(containers []corev1.Container) corev1.PodQOSClass {
+	requests := corev1.ResourceList{}
+	limits := corev1.ResourceList{}
+	isGuaranteed := true
+	for _, container := range containers {
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			limit, hasLimit := container.Resources.Limits[name]
+			if hasLimit && !limit.IsZero() {
+				addQuantity(limits, name, limit)
+			} else {
+				isGuaranteed = false
+			}
+
+			request, hasRequest := container.Resources.Requests[name]
+			if !hasRequest && hasLimit {
+				request = limit
+			}
+			if !request.IsZero() {
+				addQuantity(requests, name, request)
+			}
+		}
+	}
+
+	if len(requests) == 0 && len(limits) == 0 {
+		return corev1.PodQOSBestEffort
+	}
+	if isGuaranteed {
+		for name, request := range requests {
+			if limit, ok := limits[name]; !ok || limit.Cmp(request) != 0 {
+				isGuaranteed = false
+				break
+			}
+		}
+	}
+	if isGuaranteed && len(requests) == len(limits) {
+		return corev1.PodQOSGuaranteed
+	}
+	return corev1.PodQOSBurstable
+}
+
+func addQuantity(list corev1.ResourceList, name corev1.ResourceName, q resource.Quantity) {
+	if existing, ok := list[name]; ok {
+		existing.Add(q)
+		list[name] = existing
+	} else {
+		list[name] = q.DeepCopy()
+	}
+}
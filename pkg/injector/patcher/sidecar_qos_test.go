@@ -0,0 +1,217 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+)
+
+func TestParsePodQOSClass(t *testing.T) {
+	tests := map[string]corev1.PodQOSClass{
+		"":           "",
+		"Guaranteed": corev1.PodQOSGuaranteed,
+		"burstable":  corev1.PodQOSBurstable,
+		"BESTEFFORT": corev1.PodQOSBestEffort,
+	}
+	for val, expect := range tests {
+		class, err := ParsePodQOSClass(val)
+		require.NoError(t, err, val)
+		assert.Equal(t, expect, class, val)
+	}
+
+	_, err := ParsePodQOSClass("premium")
+	require.Error(t, err)
+}
+
+func TestGetPodQOSClass(t *testing.T) {
+	resources := func(req, lim corev1.ResourceList) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{Requests: req, Limits: lim}
+	}
+	list := func(cpu, mem string) corev1.ResourceList {
+		l := corev1.ResourceList{}
+		if cpu != "" {
+			l[corev1.ResourceCPU] = resource.MustParse(cpu)
+		}
+		if mem != "" {
+			l[corev1.ResourceMemory] = resource.MustParse(mem)
+		}
+		return l
+	}
+
+	tests := []struct {
+		name       string
+		containers []corev1.ResourceRequirements
+		expect     corev1.PodQOSClass
+	}{
+		{
+			name:       "no resources",
+			containers: []corev1.ResourceRequirements{{}, {}},
+			expect:     corev1.PodQOSBestEffort,
+		},
+		{
+			name: "equal requests and limits",
+			containers: []corev1.ResourceRequirements{
+				resources(list("1", "1Gi"), list("1", "1Gi")),
+				resources(list("500m", "128Mi"), list("500m", "128Mi")),
+			},
+			expect: corev1.PodQOSGuaranteed,
+		},
+		{
+			name: "requests defaulted to limits",
+			containers: []corev1.ResourceRequirements{
+				resources(nil, list("1", "1Gi")),
+			},
+			expect: corev1.PodQOSGuaranteed,
+		},
+		{
+			name: "requests lower than limits",
+			containers: []corev1.ResourceRequirements{
+				resources(list("1", "1Gi"), list("1", "1Gi")),
+				resources(list("100m", "128Mi"), list("500m", "128Mi")),
+			},
+			expect: corev1.PodQOSBurstable,
+		},
+		{
+			name: "container without limits",
+			containers: []corev1.ResourceRequirements{
+				resources(list("1", "1Gi"), list("1", "1Gi")),
+				{},
+			},
+			expect: corev1.PodQOSBurstable,
+		},
+		{
+			name: "only memory limits",
+			containers: []corev1.ResourceRequirements{
+				resources(nil, list("", "1Gi")),
+			},
+			expect: corev1.PodQOSBurstable,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			containers := make([]corev1.Container, len(tc.containers))
+			for i, r := range tc.containers {
+				containers[i] = corev1.Container{Resources: r}
+			}
+			assert.Equal(t, tc.expect, getPodQOSClass(containers))
+		})
+	}
+}
+
+func TestRequiredPodQoS(t *testing.T) {
+	getPatch := func(required corev1.PodQOSClass, an map[string]string, appResources corev1.ResourceRequirements) error {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "app", Image: "app:1.0", Resources: appResources},
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		c.RequiredPodQoS = required
+		c.SetFromPodAnnotations()
+
+		_, err := c.GetPatch()
+		return err
+	}
+
+	guaranteedApp := corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("1"),
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		},
+	}
+	guaranteedSidecar := map[string]string{
+		annotations.KeyCPULimit:    "500m",
+		annotations.KeyMemoryLimit: "256Mi",
+	}
+	burstableSidecar := map[string]string{
+		annotations.KeyCPURequest:  "100m",
+		annotations.KeyCPULimit:    "500m",
+		annotations.KeyMemoryLimit: "256Mi",
+	}
+
+	t.Run("any class allowed by default", func(t *testing.T) {
+		require.NoError(t, getPatch("", nil, corev1.ResourceRequirements{}))
+		require.NoError(t, getPatch("", burstableSidecar, guaranteedApp))
+	})
+
+	t.Run("guaranteed", func(t *testing.T) {
+		require.NoError(t, getPatch(corev1.PodQOSGuaranteed, guaranteedSidecar, guaranteedApp))
+	})
+
+	t.Run("guaranteed with the guaranteed QoS annotation", func(t *testing.T) {
+		require.NoError(t, getPatch(corev1.PodQOSGuaranteed, map[string]string{
+			annotations.KeySidecarGuaranteedQoS: "true",
+			annotations.KeyCPURequest:           "1",
+			annotations.KeyMemoryRequest:        "256Mi",
+		}, guaranteedApp))
+	})
+
+	t.Run("guaranteed denied when the sidecar is burstable", func(t *testing.T) {
+		err := getPatch(corev1.PodQOSGuaranteed, burstableSidecar, guaranteedApp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Burstable QoS class")
+		assert.Equal(t, DenialReasonUnexpectedQoSClass, GetDenialReason(err))
+	})
+
+	t.Run("guaranteed denied when the sidecar has no resources", func(t *testing.T) {
+		err := getPatch(corev1.PodQOSGuaranteed, nil, guaranteedApp)
+		require.Error(t, err)
+		assert.Equal(t, DenialReasonUnexpectedQoSClass, GetDenialReason(err))
+	})
+
+	t.Run("burstable", func(t *testing.T) {
+		require.NoError(t, getPatch(corev1.PodQOSBurstable, burstableSidecar, guaranteedApp))
+		require.NoError(t, getPatch(corev1.PodQOSBurstable, guaranteedSidecar, corev1.ResourceRequirements{}))
+	})
+
+	t.Run("burstable denied when the pod is guaranteed", func(t *testing.T) {
+		err := getPatch(corev1.PodQOSBurstable, guaranteedSidecar, guaranteedApp)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Guaranteed QoS class")
+		assert.Equal(t, DenialReasonUnexpectedQoSClass, GetDenialReason(err))
+	})
+
+	t.Run("best effort", func(t *testing.T) {
+		require.NoError(t, getPatch(corev1.PodQOSBestEffort, nil, corev1.ResourceRequirements{}))
+	})
+
+	t.Run("best effort denied when the sidecar has resources", func(t *testing.T) {
+		err := getPatch(corev1.PodQOSBestEffort, guaranteedSidecar, corev1.ResourceRequirements{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Burstable QoS class")
+		assert.Equal(t, DenialReasonUnexpectedQoSClass, GetDenialReason(err))
+	})
+}
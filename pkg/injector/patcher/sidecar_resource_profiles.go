@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ResourceProfile contains a set of resource requests and limits for the sidecar container.
+// Empty values are not set.
+type ResourceProfile struct {
+	CPURequest    string `json:"cpuRequest,omitempty"`
+	CPULimit      string `json:"cpuLimit,omitempty"`
+	MemoryRequest string `json:"memoryRequest,omitempty"`
+	MemoryLimit   string `json:"memoryLimit,omitempty"`
+}
+
+// Validate returns an error if any of the values in the profile is not a valid quantity.
+func (p ResourceProfile) Validate() error {
+	values := []struct {
+		name string
+		val  string
+	}{
+		{"CPU request", p.CPURequest},
+		{"CPU limit", p.CPULimit},
+		{"memory request", p.MemoryRequest},
+		{"memory limit", p.MemoryLimit},
+	}
+	for _, v := range values {
+		if v.val == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(v.val); err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", v.name, v.val, err)
+		}
+	}
+	return nil
+}
+
+// SetResourceProfile sets the resource requests and limits of the sidecar from the profile.
+// This must be invoked before SetFromPodAnnotations, so values set in the annotations take precedence.
+func (c *SidecarConfig) SetResourceProfile(p ResourceProfile) {
+	if p.CPURequest != "" {
+		c.SidecarCPURequest = p.CPURequest
+	}
+	if p.CPULimit != "" {
+		c.SidecarCPULimit = p.CPULimit
+	}
+	if p.MemoryRequest != "" {
+		c.SidecarMemoryRequest = p.MemoryRequest
+	}
+	if p.MemoryLimit != "" {
+		c.SidecarMemoryLimit = p.MemoryLimit
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"fmt"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+)
+
+// validatePodRuntimeClass returns an error if the runtime class set in the annotations is not a valid name for a RuntimeClass.
+func (c *SidecarConfig) validatePodRuntimeClass() error {
+	if c.PodRuntimeClass == "" {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(c.PodRuntimeClass); len(errs) > 0 {
+		return fmt.Errorf("invalid value for annotation %s: %s", annotations.KeyPodRuntimeClass, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// getRuntimeClassName returns the runtime class the pod runs with: the one set on the pod, or else the one set in the annotations.
+// Returns an empty string if the pod uses the default runtime.
+func (c *SidecarConfig) getRuntimeClassName() string {
+	if c.pod != nil && c.pod.Spec.RuntimeClassName != nil {
+		return *c.pod.Spec.RuntimeClassName
+	}
+	return c.PodRuntimeClass
+}
+
+// getRuntimeClassPatchOps returns the patch operations that set the pod's runtime class from the annotations, for workloads that need a sandboxed runtime.
+// A runtime class that is already set on the pod is never overwritten.
+func (c *SidecarConfig) getRuntimeClassPatchOps() jsonpatch.Patch {
+	if c.PodRuntimeClass == "" || c.pod.Spec.RuntimeClassName != nil {
+		return nil
+	}
+
+	return jsonpatch.Patch{
+		NewPatchOperation("add", PatchPathRuntimeClassName, c.PodRuntimeClass),
+	}
+}
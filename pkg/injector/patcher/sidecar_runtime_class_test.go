@@ -0,0 +1,140 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+	"github.com/dapr/kit/ptr"
+)
+
+func TestPodRuntimeClass(t *testing.T) {
+	getPatchedPod := func(t *testing.T, runtimeClass *string, an map[string]string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				RuntimeClassName: runtimeClass,
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		c.RuntimeClassAdjustments = map[string]corev1.SecurityContext{
+			"gvisor": {AllowPrivilegeEscalation: ptr.Of(true)},
+		}
+		c.SetFromPodAnnotations()
+
+		patch, err := c.GetPatch()
+		require.NoError(t, err)
+		newPod, err := PatchPod(pod, patch)
+		require.NoError(t, err)
+		return newPod
+	}
+
+	getDaprd := func(t *testing.T, pod *corev1.Pod) corev1.Container {
+		for _, container := range pod.Spec.Containers {
+			if container.Name == "daprd" {
+				return container
+			}
+		}
+		t.Fatal("daprd container not found")
+		return corev1.Container{}
+	}
+
+	t.Run("set when absent", func(t *testing.T) {
+		pod := getPatchedPod(t, nil, map[string]string{
+			annotations.KeyPodRuntimeClass: "gvisor",
+		})
+		require.NotNil(t, pod.Spec.RuntimeClassName)
+		assert.Equal(t, "gvisor", *pod.Spec.RuntimeClassName)
+
+		// Adjustments for the runtime class are applied to the sidecar
+		daprd := getDaprd(t, pod)
+		require.NotNil(t, daprd.SecurityContext.AllowPrivilegeEscalation)
+		assert.True(t, *daprd.SecurityContext.AllowPrivilegeEscalation)
+	})
+
+	t.Run("existing runtime class not overwritten", func(t *testing.T) {
+		pod := getPatchedPod(t, ptr.Of("kata"), map[string]string{
+			annotations.KeyPodRuntimeClass: "gvisor",
+		})
+		require.NotNil(t, pod.Spec.RuntimeClassName)
+		assert.Equal(t, "kata", *pod.Spec.RuntimeClassName)
+
+		daprd := getDaprd(t, pod)
+		require.NotNil(t, daprd.SecurityContext.AllowPrivilegeEscalation)
+		assert.False(t, *daprd.SecurityContext.AllowPrivilegeEscalation)
+	})
+
+	t.Run("no-op without the annotation", func(t *testing.T) {
+		pod := getPatchedPod(t, nil, nil)
+		assert.Nil(t, pod.Spec.RuntimeClassName)
+	})
+
+	t.Run("invalid runtime class", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled:         "true",
+					annotations.KeyPodRuntimeClass: "GVisor_Sandbox",
+				},
+			},
+		})
+		c.SidecarImage = "daprio/daprd"
+		c.SetFromPodAnnotations()
+
+		_, err := c.GetPatch()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), annotations.KeyPodRuntimeClass)
+		assert.Equal(t, DenialReasonInvalidAnnotation, GetDenialReason(err))
+	})
+
+	t.Run("warning when the pod sets a different runtime class", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					annotations.KeyEnabled:         "true",
+					annotations.KeyAppPort:         "3000",
+					annotations.KeyPodRuntimeClass: "gvisor",
+				},
+			},
+			Spec: corev1.PodSpec{
+				RuntimeClassName: ptr.Of("kata"),
+			},
+		})
+		c.SetFromPodAnnotations()
+
+		warnings := c.GetWarnings()
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], annotations.KeyPodRuntimeClass)
+	})
+}
@@ -18,6 +18,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/dapr/dapr/pkg/injector/annotations"
 	injectorConsts "github.com/dapr/dapr/pkg/injector/consts"
@@ -70,6 +71,31 @@ func TestSidecarConfigSetFromAnnotations(t *testing.T) {
 		assert.Equal(t, "info", c.LogLevel)
 	})
 
+	t.Run("precedence of default and forced annotations", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					annotations.KeyAppID:    "myappid",
+					annotations.KeyAppPort:  "9876",
+					annotations.KeyLogLevel: "warn",
+				},
+			},
+		})
+		c.DefaultPodAnnotations = map[string]string{
+			annotations.KeyLogLevel:  "debug",
+			annotations.KeyLogAsJSON: "true",
+		}
+		c.ForcedAnnotations = map[string]string{
+			annotations.KeyAppPort: "1234",
+		}
+		c.SetFromPodAnnotations()
+
+		assert.Equal(t, "myappid", c.AppID)
+		assert.Equal(t, "warn", c.LogLevel)
+		assert.True(t, c.LogAsJSON)
+		assert.Equal(t, int32(1234), c.AppPort)
+	})
+
 	t.Run("skip invalid properties", func(t *testing.T) {
 		c := NewSidecarConfig(&corev1.Pod{})
 
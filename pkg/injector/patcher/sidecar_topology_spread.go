@@ -0,0 +1,71 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	injectorConsts "github.com/dapr/dapr/pkg/injector/consts"
+)
+
+// getTopologySpreadPatchOps returns the patch operations that merge SidecarTopologySpread into the pod's topology spread constraints, so Dapr workloads are spread across zones or nodes.
+// Constraints set on the pod are never overwritten: a constraint is skipped if the pod already has one with the same topology key and whenUnsatisfiable, as Kubernetes doesn't allow duplicates.
+// Constraints without a label selector spread the pods of the same Dapr app.
+func (c *SidecarConfig) getTopologySpreadPatchOps() jsonpatch.Patch {
+	if len(c.SidecarTopologySpread) == 0 {
+		return nil
+	}
+
+	type constraintKey struct {
+		topologyKey       string
+		whenUnsatisfiable corev1.UnsatisfiableConstraintAction
+	}
+
+	merged := make([]corev1.TopologySpreadConstraint, 0, len(c.pod.Spec.TopologySpreadConstraints)+len(c.SidecarTopologySpread))
+	existing := map[constraintKey]struct{}{}
+	for _, tsc := range c.pod.Spec.TopologySpreadConstraints {
+		merged = append(merged, tsc)
+		existing[constraintKey{tsc.TopologyKey, tsc.WhenUnsatisfiable}] = struct{}{}
+	}
+
+	changed := false
+	for _, tsc := range c.SidecarTopologySpread {
+		key := constraintKey{tsc.TopologyKey, tsc.WhenUnsatisfiable}
+		if _, ok := existing[key]; ok {
+			continue
+		}
+		existing[key] = struct{}{}
+
+		tsc = *tsc.DeepCopy()
+		if tsc.LabelSelector == nil {
+			tsc.LabelSelector = &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					injectorConsts.SidecarAppIDLabel: c.GetAppID(),
+				},
+			}
+		}
+		merged = append(merged, tsc)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	// "add" replaces the list if the pod already has topology spread constraints
+	return jsonpatch.Patch{
+		NewPatchOperation("add", PatchPathTopologySpreadConstraints, merged),
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+)
+
+func TestGetTopologySpreadPatchOps(t *testing.T) {
+	zoneSelector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{"tier": "dapr"},
+	}
+	sidecarConstraints := []corev1.TopologySpreadConstraint{
+		{
+			MaxSkew:           1,
+			TopologyKey:       "topology.kubernetes.io/zone",
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     zoneSelector,
+		},
+		{
+			MaxSkew:           2,
+			TopologyKey:       "kubernetes.io/hostname",
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+		},
+	}
+
+	getConstraints := func(t *testing.T, podConstraints []corev1.TopologySpreadConstraint, constraints []corev1.TopologySpreadConstraint) []corev1.TopologySpreadConstraint {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					annotations.KeyAppID: "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				TopologySpreadConstraints: podConstraints,
+			},
+		}
+		c := NewSidecarConfig(pod)
+		c.SetFromPodAnnotations()
+		c.SidecarTopologySpread = constraints
+
+		patch := c.getTopologySpreadPatchOps()
+		if len(patch) == 0 {
+			return pod.Spec.TopologySpreadConstraints
+		}
+		newPod, err := PatchPod(pod, patch)
+		require.NoError(t, err)
+		return newPod.Spec.TopologySpreadConstraints
+	}
+
+	appSelector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{"dapr.io/app-id": "myapp"},
+	}
+
+	t.Run("no constraints", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{})
+		assert.Empty(t, c.getTopologySpreadPatchOps())
+	})
+
+	t.Run("pod without constraints", func(t *testing.T) {
+		constraints := getConstraints(t, nil, sidecarConstraints)
+		require.Len(t, constraints, 2)
+		assert.Equal(t, sidecarConstraints[0], constraints[0])
+		assert.Equal(t, "kubernetes.io/hostname", constraints[1].TopologyKey)
+		assert.Equal(t, int32(2), constraints[1].MaxSkew)
+		assert.Equal(t, appSelector, constraints[1].LabelSelector, "constraints without a selector spread the pods of the app")
+	})
+
+	t.Run("label selector of the configuration is not modified", func(t *testing.T) {
+		getConstraints(t, nil, sidecarConstraints)
+		assert.Nil(t, sidecarConstraints[1].LabelSelector)
+	})
+
+	t.Run("merged with the pod's constraints", func(t *testing.T) {
+		podConstraint := corev1.TopologySpreadConstraint{
+			MaxSkew:           1,
+			TopologyKey:       "example.com/rack",
+			WhenUnsatisfiable: corev1.DoNotSchedule,
+			LabelSelector:     zoneSelector,
+		}
+		constraints := getConstraints(t, []corev1.TopologySpreadConstraint{podConstraint}, sidecarConstraints)
+		require.Len(t, constraints, 3)
+		assert.Equal(t, podConstraint, constraints[0])
+		assert.Equal(t, "topology.kubernetes.io/zone", constraints[1].TopologyKey)
+		assert.Equal(t, "kubernetes.io/hostname", constraints[2].TopologyKey)
+	})
+
+	t.Run("pod's constraints are not overwritten", func(t *testing.T) {
+		podConstraint := corev1.TopologySpreadConstraint{
+			MaxSkew:           5,
+			TopologyKey:       "topology.kubernetes.io/zone",
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     appSelector,
+		}
+		constraints := getConstraints(t, []corev1.TopologySpreadConstraint{podConstraint}, sidecarConstraints)
+		require.Len(t, constraints, 2)
+		assert.Equal(t, podConstraint, constraints[0])
+		assert.Equal(t, "kubernetes.io/hostname", constraints[1].TopologyKey)
+	})
+
+	t.Run("same topology key with a different action is added", func(t *testing.T) {
+		podConstraint := corev1.TopologySpreadConstraint{
+			MaxSkew:           1,
+			TopologyKey:       "topology.kubernetes.io/zone",
+			WhenUnsatisfiable: corev1.DoNotSchedule,
+			LabelSelector:     appSelector,
+		}
+		constraints := getConstraints(t, []corev1.TopologySpreadConstraint{podConstraint}, sidecarConstraints[:1])
+		require.Len(t, constraints, 2)
+		assert.Equal(t, podConstraint, constraints[0])
+		assert.Equal(t, sidecarConstraints[0], constraints[1])
+	})
+
+	t.Run("no patch if the pod already has all constraints", func(t *testing.T) {
+		pod := &corev1.Pod{
+			Spec: corev1.PodSpec{
+				TopologySpreadConstraints: sidecarConstraints,
+			},
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarTopologySpread = sidecarConstraints
+		assert.Empty(t, c.getTopologySpreadPatchOps())
+	})
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	injectorConsts "github.com/dapr/dapr/pkg/injector/consts"
+	securityConsts "github.com/dapr/dapr/pkg/security/consts"
+)
+
+// Kinds of resources that can contain the trust anchors.
+const (
+	TrustAnchorsSourceConfigMap = "configmap"
+	TrustAnchorsSourceSecret    = "secret"
+)
+
+// TrustAnchorsSourceExistsFn is a function that returns true if the trust anchors source exists in the namespace.
+type TrustAnchorsSourceExistsFn = func(source TrustAnchorsSource, namespace string) (bool, error)
+
+// TrustAnchorsSource is a ConfigMap or Secret, in the pod's namespace, that contains custom trust anchors for the sidecar.
+type TrustAnchorsSource struct {
+	// Kind is either TrustAnchorsSourceConfigMap or TrustAnchorsSourceSecret.
+	Kind string
+	// Name of the resource.
+	Name string
+}
+
+// ParseTrustAnchorsSource parses a trust anchors source in the format "configmap/<name>" or "secret/<name>".
+// An empty string returns nil.
+func ParseTrustAnchorsSource(val string) (*TrustAnchorsSource, error) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return nil, nil
+	}
+
+	kind, name, ok := strings.Cut(val, "/")
+	if !ok {
+		return nil, fmt.Errorf("trust anchors source '%s' is not in the format kind/name", val)
+	}
+	kind = strings.ToLower(kind)
+	if kind != TrustAnchorsSourceConfigMap && kind != TrustAnchorsSourceSecret {
+		return nil, fmt.Errorf("trust anchors source '%s' has an unsupported kind: supported values are '%s' and '%s'", val, TrustAnchorsSourceConfigMap, TrustAnchorsSourceSecret)
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return nil, fmt.Errorf("trust anchors source '%s' has an invalid name: %s", val, strings.Join(errs, ", "))
+	}
+
+	return &TrustAnchorsSource{
+		Kind: kind,
+		Name: name,
+	}, nil
+}
+
+// String implements fmt.Stringer.
+func (s TrustAnchorsSource) String() string {
+	return s.Kind + "/" + s.Name
+}
+
+// checkTrustAnchorsSource returns an error if the trust anchors source doesn't exist in the pod's namespace.
+// The check is performed only if TrustAnchorsSourceExists is set. If the lookup fails, the error is logged and the pod is allowed.
+func (c *SidecarConfig) checkTrustAnchorsSource() error {
+	if c.TrustAnchorsSource == nil || c.TrustAnchorsSourceExists == nil {
+		return nil
+	}
+
+	exists, err := c.TrustAnchorsSourceExists(*c.TrustAnchorsSource, c.Namespace)
+	if err != nil {
+		log.Warnf("Could not verify that trust anchors source '%s' exists in namespace '%s': %v", c.TrustAnchorsSource, c.Namespace, err)
+		return nil
+	}
+	if !exists {
+		return fmt.Errorf("trust anchors source '%s' does not exist in namespace '%s'", c.TrustAnchorsSource, c.Namespace)
+	}
+	return nil
+}
+
+// getTrustAnchorsEnv returns the environment variable with the trust anchors for daprd, which reads them from this variable only.
+// Custom trust anchors are read from the key in the ConfigMap or Secret; otherwise, the injector's current trust anchors are used.
+func (c *SidecarConfig) getTrustAnchorsEnv() corev1.EnvVar {
+	if c.TrustAnchorsSource == nil {
+		return corev1.EnvVar{
+			Name:  securityConsts.TrustAnchorsEnvVar,
+			Value: string(c.CurrentTrustAnchors),
+		}
+	}
+
+	source := &corev1.EnvVarSource{}
+	switch c.TrustAnchorsSource.Kind {
+	case TrustAnchorsSourceSecret:
+		source.SecretKeyRef = &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: c.TrustAnchorsSource.Name,
+			},
+			Key: injectorConsts.TrustAnchorsKey,
+		}
+	default:
+		source.ConfigMapKeyRef = &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{
+				Name: c.TrustAnchorsSource.Name,
+			},
+			Key: injectorConsts.TrustAnchorsKey,
+		}
+	}
+	return corev1.EnvVar{
+		Name:      securityConsts.TrustAnchorsEnvVar,
+		ValueFrom: source,
+	}
+}
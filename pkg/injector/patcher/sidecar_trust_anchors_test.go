@@ -0,0 +1,164 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+	securityConsts "github.com/dapr/dapr/pkg/security/consts"
+)
+
+func TestParseTrustAnchorsSource(t *testing.T) {
+	testCases := []struct {
+		input  string
+		expect *TrustAnchorsSource
+		expErr bool
+	}{
+		{"", nil, false},
+		{"configmap/my-ca", &TrustAnchorsSource{Kind: TrustAnchorsSourceConfigMap, Name: "my-ca"}, false},
+		{"ConfigMap/my-ca", &TrustAnchorsSource{Kind: TrustAnchorsSourceConfigMap, Name: "my-ca"}, false},
+		{"secret/my-ca", &TrustAnchorsSource{Kind: TrustAnchorsSourceSecret, Name: "my-ca"}, false},
+		{"my-ca", nil, true},
+		{"pod/my-ca", nil, true},
+		{"secret/My_CA", nil, true},
+		{"secret/", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			source, err := ParseTrustAnchorsSource(tc.input)
+			if tc.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, source)
+		})
+	}
+}
+
+func TestTrustAnchorsSource(t *testing.T) {
+	getPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "app", Image: "app:1.0"},
+				},
+			},
+		}
+	}
+
+	patchPod := func(t *testing.T, source *TrustAnchorsSource, existsFn TrustAnchorsSourceExistsFn) (*corev1.Pod, error) {
+		t.Helper()
+
+		pod := getPod()
+		c := NewSidecarConfig(pod)
+		c.Namespace = "testns"
+		c.SidecarImage = "daprio/daprd"
+		c.CurrentTrustAnchors = []byte("-----BEGIN CERTIFICATE-----")
+		c.TrustAnchorsSource = source
+		c.TrustAnchorsSourceExists = existsFn
+		c.SetFromPodAnnotations()
+
+		patch, err := c.GetPatch()
+		if err != nil {
+			return nil, err
+		}
+		newPod, err := PatchPod(pod, patch)
+		require.NoError(t, err)
+		return newPod, nil
+	}
+
+	findTrustAnchorsEnv := func(t *testing.T, pod *corev1.Pod) corev1.EnvVar {
+		t.Helper()
+
+		for _, env := range pod.Spec.Containers[1].Env {
+			if env.Name == securityConsts.TrustAnchorsEnvVar {
+				return env
+			}
+		}
+		require.Fail(t, "env var not found", securityConsts.TrustAnchorsEnvVar)
+		return corev1.EnvVar{}
+	}
+
+	t.Run("current trust anchors by default", func(t *testing.T) {
+		pod, err := patchPod(t, nil, nil)
+		require.NoError(t, err)
+
+		env := findTrustAnchorsEnv(t, pod)
+		assert.Equal(t, "-----BEGIN CERTIFICATE-----", env.Value)
+		assert.Nil(t, env.ValueFrom)
+	})
+
+	t.Run("read from a ConfigMap", func(t *testing.T) {
+		pod, err := patchPod(t, &TrustAnchorsSource{Kind: TrustAnchorsSourceConfigMap, Name: "my-ca"}, nil)
+		require.NoError(t, err)
+
+		env := findTrustAnchorsEnv(t, pod)
+		assert.Empty(t, env.Value)
+		require.NotNil(t, env.ValueFrom)
+		assert.Nil(t, env.ValueFrom.SecretKeyRef)
+		require.NotNil(t, env.ValueFrom.ConfigMapKeyRef)
+		assert.Equal(t, "my-ca", env.ValueFrom.ConfigMapKeyRef.Name)
+		assert.Equal(t, "ca.crt", env.ValueFrom.ConfigMapKeyRef.Key)
+
+		// Custom trust anchors are not mounted, since daprd reads them from the environment only
+		for _, v := range pod.Spec.Volumes {
+			assert.Nil(t, v.ConfigMap, v.Name)
+		}
+		assert.NotContains(t, pod.Spec.Containers[1].Args, "--trust-anchors-file")
+	})
+
+	t.Run("read from a Secret", func(t *testing.T) {
+		pod, err := patchPod(t, &TrustAnchorsSource{Kind: TrustAnchorsSourceSecret, Name: "my-ca"}, nil)
+		require.NoError(t, err)
+
+		env := findTrustAnchorsEnv(t, pod)
+		require.NotNil(t, env.ValueFrom)
+		assert.Nil(t, env.ValueFrom.ConfigMapKeyRef)
+		require.NotNil(t, env.ValueFrom.SecretKeyRef)
+		assert.Equal(t, "my-ca", env.ValueFrom.SecretKeyRef.Name)
+		assert.Equal(t, "ca.crt", env.ValueFrom.SecretKeyRef.Key)
+	})
+
+	t.Run("source must exist when validation is enabled", func(t *testing.T) {
+		source := &TrustAnchorsSource{Kind: TrustAnchorsSourceSecret, Name: "my-ca"}
+
+		_, err := patchPod(t, source, func(s TrustAnchorsSource, namespace string) (bool, error) {
+			assert.Equal(t, *source, s)
+			assert.Equal(t, "testns", namespace)
+			return false, nil
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "secret/my-ca")
+
+		_, err = patchPod(t, source, func(s TrustAnchorsSource, namespace string) (bool, error) {
+			return true, nil
+		})
+		require.NoError(t, err)
+	})
+}
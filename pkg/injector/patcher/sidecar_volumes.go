@@ -14,11 +14,15 @@ limitations under the License.
 package patcher
 
 import (
+	"fmt"
+	"path"
 	"strings"
 
 	jsonpatch "github.com/evanphx/json-patch/v5"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 
+	"github.com/dapr/dapr/pkg/injector/annotations"
 	injectorConsts "github.com/dapr/dapr/pkg/injector/consts"
 	securityConsts "github.com/dapr/dapr/pkg/security/consts"
 	"github.com/dapr/kit/ptr"
@@ -88,6 +92,100 @@ func (c *SidecarConfig) getTokenVolume() corev1.Volume {
 	}
 }
 
+// getSharedMemoryVolumeMount returns the in-memory volume and the volume mount for the sidecar's shared memory, sized with SidecarSharedMemorySize.
+func (c *SidecarConfig) getSharedMemoryVolumeMount() (vol corev1.Volume, volMount corev1.VolumeMount, err error) {
+	size, err := resource.ParseQuantity(c.SidecarSharedMemorySize)
+	if err != nil {
+		return vol, volMount, fmt.Errorf("invalid value for annotation %s: %w", annotations.KeySidecarSharedMemorySize, err)
+	}
+	if size.Sign() <= 0 {
+		return vol, volMount, fmt.Errorf("invalid value for annotation %s: must be a positive quantity", annotations.KeySidecarSharedMemorySize)
+	}
+
+	vol = corev1.Volume{
+		Name: injectorConsts.SharedMemoryVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{
+				Medium:    corev1.StorageMediumMemory,
+				SizeLimit: &size,
+			},
+		},
+	}
+
+	volMount = corev1.VolumeMount{
+		Name:      injectorConsts.SharedMemoryVolumeName,
+		MountPath: injectorConsts.SharedMemoryVolumeMountPath,
+	}
+
+	return vol, volMount, nil
+}
+
+// getTmpVolumeMount returns the emptyDir volume and the volume mount for a writable /tmp in the sidecar.
+func (c *SidecarConfig) getTmpVolumeMount() (vol corev1.Volume, volMount corev1.VolumeMount) {
+	vol = corev1.Volume{
+		Name: injectorConsts.TmpVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	}
+
+	volMount = corev1.VolumeMount{
+		Name:      injectorConsts.TmpVolumeName,
+		MountPath: injectorConsts.TmpVolumeMountPath,
+	}
+
+	return vol, volMount
+}
+
+// needsTmpVolume returns true if the sidecar container has a read-only root filesystem and doesn't already mount a volume at /tmp (e.g. with the volume mounts annotations).
+func needsTmpVolume(container *corev1.Container) bool {
+	if container.SecurityContext == nil || container.SecurityContext.ReadOnlyRootFilesystem == nil || !*container.SecurityContext.ReadOnlyRootFilesystem {
+		return false
+	}
+	for _, vm := range container.VolumeMounts {
+		if path.Clean(vm.MountPath) == injectorConsts.TmpVolumeMountPath {
+			return false
+		}
+	}
+	return true
+}
+
+// getAdditionalCAVolumeMount returns the projected volume and the volume mount for the sidecar with the CA certificates from the AdditionalCASecrets.
+// Each secret must be in the namespace of the pod and contain the certificate in the "ca.crt" key; certificates are mounted as "<secret name>.crt".
+func (c *SidecarConfig) getAdditionalCAVolumeMount() (vol corev1.Volume, volMount corev1.VolumeMount) {
+	sources := make([]corev1.VolumeProjection, len(c.AdditionalCASecrets))
+	for i, name := range c.AdditionalCASecrets {
+		sources[i] = corev1.VolumeProjection{
+			Secret: &corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: name,
+				},
+				Items: []corev1.KeyToPath{{
+					Key:  injectorConsts.AdditionalCAFileName,
+					Path: name + ".crt",
+				}},
+			},
+		}
+	}
+
+	vol = corev1.Volume{
+		Name: injectorConsts.AdditionalCAVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: sources,
+			},
+		},
+	}
+
+	volMount = corev1.VolumeMount{
+		Name:      injectorConsts.AdditionalCAVolumeName,
+		MountPath: injectorConsts.AdditionalCAVolumeMountPath,
+		ReadOnly:  true,
+	}
+
+	return
+}
+
 func addVolumeMountToContainers(containers map[int]corev1.Container, addMounts corev1.VolumeMount) jsonpatch.Patch {
 	volumeMount := []corev1.VolumeMount{addMounts}
 	volumeMountPatchOps := make(jsonpatch.Patch, 0, len(containers))
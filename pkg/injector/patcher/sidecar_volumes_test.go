@@ -14,11 +14,15 @@ limitations under the License.
 package patcher
 
 import (
+	"encoding/json"
 	"testing"
 
 	jsonpatch "github.com/evanphx/json-patch/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	injectorConsts "github.com/dapr/dapr/pkg/injector/consts"
 )
@@ -219,6 +223,192 @@ func TestGetUnixDomainSocketVolume(t *testing.T) {
 	assert.Equal(t, "/tmp", appMount.MountPath)
 }
 
+func TestGetSharedMemoryVolumeMount(t *testing.T) {
+	t.Run("valid size", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{})
+		c.SidecarSharedMemorySize = "256Mi"
+
+		volume, daprdMount, err := c.getSharedMemoryVolumeMount()
+		require.NoError(t, err)
+
+		assert.Equal(t, injectorConsts.SharedMemoryVolumeName, volume.Name)
+		require.NotNil(t, volume.VolumeSource.EmptyDir)
+		assert.Equal(t, corev1.StorageMediumMemory, volume.VolumeSource.EmptyDir.Medium)
+		require.NotNil(t, volume.VolumeSource.EmptyDir.SizeLimit)
+		assert.True(t, volume.VolumeSource.EmptyDir.SizeLimit.Equal(resource.MustParse("256Mi")))
+		assert.Equal(t, injectorConsts.SharedMemoryVolumeName, daprdMount.Name)
+		assert.Equal(t, "/dev/shm", daprdMount.MountPath)
+		assert.False(t, daprdMount.ReadOnly)
+	})
+
+	t.Run("invalid size", func(t *testing.T) {
+		for _, size := range []string{"lots", "0", "-1Gi"} {
+			c := NewSidecarConfig(&corev1.Pod{})
+			c.SidecarSharedMemorySize = size
+
+			_, _, err := c.getSharedMemoryVolumeMount()
+			require.Error(t, err, size)
+		}
+	})
+
+	t.Run("volume is added to the patch", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					"dapr.io/enabled":          "true",
+					"dapr.io/sidecar-shm-size": "1Gi",
+				},
+			},
+		})
+		c.SidecarImage = "daprio/daprd"
+		c.SetFromPodAnnotations()
+
+		patch, err := c.GetPatch()
+		require.NoError(t, err)
+
+		found := false
+		for _, op := range patch {
+			path, _ := op.Path()
+			if path != PatchPathVolumes {
+				continue
+			}
+			var volumes []corev1.Volume
+			require.NoError(t, json.Unmarshal(*op["value"], &volumes))
+			for _, v := range volumes {
+				if v.Name == injectorConsts.SharedMemoryVolumeName {
+					found = true
+				}
+			}
+		}
+		assert.True(t, found)
+	})
+
+	t.Run("invalid size denies the pod", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+				Annotations: map[string]string{
+					"dapr.io/enabled":          "true",
+					"dapr.io/sidecar-shm-size": "huge",
+				},
+			},
+		})
+		c.SidecarImage = "daprio/daprd"
+		c.SetFromPodAnnotations()
+
+		_, err := c.GetPatch()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "dapr.io/sidecar-shm-size")
+	})
+}
+
+func TestTmpVolume(t *testing.T) {
+	getPatchedPod := func(t *testing.T, readOnlyRootFilesystem bool, an map[string]string) *corev1.Pod {
+		t.Helper()
+
+		an["dapr.io/enabled"] = "true"
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "myapp",
+				Annotations: an,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+				Volumes: []corev1.Volume{
+					{Name: "scratch", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+				},
+			},
+		}
+		c := NewSidecarConfig(pod)
+		c.SidecarImage = "daprio/daprd"
+		c.ReadOnlyRootFilesystem = readOnlyRootFilesystem
+		c.SetFromPodAnnotations()
+
+		patch, err := c.GetPatch()
+		require.NoError(t, err)
+
+		podJSON, err := json.Marshal(pod)
+		require.NoError(t, err)
+		patchedJSON, err := patch.Apply(podJSON)
+		require.NoError(t, err)
+		patched := &corev1.Pod{}
+		require.NoError(t, json.Unmarshal(patchedJSON, patched))
+		return patched
+	}
+
+	hasTmpVolume := func(pod *corev1.Pod) bool {
+		for _, v := range pod.Spec.Volumes {
+			if v.Name == injectorConsts.TmpVolumeName {
+				return true
+			}
+		}
+		return false
+	}
+
+	getTmpMounts := func(pod *corev1.Pod) []corev1.VolumeMount {
+		var mounts []corev1.VolumeMount
+		for _, c := range pod.Spec.Containers {
+			for _, vm := range c.VolumeMounts {
+				if vm.MountPath == "/tmp" {
+					mounts = append(mounts, vm)
+				}
+			}
+		}
+		return mounts
+	}
+
+	t.Run("read-only root filesystem", func(t *testing.T) {
+		pod := getPatchedPod(t, true, map[string]string{})
+
+		assert.True(t, hasTmpVolume(pod))
+		mounts := getTmpMounts(pod)
+		require.Len(t, mounts, 1)
+		assert.Equal(t, injectorConsts.TmpVolumeName, mounts[0].Name)
+		assert.False(t, mounts[0].ReadOnly)
+	})
+
+	t.Run("writable root filesystem", func(t *testing.T) {
+		pod := getPatchedPod(t, false, map[string]string{})
+
+		assert.False(t, hasTmpVolume(pod))
+		assert.Empty(t, getTmpMounts(pod))
+	})
+
+	t.Run("/tmp already mounted with annotations", func(t *testing.T) {
+		pod := getPatchedPod(t, true, map[string]string{
+			"dapr.io/volume-mounts-rw": "scratch:/tmp",
+		})
+
+		assert.False(t, hasTmpVolume(pod))
+		mounts := getTmpMounts(pod)
+		require.Len(t, mounts, 1)
+		assert.Equal(t, "scratch", mounts[0].Name)
+	})
+}
+
+func TestGetAdditionalCAVolumeMount(t *testing.T) {
+	c := NewSidecarConfig(&corev1.Pod{})
+	c.AdditionalCASecrets = []string{"corp-ca", "partner-ca"}
+
+	volume, daprdMount := c.getAdditionalCAVolumeMount()
+
+	assert.Equal(t, injectorConsts.AdditionalCAVolumeName, volume.Name)
+	require.NotNil(t, volume.VolumeSource.Projected)
+	require.Len(t, volume.VolumeSource.Projected.Sources, 2)
+	for i, name := range []string{"corp-ca", "partner-ca"} {
+		source := volume.VolumeSource.Projected.Sources[i]
+		require.NotNil(t, source.Secret)
+		assert.Equal(t, name, source.Secret.Name)
+		assert.Equal(t, []corev1.KeyToPath{{Key: "ca.crt", Path: name + ".crt"}}, source.Secret.Items)
+	}
+	assert.Equal(t, injectorConsts.AdditionalCAVolumeName, daprdMount.Name)
+	assert.Equal(t, injectorConsts.AdditionalCAVolumeMountPath, daprdMount.MountPath)
+	assert.True(t, daprdMount.ReadOnly)
+}
+
 func TestAddVolumeToContainers(t *testing.T) {
 	testCases := []struct {
 		testName      string
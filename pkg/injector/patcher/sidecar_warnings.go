@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+)
+
+// GetWarnings returns the soft issues found in the configuration of the pod.
+// These don't block the injection of the sidecar, but are returned to the user as admission warnings (e.g. shown by `kubectl apply`).
+func (c *SidecarConfig) GetWarnings() []string {
+	if !c.NeedsPatching() {
+		return nil
+	}
+
+	warnings := []string{}
+
+	if _, ok := c.pod.GetAnnotations()[annotations.KeyAppSSL]; ok {
+		warnings = append(warnings, fmt.Sprintf("annotation %s is deprecated: use %s with 'https' or 'grpcs' instead", annotations.KeyAppSSL, annotations.KeyAppProtocol))
+	}
+
+	if _, ok := c.pod.GetAnnotations()[annotations.KeyAppHealthCheckPath]; ok && c.EnableAppHealthCheck && c.isGRPCApp() {
+		warnings = append(warnings, fmt.Sprintf("annotation %s is ignored: health checks of gRPC apps use the gRPC health checking protocol", annotations.KeyAppHealthCheckPath))
+	}
+
+	if _, ok := c.pod.GetAnnotations()[annotations.KeyPlacementHostAddresses]; ok && c.DisablePlacement {
+		warnings = append(warnings, fmt.Sprintf("annotation %s is ignored: the placement service is disabled with annotation %s", annotations.KeyPlacementHostAddresses, annotations.KeyDisablePlacement))
+	}
+
+	if c.EnableMTLS != nil && c.ForcedMTLSEnabled != nil && *c.EnableMTLS != *c.ForcedMTLSEnabled {
+		warnings = append(warnings, fmt.Sprintf("annotation %s is ignored: mTLS is enforced as %t for the namespace", annotations.KeyEnableMTLS, *c.ForcedMTLSEnabled))
+	}
+
+	if c.NativeSidecar != nil && *c.NativeSidecar && !c.EnableNativeSidecars {
+		warnings = append(warnings, fmt.Sprintf("annotation %s is ignored: native sidecars are not enabled in the injector", annotations.KeyNativeSidecar))
+	}
+
+	if c.PodRuntimeClass != "" && c.pod.Spec.RuntimeClassName != nil && *c.pod.Spec.RuntimeClassName != c.PodRuntimeClass {
+		warnings = append(warnings, fmt.Sprintf("annotation %s is ignored: the pod already sets runtime class '%s'", annotations.KeyPodRuntimeClass, *c.pod.Spec.RuntimeClassName))
+	}
+
+	if c.AppPort <= 0 {
+		if c.EnableAppHealthCheck {
+			warnings = append(warnings, fmt.Sprintf("annotation %s is enabled but %s is not set: app health checks will not be performed", annotations.KeyEnableAppHealthCheck, annotations.KeyAppPort))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("annotation %s is not set: Dapr will not deliver messages or invocations to the app", annotations.KeyAppPort))
+		}
+	}
+
+	if c.SidecarGuaranteedQoS {
+		cpu := c.SidecarCPULimit
+		if cpu == "" {
+			cpu = c.SidecarCPURequest
+		}
+		q, err := resource.ParseQuantity(cpu)
+		if err == nil && q.MilliValue()%1000 != 0 {
+			warnings = append(warnings, fmt.Sprintf("annotation %s is enabled but the sidecar's CPU is not a whole number of cores: the sidecar will not be assigned exclusive CPUs by the static CPU manager policy", annotations.KeySidecarGuaranteedQoS))
+		}
+	}
+
+	return warnings
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+	"github.com/dapr/kit/ptr"
+)
+
+func TestGetWarnings(t *testing.T) {
+	getWarnings := func(an map[string]string) []string {
+		c := NewSidecarConfig(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: an,
+			},
+		})
+		c.SetFromPodAnnotations()
+		return c.GetWarnings()
+	}
+
+	t.Run("no warnings for a well-configured pod", func(t *testing.T) {
+		warnings := getWarnings(map[string]string{
+			annotations.KeyEnabled: "true",
+			annotations.KeyAppPort: "3000",
+		})
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("no warnings if the pod is not patched", func(t *testing.T) {
+		warnings := getWarnings(map[string]string{
+			annotations.KeyAppSSL: "true",
+		})
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("deprecated app-ssl annotation", func(t *testing.T) {
+		warnings := getWarnings(map[string]string{
+			annotations.KeyEnabled: "true",
+			annotations.KeyAppPort: "3000",
+			annotations.KeyAppSSL:  "true",
+		})
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], annotations.KeyAppSSL)
+	})
+
+	t.Run("missing app port", func(t *testing.T) {
+		warnings := getWarnings(map[string]string{
+			annotations.KeyEnabled: "true",
+		})
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], annotations.KeyAppPort)
+	})
+
+	t.Run("missing app port with app health checks", func(t *testing.T) {
+		warnings := getWarnings(map[string]string{
+			annotations.KeyEnabled:              "true",
+			annotations.KeyEnableAppHealthCheck: "true",
+		})
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], annotations.KeyEnableAppHealthCheck)
+	})
+
+	t.Run("app health check path for a gRPC app", func(t *testing.T) {
+		warnings := getWarnings(map[string]string{
+			annotations.KeyEnabled:              "true",
+			annotations.KeyAppPort:              "3000",
+			annotations.KeyAppProtocol:          "grpc",
+			annotations.KeyEnableAppHealthCheck: "true",
+			annotations.KeyAppHealthCheckPath:   "/health",
+		})
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], annotations.KeyAppHealthCheckPath)
+	})
+
+	t.Run("guaranteed QoS with fractional cpu", func(t *testing.T) {
+		warnings := getWarnings(map[string]string{
+			annotations.KeyEnabled:              "true",
+			annotations.KeyAppPort:              "3000",
+			annotations.KeySidecarGuaranteedQoS: "true",
+			annotations.KeyCPULimit:             "500m",
+			annotations.KeyMemoryLimit:          "256Mi",
+		})
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], annotations.KeySidecarGuaranteedQoS)
+	})
+
+	t.Run("guaranteed QoS with whole cpu", func(t *testing.T) {
+		warnings := getWarnings(map[string]string{
+			annotations.KeyEnabled:              "true",
+			annotations.KeyAppPort:              "3000",
+			annotations.KeySidecarGuaranteedQoS: "true",
+			annotations.KeyCPULimit:             "2",
+			annotations.KeyMemoryLimit:          "256Mi",
+		})
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("placement address with placement disabled", func(t *testing.T) {
+		warnings := getWarnings(map[string]string{
+			annotations.KeyEnabled:                "true",
+			annotations.KeyAppPort:                "3000",
+			annotations.KeyDisablePlacement:       "true",
+			annotations.KeyPlacementHostAddresses: "placement:50005",
+		})
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], annotations.KeyPlacementHostAddresses)
+	})
+
+	t.Run("native sidecar not enabled in the injector", func(t *testing.T) {
+		warnings := getWarnings(map[string]string{
+			annotations.KeyEnabled:       "true",
+			annotations.KeyAppPort:       "3000",
+			annotations.KeyNativeSidecar: "true",
+		})
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], annotations.KeyNativeSidecar)
+	})
+
+	t.Run("mTLS annotation overridden by the namespace", func(t *testing.T) {
+		c := NewSidecarConfig(&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					annotations.KeyEnabled:    "true",
+					annotations.KeyAppPort:    "3000",
+					annotations.KeyEnableMTLS: "false",
+				},
+			},
+		})
+		c.SetFromPodAnnotations()
+		c.ForcedMTLSEnabled = ptr.Of(true)
+		warnings := c.GetWarnings()
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], annotations.KeyEnableMTLS)
+
+		c.ForcedMTLSEnabled = ptr.Of(false)
+		assert.Empty(t, c.GetWarnings())
+	})
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// WindowsPodMode controls what the injector does with pods that are scheduled on Windows nodes.
+type WindowsPodMode string
+
+const (
+	// WindowsPodInject injects the sidecar in Windows pods like in any other pod. This is the default.
+	WindowsPodInject WindowsPodMode = "inject"
+	// WindowsPodSkip doesn't inject the sidecar in Windows pods.
+	WindowsPodSkip WindowsPodMode = "skip"
+	// WindowsPodWindows injects the sidecar in Windows pods with the Windows sidecar image, if configured, and a security context compatible with Windows.
+	WindowsPodWindows WindowsPodMode = "windows"
+)
+
+// ParseWindowsPodMode parses a string into a WindowsPodMode.
+// An empty string returns the default mode.
+func ParseWindowsPodMode(val string) (WindowsPodMode, error) {
+	switch m := WindowsPodMode(val); m {
+	case "":
+		return WindowsPodInject, nil
+	case WindowsPodInject, WindowsPodSkip, WindowsPodWindows:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid value for Windows pod mode: '%s'", val)
+	}
+}
+
+// IsWindowsPod returns true if the pod is scheduled on Windows nodes, because it sets the OS to Windows or it has a node selector for Windows nodes.
+func IsWindowsPod(pod *corev1.Pod) bool {
+	if pod == nil {
+		return false
+	}
+	if pod.Spec.OS != nil && pod.Spec.OS.Name == corev1.Windows {
+		return true
+	}
+	return pod.Spec.NodeSelector[corev1.LabelOSStable] == string(corev1.Windows)
+}
+
+// podIsSkippedWindowsPod returns true if the pod is a Windows pod and injection in Windows pods is disabled.
+func (c *SidecarConfig) podIsSkippedWindowsPod() bool {
+	if c.OnWindowsPod != WindowsPodSkip || !IsWindowsPod(c.pod) {
+		return false
+	}
+	log.Debugf("Skipping injection for Windows pod")
+	return true
+}
+
+// adjustSecurityContextForWindows removes the options that are not supported on Windows from the security context of the sidecar.
+// Kubernetes rejects pods that set the OS to Windows and have Linux-only options in the security context of a container.
+func adjustSecurityContextForWindows(sc *corev1.SecurityContext) {
+	sc.Capabilities = nil
+	sc.SeccompProfile = nil
+	sc.SELinuxOptions = nil
+	sc.ReadOnlyRootFilesystem = nil
+	sc.AllowPrivilegeEscalation = nil
+	sc.Privileged = nil
+	sc.ProcMount = nil
+	sc.RunAsUser = nil
+	sc.RunAsGroup = nil
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package patcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseWindowsPodMode(t *testing.T) {
+	for val, expect := range map[string]WindowsPodMode{
+		"":        WindowsPodInject,
+		"inject":  WindowsPodInject,
+		"skip":    WindowsPodSkip,
+		"windows": WindowsPodWindows,
+	} {
+		mode, err := ParseWindowsPodMode(val)
+		require.NoError(t, err)
+		assert.Equal(t, expect, mode)
+	}
+
+	_, err := ParseWindowsPodMode("linux")
+	require.Error(t, err)
+}
+
+func TestIsWindowsPod(t *testing.T) {
+	assert.False(t, IsWindowsPod(nil))
+	assert.False(t, IsWindowsPod(&corev1.Pod{}))
+	assert.False(t, IsWindowsPod(&corev1.Pod{
+		Spec: corev1.PodSpec{
+			NodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+		},
+	}))
+	assert.True(t, IsWindowsPod(&corev1.Pod{
+		Spec: corev1.PodSpec{
+			NodeSelector: map[string]string{"kubernetes.io/os": "windows"},
+		},
+	}))
+	assert.True(t, IsWindowsPod(&corev1.Pod{
+		Spec: corev1.PodSpec{
+			OS: &corev1.PodOS{Name: corev1.Windows},
+		},
+	}))
+}
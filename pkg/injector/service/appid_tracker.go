@@ -0,0 +1,110 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Default duration after which an app ID that hasn't been seen again is forgotten.
+const defaultAppIDTrackerTTL = 30 * time.Minute
+
+// appIDTracker keeps a lightweight in-memory record of the app IDs recently seen in each namespace, and of the workload that uses them.
+// It is used to detect app IDs that are shared by different workloads in the same namespace, which causes routing confusion.
+// Because the state is local to the injector instance and expires, this is a best-effort check only.
+type appIDTracker struct {
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]map[string]appIDTrackerEntry // namespace -> app ID -> entry
+	lock    sync.Mutex
+}
+
+type appIDTrackerEntry struct {
+	workload string
+	lastSeen time.Time
+}
+
+func newAppIDTracker(ttl time.Duration) *appIDTracker {
+	return &appIDTracker{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]map[string]appIDTrackerEntry{},
+	}
+}
+
+// Observe records that the app ID is used by the workload in the namespace.
+// If the app ID was recently seen for a different workload, it returns the name of that workload.
+func (t *appIDTracker) Observe(namespace, appID, workload string) (collision string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.now()
+	ns, ok := t.entries[namespace]
+	if ok {
+		t.evictExpired(ns, now)
+	} else {
+		ns = map[string]appIDTrackerEntry{}
+		t.entries[namespace] = ns
+	}
+
+	if existing, ok := ns[appID]; ok && existing.workload != workload {
+		// Keep the existing workload as the owner of the app ID, but refresh it
+		existing.lastSeen = now
+		ns[appID] = existing
+		return existing.workload
+	}
+
+	ns[appID] = appIDTrackerEntry{
+		workload: workload,
+		lastSeen: now,
+	}
+	return ""
+}
+
+// evictExpired removes the entries of a namespace that haven't been seen within the TTL.
+// Only the namespace being observed is scanned, so the work done for each admission doesn't grow with the number of pods in the cluster.
+// Must be invoked while holding the lock.
+func (t *appIDTracker) evictExpired(ns map[string]appIDTrackerEntry, now time.Time) {
+	for appID, e := range ns {
+		if now.Sub(e.lastSeen) > t.ttl {
+			delete(ns, appID)
+		}
+	}
+}
+
+// getPodWorkloadName returns a name that identifies the workload the pod belongs to, so replicas of the same workload share it.
+// For pods owned by a ReplicaSet, the pod template hash is removed so that all revisions of a Deployment are considered the same workload.
+func getPodWorkloadName(pod *corev1.Pod) string {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Controller == nil || !*ref.Controller {
+			continue
+		}
+		name := ref.Name
+		if ref.Kind == "ReplicaSet" {
+			if hash := pod.Labels["pod-template-hash"]; hash != "" {
+				name = strings.TrimSuffix(name, "-"+hash)
+			}
+		}
+		return ref.Kind + "/" + name
+	}
+
+	if pod.Name != "" {
+		return "Pod/" + pod.Name
+	}
+	return "Pod/" + pod.GenerateName
+}
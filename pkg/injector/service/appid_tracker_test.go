@@ -0,0 +1,174 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/dapr/dapr/pkg/client/clientset/versioned/fake"
+	injectorConsts "github.com/dapr/dapr/pkg/injector/consts"
+	"github.com/dapr/dapr/pkg/injector/patcher"
+	"github.com/dapr/kit/ptr"
+)
+
+func TestAppIDTracker(t *testing.T) {
+	now := time.Now()
+	tracker := newAppIDTracker(time.Minute)
+	tracker.now = func() time.Time {
+		return now
+	}
+
+	t.Run("first workload owns the app ID", func(t *testing.T) {
+		assert.Empty(t, tracker.Observe("ns1", "myapp", "Deployment/a"))
+	})
+
+	t.Run("same workload does not collide", func(t *testing.T) {
+		assert.Empty(t, tracker.Observe("ns1", "myapp", "Deployment/a"))
+	})
+
+	t.Run("different workload collides", func(t *testing.T) {
+		assert.Equal(t, "Deployment/a", tracker.Observe("ns1", "myapp", "Deployment/b"))
+	})
+
+	t.Run("different namespace does not collide", func(t *testing.T) {
+		assert.Empty(t, tracker.Observe("ns2", "myapp", "Deployment/b"))
+	})
+
+	t.Run("expired entries are forgotten", func(t *testing.T) {
+		now = now.Add(2 * time.Minute)
+		assert.Empty(t, tracker.Observe("ns1", "myapp", "Deployment/b"))
+		assert.Equal(t, "Deployment/b", tracker.Observe("ns1", "myapp", "Deployment/a"))
+	})
+
+	t.Run("only the observed namespace is evicted", func(t *testing.T) {
+		// The entry in ns2 is expired too, but it's kept until ns2 is observed again
+		assert.Len(t, tracker.entries["ns2"], 1)
+		assert.Empty(t, tracker.Observe("ns2", "myapp", "Deployment/c"))
+		assert.Len(t, tracker.entries["ns2"], 1)
+	})
+}
+
+func TestGetPodWorkloadName(t *testing.T) {
+	t.Run("pod owned by a ReplicaSet", func(t *testing.T) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "myapp-5d8f9c7b6-abcde",
+				Labels: map[string]string{"pod-template-hash": "5d8f9c7b6"},
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "ReplicaSet", Name: "myapp-5d8f9c7b6", Controller: ptr.Of(true)},
+				},
+			},
+		}
+		assert.Equal(t, "ReplicaSet/myapp", getPodWorkloadName(pod))
+	})
+
+	t.Run("pod owned by a StatefulSet", func(t *testing.T) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp-0",
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "StatefulSet", Name: "myapp", Controller: ptr.Of(true)},
+				},
+			},
+		}
+		assert.Equal(t, "StatefulSet/myapp", getPodWorkloadName(pod))
+	})
+
+	t.Run("pod without a controller", func(t *testing.T) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "myapp",
+			},
+		}
+		assert.Equal(t, "Pod/myapp", getPodWorkloadName(pod))
+	})
+}
+
+func TestAppIDCollisionWarning(t *testing.T) {
+	i, err := NewInjector(Options{
+		Config: Config{
+			SidecarImage:        "test-image",
+			Namespace:           "dapr-system",
+			AppIDCollisionCheck: "true",
+		},
+		DaprClient: fake.NewSimpleClientset(),
+		KubeClient: kubernetesfake.NewSimpleClientset(),
+	})
+	require.NoError(t, err)
+	inj := i.(*injector)
+	inj.currentTrustAnchors = func() ([]byte, error) {
+		return nil, nil
+	}
+	inj.signDaprdCertificate = func(context.Context, string) ([]byte, []byte, error) {
+		return []byte("test-cert"), []byte("test-key"), nil
+	}
+
+	patchPod := func(t *testing.T, name string, owner string) *corev1.Pod {
+		t.Helper()
+
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Annotations: map[string]string{
+					"dapr.io/enabled": "true",
+					"dapr.io/app-id":  "myapp",
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "StatefulSet", Name: owner, Controller: ptr.Of(true)},
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+		podBytes, err := json.Marshal(pod)
+		require.NoError(t, err)
+
+		patch, _, err := inj.getPodPatchOperations(context.Background(), &admissionv1.AdmissionReview{
+			Request: &admissionv1.AdmissionRequest{
+				Namespace: "default",
+				Object:    runtime.RawExtension{Raw: podBytes},
+			},
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, patch)
+
+		newPod, err := patcher.PatchPod(pod, patch)
+		require.NoError(t, err)
+		return newPod
+	}
+
+	first := patchPod(t, "first-0", "first")
+	assert.NotContains(t, first.Annotations, injectorConsts.AppIDCollisionAnnotation)
+
+	replica := patchPod(t, "first-1", "first")
+	assert.NotContains(t, replica.Annotations, injectorConsts.AppIDCollisionAnnotation)
+
+	second := patchPod(t, "second-0", "second")
+	assert.Equal(t, "StatefulSet/first", second.Annotations[injectorConsts.AppIDCollisionAnnotation])
+}
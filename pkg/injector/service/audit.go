@@ -0,0 +1,201 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+	"github.com/dapr/dapr/pkg/injector/patcher"
+)
+
+// Supported sinks for the audit records.
+const (
+	auditSinkStdout  = "stdout"
+	auditSinkWebhook = "webhook"
+)
+
+// Decisions recorded in the audit records.
+const (
+	auditDecisionInject = "inject"
+	auditDecisionSkip   = "skip"
+	auditDecisionDeny   = "deny"
+)
+
+// Default number of audit records that are buffered before new records are dropped.
+const defaultAuditBufferSize = 1000
+
+// Timeout for delivering an audit record to the webhook.
+const auditWebhookTimeout = 5 * time.Second
+
+// auditRecord is the record emitted for each admission decision.
+type auditRecord struct {
+	Time         time.Time `json:"time"`
+	UID          string    `json:"uid,omitempty"`
+	Namespace    string    `json:"namespace"`
+	Name         string    `json:"name,omitempty"`
+	AppID        string    `json:"appID,omitempty"`
+	Decision     string    `json:"decision"`
+	SidecarImage string    `json:"sidecarImage,omitempty"`
+	PatchOps     int       `json:"patchOps"`
+	Reason       string    `json:"reason,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// newAuditRecord returns the audit record for the result of getPodPatchOperations.
+func newAuditRecord(ar *admissionv1.AdmissionReview, pod *corev1.Pod, entry patchCacheEntry, patch jsonpatch.Patch, err error) auditRecord {
+	rec := auditRecord{
+		Time:      time.Now().UTC(),
+		UID:       string(ar.Request.UID),
+		Namespace: ar.Request.Namespace,
+		Name:      pod.Name,
+		AppID:     entry.appID,
+		PatchOps:  len(patch),
+	}
+	if rec.Name == "" {
+		rec.Name = pod.GenerateName
+	}
+	if rec.AppID == "" {
+		rec.AppID = pod.Annotations[annotations.KeyAppID]
+	}
+
+	switch {
+	case err != nil:
+		rec.Decision = auditDecisionDeny
+		rec.Reason = string(patcher.GetDenialReason(err))
+		rec.Error = err.Error()
+	case len(patch) == 0:
+		rec.Decision = auditDecisionSkip
+	default:
+		rec.Decision = auditDecisionInject
+		rec.SidecarImage = entry.sidecarImage
+	}
+	return rec
+}
+
+// recordSkippedRequest emits the audit record for an admission request that is skipped before the pod is reviewed, if enabled.
+func (i *injector) recordSkippedRequest(ar *admissionv1.AdmissionReview) {
+	if i.audit == nil {
+		return
+	}
+	pod := &corev1.Pod{}
+	pod.Name = ar.Request.Name
+	i.audit.Record(newAuditRecord(ar, pod, patchCacheEntry{}, nil, nil))
+}
+
+// auditWriterFn delivers a serialized audit record to the sink.
+type auditWriterFn func(ctx context.Context, data []byte) error
+
+// auditLogger emits audit records asynchronously.
+// Records are queued in a bounded buffer, and are dropped if the buffer is full, so a slow sink never delays admission requests.
+type auditLogger struct {
+	records chan auditRecord
+	write   auditWriterFn
+	dropped atomic.Int64
+}
+
+func newAuditLogger(write auditWriterFn, bufferSize int) *auditLogger {
+	if bufferSize <= 0 {
+		bufferSize = defaultAuditBufferSize
+	}
+	return &auditLogger{
+		records: make(chan auditRecord, bufferSize),
+		write:   write,
+	}
+}
+
+// Record queues a record, dropping it if the buffer is full.
+func (a *auditLogger) Record(rec auditRecord) {
+	select {
+	case a.records <- rec:
+	default:
+		dropped := a.dropped.Add(1)
+		log.Warnf("Audit buffer is full: dropped audit record for pod '%s' in namespace '%s' (%d records dropped so far)", rec.Name, rec.Namespace, dropped)
+	}
+}
+
+// Start delivers the queued records in background, until the context is canceled.
+func (a *auditLogger) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case rec := <-a.records:
+				data, err := json.Marshal(rec)
+				if err != nil {
+					log.Warnf("Failed to serialize audit record: %v", err)
+					continue
+				}
+				err = a.write(ctx, data)
+				if err != nil {
+					log.Warnf("Failed to deliver audit record: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// newAuditWriter returns the function that delivers audit records to the sink set in the configuration.
+func newAuditWriter(cfg Config) auditWriterFn {
+	switch cfg.AuditSink {
+	case auditSinkWebhook:
+		return newWebhookAuditWriter(&http.Client{Timeout: auditWebhookTimeout}, cfg.AuditWebhook)
+	default:
+		return newStreamAuditWriter(os.Stdout)
+	}
+}
+
+// newStreamAuditWriter returns a function that writes audit records to a stream as JSON lines.
+func newStreamAuditWriter(w io.Writer) auditWriterFn {
+	return func(_ context.Context, data []byte) error {
+		_, err := w.Write(append(data, '\n'))
+		return err
+	}
+}
+
+// newWebhookAuditWriter returns a function that sends audit records to a webhook with a POST request.
+func newWebhookAuditWriter(client *http.Client, url string) auditWriterFn {
+	return func(ctx context.Context, data []byte) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		_, _ = io.Copy(io.Discard, res.Body)
+
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			return fmt.Errorf("webhook responded with status code %d", res.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestAuditRecords(t *testing.T) {
+	getPod := func(an map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "myapp",
+				Namespace:   "default",
+				Annotations: an,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+	}
+
+	newInjector := func(t *testing.T) (*injector, chan auditRecord) {
+		inj := newTestInjector(t, Config{AuditSink: "stdout"})
+		require.NotNil(t, inj.audit)
+
+		records := make(chan auditRecord, 10)
+		inj.audit.write = func(_ context.Context, data []byte) error {
+			var rec auditRecord
+			require.NoError(t, json.Unmarshal(data, &rec))
+			records <- rec
+			return nil
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		inj.audit.Start(ctx)
+		return inj, records
+	}
+
+	waitForRecord := func(t *testing.T, records chan auditRecord) auditRecord {
+		select {
+		case rec := <-records:
+			return rec
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the audit record")
+			return auditRecord{}
+		}
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		inj := newTestInjector(t, Config{})
+		assert.Nil(t, inj.audit)
+	})
+
+	t.Run("inject decision", func(t *testing.T) {
+		inj, records := newInjector(t)
+		_, err := patchTestPod(t, inj, getPod(map[string]string{
+			"dapr.io/enabled": "true",
+			"dapr.io/app-id":  "myapp",
+		}))
+		require.NoError(t, err)
+
+		rec := waitForRecord(t, records)
+		assert.Equal(t, auditDecisionInject, rec.Decision)
+		assert.Equal(t, "myapp", rec.AppID)
+		assert.Equal(t, "default", rec.Namespace)
+		assert.Equal(t, "myapp", rec.Name)
+		assert.Equal(t, "test-image", rec.SidecarImage)
+		assert.Greater(t, rec.PatchOps, 0)
+		assert.Empty(t, rec.Error)
+	})
+
+	t.Run("skip decision", func(t *testing.T) {
+		inj, records := newInjector(t)
+		_, err := patchTestPod(t, inj, getPod(nil))
+		require.NoError(t, err)
+
+		rec := waitForRecord(t, records)
+		assert.Equal(t, auditDecisionSkip, rec.Decision)
+		assert.Equal(t, "default", rec.Namespace)
+		assert.Empty(t, rec.SidecarImage)
+		assert.Equal(t, 0, rec.PatchOps)
+	})
+
+	t.Run("deny decision", func(t *testing.T) {
+		inj, records := newInjector(t)
+		_, err := patchTestPod(t, inj, getPod(map[string]string{
+			"dapr.io/enabled": "true",
+			"dapr.io/app-id":  "my_app",
+		}))
+		require.Error(t, err)
+
+		rec := waitForRecord(t, records)
+		assert.Equal(t, auditDecisionDeny, rec.Decision)
+		assert.Equal(t, "my_app", rec.AppID)
+		assert.Equal(t, "InvalidAppID", rec.Reason)
+		assert.NotEmpty(t, rec.Error)
+	})
+
+	t.Run("skipped requests", func(t *testing.T) {
+		podBytes, err := json.Marshal(getPod(map[string]string{
+			"dapr.io/enabled": "true",
+			"dapr.io/app-id":  "myapp",
+		}))
+		require.NoError(t, err)
+
+		tests := map[string]admissionv1.AdmissionRequest{
+			"kind is not pod": {
+				Kind:     metav1.GroupVersionKind{Version: "v1", Kind: "Deployment"},
+				UserInfo: authenticationv1.UserInfo{Groups: []string{systemGroup}},
+			},
+			"service account is not allowed": {
+				Kind:     metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+				UserInfo: authenticationv1.UserInfo{Username: "system:serviceaccount:default:other"},
+			},
+		}
+		for name, req := range tests {
+			req := req
+			t.Run(name, func(t *testing.T) {
+				inj, records := newInjector(t)
+
+				req.UID = "test-uid"
+				req.Name = "myapp"
+				req.Namespace = "default"
+				req.Operation = admissionv1.Create
+				req.Object = runtime.RawExtension{Raw: podBytes}
+				body, err := json.Marshal(admissionv1.AdmissionReview{Request: &req})
+				require.NoError(t, err)
+
+				r := httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader(body))
+				r.Header.Set("Content-Type", runtime.ContentTypeJSON)
+				w := httptest.NewRecorder()
+				inj.handleRequest(w, r)
+				require.Equal(t, http.StatusOK, w.Code)
+
+				rec := waitForRecord(t, records)
+				assert.Equal(t, auditDecisionSkip, rec.Decision)
+				assert.Equal(t, "test-uid", rec.UID)
+				assert.Equal(t, "default", rec.Namespace)
+				assert.Equal(t, "myapp", rec.Name)
+				assert.Equal(t, 0, rec.PatchOps)
+			})
+		}
+	})
+}
+
+func TestAuditLoggerDropsWhenFull(t *testing.T) {
+	a := newAuditLogger(func(context.Context, []byte) error { return nil }, 2)
+
+	// The logger isn't started, so records stay in the buffer
+	for i := 0; i < 5; i++ {
+		a.Record(auditRecord{Namespace: "default"})
+	}
+	assert.Len(t, a.records, 2)
+	assert.Equal(t, int64(3), a.dropped.Load())
+}
+
+func TestAuditWriters(t *testing.T) {
+	t.Run("stream", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		write := newStreamAuditWriter(buf)
+		require.NoError(t, write(context.Background(), []byte(`{"a":1}`)))
+		require.NoError(t, write(context.Background(), []byte(`{"a":2}`)))
+		assert.Equal(t, "{\"a\":1}\n{\"a\":2}\n", buf.String())
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		received := make(chan []byte, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			body, _ := io.ReadAll(r.Body)
+			received <- body
+		}))
+		defer srv.Close()
+
+		write := newWebhookAuditWriter(srv.Client(), srv.URL)
+		require.NoError(t, write(context.Background(), []byte(`{"a":1}`)))
+		assert.Equal(t, `{"a":1}`, string(<-received))
+	})
+
+	t.Run("webhook error status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+
+		write := newWebhookAuditWriter(srv.Client(), srv.URL)
+		require.Error(t, write(context.Background(), []byte(`{"a":1}`)))
+	})
+}
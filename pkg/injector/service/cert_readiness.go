@@ -0,0 +1,111 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Interval for checking the expiry of the serving certificate.
+const defaultCertExpiryCheckInterval = 10 * time.Second
+
+// ReadinessReporter receives the changes in the readiness of the injector, for example to update the healthz server.
+type ReadinessReporter interface {
+	Ready()
+	NotReady()
+}
+
+// ReportReadiness waits for the injector to be ready, then reports it as ready until the context is canceled.
+// If CertExpiryReadinessBuffer is set, the injector is reported as not ready while its serving certificate expires within the buffer, so traffic is moved to other replicas until the certificate is rotated.
+func (i *injector) ReportReadiness(ctx context.Context, reporter ReadinessReporter) error {
+	err := i.Ready(ctx)
+	if err != nil {
+		return err
+	}
+	reporter.Ready()
+
+	buffer := i.config.GetCertExpiryReadinessBuffer()
+	if buffer <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(i.certExpiryCheckInterval)
+	defer ticker.Stop()
+	ready := true
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			err = i.checkServingCertificateExpiry(time.Now(), buffer)
+			switch {
+			case err != nil && ready:
+				log.Warnf("Reporting the sidecar injector as not ready: %v", err)
+				reporter.NotReady()
+				ready = false
+			case err == nil && !ready:
+				log.Info("Serving certificate was renewed: reporting the sidecar injector as ready")
+				reporter.Ready()
+				ready = true
+			}
+		}
+	}
+}
+
+// checkServingCertificateExpiry returns an error if the serving certificate of the injector expires within the buffer.
+func (i *injector) checkServingCertificateExpiry(now time.Time, buffer time.Duration) error {
+	cert, err := getServingCertificate(i.server.TLSConfig)
+	if err != nil {
+		return fmt.Errorf("failed to get the serving certificate: %w", err)
+	}
+	if expiresIn := cert.NotAfter.Sub(now); expiresIn < buffer {
+		return fmt.Errorf("the serving certificate expires at %s, which is within the buffer of %v", cert.NotAfter.UTC().Format(time.RFC3339), buffer)
+	}
+	return nil
+}
+
+// getServingCertificate returns the parsed leaf certificate served with the TLS configuration.
+func getServingCertificate(tlsConfig *tls.Config) (*x509.Certificate, error) {
+	if tlsConfig == nil {
+		return nil, errors.New("the TLS configuration is not set")
+	}
+
+	var (
+		cert *tls.Certificate
+		err  error
+	)
+	switch {
+	case tlsConfig.GetCertificate != nil:
+		cert, err = tlsConfig.GetCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			return nil, err
+		}
+	case len(tlsConfig.Certificates) > 0:
+		cert = &tlsConfig.Certificates[0]
+	}
+	if cert == nil || len(cert.Certificate) == 0 {
+		return nil, errors.New("no certificate in the TLS configuration")
+	}
+
+	if cert.Leaf != nil {
+		return cert.Leaf, nil
+	}
+	return x509.ParseCertificate(cert.Certificate[0])
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServingCertificate returns a self-signed certificate that expires at notAfter.
+func newTestServingCertificate(t *testing.T, notAfter time.Time) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dapr-sidecar-injector"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+}
+
+type fakeReadinessReporter struct {
+	ready atomic.Bool
+}
+
+func (r *fakeReadinessReporter) Ready() {
+	r.ready.Store(true)
+}
+
+func (r *fakeReadinessReporter) NotReady() {
+	r.ready.Store(false)
+}
+
+func TestCheckServingCertificateExpiry(t *testing.T) {
+	now := time.Now()
+
+	newInjector := func(t *testing.T, tlsConfig *tls.Config) *injector {
+		inj := newTestInjector(t, Config{})
+		inj.server = &http.Server{TLSConfig: tlsConfig} //nolint:gosec
+		return inj
+	}
+
+	t.Run("certificate not near expiry", func(t *testing.T) {
+		cert := newTestServingCertificate(t, now.Add(24*time.Hour))
+		inj := newInjector(t, &tls.Config{Certificates: []tls.Certificate{cert}}) //nolint:gosec
+		assert.NoError(t, inj.checkServingCertificateExpiry(now, time.Hour))
+	})
+
+	t.Run("certificate near expiry", func(t *testing.T) {
+		cert := newTestServingCertificate(t, now.Add(30*time.Second))
+		inj := newInjector(t, &tls.Config{Certificates: []tls.Certificate{cert}}) //nolint:gosec
+		err := inj.checkServingCertificateExpiry(now, time.Minute)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "within the buffer")
+	})
+
+	t.Run("certificate from GetCertificate", func(t *testing.T) {
+		cert := newTestServingCertificate(t, now.Add(30*time.Second))
+		inj := newInjector(t, &tls.Config{ //nolint:gosec
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return &cert, nil
+			},
+		})
+		assert.NoError(t, inj.checkServingCertificateExpiry(now, 10*time.Second))
+		assert.Error(t, inj.checkServingCertificateExpiry(now, time.Minute))
+	})
+
+	t.Run("no certificate", func(t *testing.T) {
+		inj := newInjector(t, &tls.Config{}) //nolint:gosec
+		assert.Error(t, inj.checkServingCertificateExpiry(now, time.Minute))
+	})
+}
+
+func TestReportReadiness(t *testing.T) {
+	startInjector := func(t *testing.T, cfg Config, cert *atomic.Pointer[tls.Certificate]) *fakeReadinessReporter {
+		inj := newTestInjector(t, cfg)
+		inj.certExpiryCheckInterval = 10 * time.Millisecond
+		inj.server = &http.Server{ //nolint:gosec
+			TLSConfig: &tls.Config{ //nolint:gosec
+				GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+					return cert.Load(), nil
+				},
+			},
+		}
+		close(inj.ready)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		reporter := &fakeReadinessReporter{}
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- inj.ReportReadiness(ctx, reporter)
+		}()
+		t.Cleanup(func() {
+			cancel()
+			require.NoError(t, <-errCh)
+		})
+		return reporter
+	}
+
+	t.Run("ready without buffer", func(t *testing.T) {
+		cert := &atomic.Pointer[tls.Certificate]{}
+		nearExpiry := newTestServingCertificate(t, time.Now().Add(time.Second))
+		cert.Store(&nearExpiry)
+
+		reporter := startInjector(t, Config{}, cert)
+		assert.Eventually(t, reporter.ready.Load, 5*time.Second, 10*time.Millisecond)
+		assert.Never(t, func() bool { return !reporter.ready.Load() }, 100*time.Millisecond, 10*time.Millisecond)
+	})
+
+	t.Run("not ready while the certificate is near expiry", func(t *testing.T) {
+		cert := &atomic.Pointer[tls.Certificate]{}
+		valid := newTestServingCertificate(t, time.Now().Add(24*time.Hour))
+		cert.Store(&valid)
+
+		reporter := startInjector(t, Config{CertExpiryReadinessBuffer: "1h"}, cert)
+		assert.Eventually(t, reporter.ready.Load, 5*time.Second, 10*time.Millisecond)
+
+		// Certificate is about to expire
+		nearExpiry := newTestServingCertificate(t, time.Now().Add(time.Minute))
+		cert.Store(&nearExpiry)
+		assert.Eventually(t, func() bool { return !reporter.ready.Load() }, 5*time.Second, 10*time.Millisecond)
+
+		// Certificate is rotated
+		renewed := newTestServingCertificate(t, time.Now().Add(24*time.Hour))
+		cert.Store(&renewed)
+		assert.Eventually(t, reporter.ready.Load, 5*time.Second, 10*time.Millisecond)
+	})
+}
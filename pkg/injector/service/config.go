@@ -15,17 +15,32 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 
+	"github.com/dapr/dapr/pkg/injector/namespacednamematcher"
+	"github.com/dapr/dapr/pkg/injector/patcher"
 	"github.com/dapr/dapr/utils"
+	"github.com/dapr/kit/logger"
 )
 
 // Config represents configuration options for the Dapr Sidecar Injector webhook server.
 type Config struct {
 	SidecarImage                      string `envconfig:"SIDECAR_IMAGE" required:"true"`
 	SidecarImagePullPolicy            string `envconfig:"SIDECAR_IMAGE_PULL_POLICY"`
+	SidecarTerminationMessagePolicy   string `envconfig:"SIDECAR_TERMINATION_MESSAGE_POLICY"`
 	Namespace                         string `envconfig:"NAMESPACE" required:"true"`
 	KubeClusterDomain                 string `envconfig:"KUBE_CLUSTER_DOMAIN"`
 	AllowedServiceAccounts            string `envconfig:"ALLOWED_SERVICE_ACCOUNTS"`
@@ -35,12 +50,86 @@ type Config struct {
 	RunAsNonRoot                      string `envconfig:"SIDECAR_RUN_AS_NON_ROOT"`
 	ReadOnlyRootFilesystem            string `envconfig:"SIDECAR_READ_ONLY_ROOT_FILESYSTEM"`
 	SidecarDropALLCapabilities        string `envconfig:"SIDECAR_DROP_ALL_CAPABILITIES"`
+	SidecarEnableMetrics              string `envconfig:"SIDECAR_ENABLE_METRICS"`
+	SidecarRunAsUser                  string `envconfig:"SIDECAR_RUN_AS_USER"`
+	SidecarRunAsGroup                 string `envconfig:"SIDECAR_RUN_AS_GROUP"`
+	SidecarFSGroup                    string `envconfig:"SIDECAR_FS_GROUP"`
+	SidecarAllowedIDRange             string `envconfig:"SIDECAR_ALLOWED_ID_RANGE"`
+	SidecarMemoryAsFractionOfApp      string `envconfig:"SIDECAR_MEMORY_AS_FRACTION_OF_APP"`
+	OnAmbiguousAppContainer           string `envconfig:"ON_AMBIGUOUS_APP_CONTAINER"`
+	OnWindowsPod                      string `envconfig:"ON_WINDOWS_POD"`
+	WindowsSidecarImage               string `envconfig:"WINDOWS_SIDECAR_IMAGE"`
+	InjectPortEnvIntoApp              string `envconfig:"INJECT_PORT_ENV_INTO_APP"`
+	SkipGenerateNamePatterns          string `envconfig:"SKIP_GENERATE_NAME_PATTERNS"`
+	OnlyInjectForOwnerKinds           string `envconfig:"ONLY_INJECT_FOR_OWNER_KINDS"`
+	SidecarDownwardAPIEnv             string `envconfig:"SIDECAR_DOWNWARD_API_ENV"`
+	RuntimeClassAdjustments           string `envconfig:"RUNTIME_CLASS_ADJUSTMENTS"`
+	DefaultPodAnnotations             string `envconfig:"DEFAULT_POD_ANNOTATIONS"`
+	ForcedAnnotations                 string `envconfig:"FORCED_ANNOTATIONS"`
+	ImageDigestMap                    string `envconfig:"IMAGE_DIGEST_MAP"`
+	RequiredPodQoS                    string `envconfig:"REQUIRED_POD_QOS"`
+	AdditionalCASecrets               string `envconfig:"ADDITIONAL_CA_SECRETS"`
+	CostAllocationLabels              string `envconfig:"COST_ALLOCATION_LABELS"`
+	SidecarHostAliases                string `envconfig:"SIDECAR_HOST_ALIASES"`
+	SidecarTopologySpread             string `envconfig:"SIDECAR_TOPOLOGY_SPREAD"`
+	ResourceProfiles                  string `envconfig:"SIDECAR_RESOURCE_PROFILES"`
+	DefaultResourceProfile            string `envconfig:"SIDECAR_DEFAULT_RESOURCE_PROFILE"`
+	NamespaceResourceProfiles         string `envconfig:"NAMESPACE_RESOURCE_PROFILES"`
+	NamespaceLogLevels                string `envconfig:"NAMESPACE_LOG_LEVELS"`
+	DefaultConfigPerNamespace         string `envconfig:"DEFAULT_CONFIG_PER_NAMESPACE"`
+	EnforceMTLSPerNamespace           string `envconfig:"ENFORCE_MTLS_PER_NAMESPACE"`
+	AppIDCollisionCheck               string `envconfig:"APP_ID_COLLISION_CHECK"`
+	AnnotateSidecarAddedResources     string `envconfig:"ANNOTATE_SIDECAR_ADDED_RESOURCES"`
+	RequireSidecarLimits              string `envconfig:"REQUIRE_SIDECAR_LIMITS"`
+	AutoRemapPorts                    string `envconfig:"AUTO_REMAP_PORTS"`
+	RequireAppID                      string `envconfig:"REQUIRE_APP_ID"`
+	ForbidInsecureAppProtocol         string `envconfig:"FORBID_INSECURE_APP_PROTOCOL"`
+	SetPodSeccompRuntimeDefault       string `envconfig:"SET_POD_SECCOMP_RUNTIME_DEFAULT"`
+	WarnBroadSecretScopes             string `envconfig:"WARN_BROAD_SECRET_SCOPES"`
+	NamespaceLabelSelector            string `envconfig:"NAMESPACE_LABEL_SELECTOR"`
+	AdmissionCacheTTL                 string `envconfig:"ADMISSION_CACHE_TTL"`
+	CertExpiryReadinessBuffer         string `envconfig:"CERT_EXPIRY_READINESS_BUFFER"`
+	AuditSink                         string `envconfig:"AUDIT_SINK"`
+	AuditWebhook                      string `envconfig:"AUDIT_WEBHOOK"`
+	SidecarQuotaFailOpen              string `envconfig:"SIDECAR_QUOTA_FAIL_OPEN"`
+	EnableTracing                     string `envconfig:"ENABLE_TRACING"`
+	EnableNativeSidecars              string `envconfig:"ENABLE_NATIVE_SIDECARS"`
+	AllowControlPlaneAddresses        string `envconfig:"ALLOW_CONTROL_PLANE_ADDRESSES"`
+	MinAppContainers                  int    `envconfig:"MIN_APP_CONTAINERS"`
+	MaxAppContainers                  int    `envconfig:"MAX_APP_CONTAINERS"`
+	AdmissionCacheSize                int    `envconfig:"ADMISSION_CACHE_SIZE"`
+	AuditBufferSize                   int    `envconfig:"AUDIT_BUFFER_SIZE"`
+	MaxPatchWarnBytes                 int    `envconfig:"MAX_PATCH_WARN_BYTES"`
+	MaxInjectedSidecars               int    `envconfig:"MAX_INJECTED_SIDECARS"`
 
-	TrustAnchorsFile        string `envconfig:"DAPR_TRUST_ANCHORS_FILE"`
-	ControlPlaneTrustDomain string `envconfig:"DAPR_CONTROL_PLANE_TRUST_DOMAIN"`
-	SentryAddress           string `envconfig:"DAPR_SENTRY_ADDRESS"`
+	// Default values for the sidecar liveness probe; 0 means the built-in default
+	SidecarLivenessProbeDelaySeconds  int32 `envconfig:"SIDECAR_LIVENESS_PROBE_DELAY_SECONDS"`
+	SidecarLivenessProbePeriodSeconds int32 `envconfig:"SIDECAR_LIVENESS_PROBE_PERIOD_SECONDS"`
+	SidecarLivenessProbeThreshold     int32 `envconfig:"SIDECAR_LIVENESS_PROBE_THRESHOLD"`
 
-	parsedEntrypointTolerations []corev1.Toleration
+	TrustAnchorsFile           string `envconfig:"DAPR_TRUST_ANCHORS_FILE"`
+	TrustAnchorsSource         string `envconfig:"SIDECAR_TRUST_ANCHORS_SOURCE"`
+	ValidateTrustAnchorsSource string `envconfig:"VALIDATE_SIDECAR_TRUST_ANCHORS_SOURCE"`
+	ControlPlaneTrustDomain    string `envconfig:"DAPR_CONTROL_PLANE_TRUST_DOMAIN"`
+	SentryAddress              string `envconfig:"DAPR_SENTRY_ADDRESS"`
+
+	// Namespaces, other than the injector's, that pods can select with the control plane namespace annotation
+	AllowedControlPlaneNamespaces string `envconfig:"ALLOWED_CONTROL_PLANE_NAMESPACES"`
+
+	parsedEntrypointTolerations   []corev1.Toleration
+	parsedSidecarHostAliases      []corev1.HostAlias
+	parsedSidecarTopologySpread   []corev1.TopologySpreadConstraint
+	parsedRuntimeClassAdjustments map[string]corev1.SecurityContext
+	parsedDefaultPodAnnotations   map[string]string
+	parsedForcedAnnotations       map[string]string
+	parsedImageDigests            map[string]string
+	parsedResourceProfiles        map[string]patcher.ResourceProfile
+
+	parsedNamespaceResourceProfiles *namespacednamematcher.NamespaceValueMatcher
+	parsedNamespaceLogLevels        *namespacednamematcher.NamespaceValueMatcher
+	parsedNamespaceConfigs          *namespacednamematcher.NamespaceValueMatcher
+	parsedNamespaceMTLS             *namespacednamematcher.NamespaceValueMatcher
+	parsedNamespaceLabelSelector    labels.Selector
 }
 
 // NewConfigWithDefaults returns a Config object with default values already
@@ -74,8 +163,6 @@ func GetConfig() (Config, error) {
 		}
 	}
 
-	c.parseTolerationsJSON()
-
 	return c, nil
 }
 
@@ -92,10 +179,56 @@ func (c Config) GetPullPolicy() corev1.PullPolicy {
 	}
 }
 
+func (c Config) GetTerminationMessagePolicy() corev1.TerminationMessagePolicy {
+	switch c.SidecarTerminationMessagePolicy {
+	case "File":
+		return corev1.TerminationMessageReadFile
+	default:
+		// Default is FallbackToLogsOnError so crash diagnostics are reported even if daprd doesn't write the termination message file
+		return corev1.TerminationMessageFallbackToLogsOnError
+	}
+}
+
 func (c *Config) GetIgnoreEntrypointTolerations() []corev1.Toleration {
 	return c.parsedEntrypointTolerations
 }
 
+// GetSidecarHostAliases returns the host aliases that are added to the pods that are injected, so the sidecar can resolve internal hostnames.
+func (c *Config) GetSidecarHostAliases() []corev1.HostAlias {
+	return c.parsedSidecarHostAliases
+}
+
+// GetSidecarTopologySpread returns the topology spread constraints that are added to the pods that are injected, so Dapr workloads are spread across zones or nodes.
+func (c *Config) GetSidecarTopologySpread() []corev1.TopologySpreadConstraint {
+	return c.parsedSidecarTopologySpread
+}
+
+// GetRuntimeClassAdjustments returns the security context adjustments to apply to the sidecar, keyed by the pod's runtimeClassName.
+func (c *Config) GetRuntimeClassAdjustments() map[string]corev1.SecurityContext {
+	return c.parsedRuntimeClassAdjustments
+}
+
+// GetDefaultPodAnnotations returns the annotations to add to the pods that are injected, unless already set.
+func (c *Config) GetDefaultPodAnnotations() map[string]string {
+	return c.parsedDefaultPodAnnotations
+}
+
+// GetForcedAnnotations returns the annotations that are enforced on the pods that are injected, overriding the values set by users.
+// The precedence is: forced annotations, then annotations set on the pod, then the injector's defaults (including the default pod annotations).
+func (c *Config) GetForcedAnnotations() map[string]string {
+	return c.parsedForcedAnnotations
+}
+
+// GetImageDigestMap returns the digests that sidecar images are pinned by, keyed by the image reference with the tag.
+func (c *Config) GetImageDigestMap() map[string]string {
+	return c.parsedImageDigests
+}
+
+// GetResourceProfiles returns the resource profiles for the sidecar, keyed by name.
+func (c *Config) GetResourceProfiles() map[string]patcher.ResourceProfile {
+	return c.parsedResourceProfiles
+}
+
 func (c *Config) GetRunAsNonRoot() bool {
 	// Default is true if empty
 	if c.RunAsNonRoot == "" {
@@ -112,16 +245,509 @@ func (c *Config) GetReadOnlyRootFilesystem() bool {
 	return utils.IsTruthy(c.ReadOnlyRootFilesystem)
 }
 
+func (c *Config) GetEnableMetrics() bool {
+	// Default is true if empty
+	if c.SidecarEnableMetrics == "" {
+		return true
+	}
+	return utils.IsTruthy(c.SidecarEnableMetrics)
+}
+
 func (c *Config) GetDropCapabilities() bool {
 	// Default is false if empty
 	return utils.IsTruthy(c.SidecarDropALLCapabilities)
 }
 
+// GetSidecarRunAsUser returns the default user ID for the sidecar, or nil if not set.
+func (c *Config) GetSidecarRunAsUser() *int64 {
+	return parseOptionalID(c.SidecarRunAsUser)
+}
+
+// GetSidecarRunAsGroup returns the default group ID for the sidecar, or nil if not set.
+func (c *Config) GetSidecarRunAsGroup() *int64 {
+	return parseOptionalID(c.SidecarRunAsGroup)
+}
+
+// GetSidecarFSGroup returns the default fsGroup for pods with the sidecar, or nil if not set.
+func (c *Config) GetSidecarFSGroup() *int64 {
+	return parseOptionalID(c.SidecarFSGroup)
+}
+
+// GetSidecarMemoryAsFractionOfApp returns the fraction of the app containers' memory limit that is used as the sidecar's memory limit, when no memory is set for the sidecar.
+// Returns 0 if not set.
+func (c *Config) GetSidecarMemoryAsFractionOfApp() float64 {
+	f, err := strconv.ParseFloat(c.SidecarMemoryAsFractionOfApp, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func (c *Config) GetSidecarAllowedIDRange() patcher.IDRange {
+	r, err := patcher.ParseIDRange(c.SidecarAllowedIDRange)
+	if err != nil {
+		return patcher.DefaultIDRange
+	}
+	return r
+}
+
+// GetEnableNativeSidecars returns true if the sidecar can be injected as a native sidecar, which requires Kubernetes 1.29 or newer.
+func (c *Config) GetEnableNativeSidecars() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.EnableNativeSidecars)
+}
+
+// GetAllowControlPlaneAddresses returns true if pods can set the addresses of the sentry and operator services with annotations.
+func (c *Config) GetAllowControlPlaneAddresses() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.AllowControlPlaneAddresses)
+}
+
+func (c *Config) GetInjectPortEnvIntoApp() bool {
+	// Default is true if empty
+	if c.InjectPortEnvIntoApp == "" {
+		return true
+	}
+	return utils.IsTruthy(c.InjectPortEnvIntoApp)
+}
+
+// GetSkipGenerateNamePatterns returns the list of glob patterns matched against the generateName of pods that should not be injected.
+func (c *Config) GetSkipGenerateNamePatterns() []string {
+	return splitAndTrim(c.SkipGenerateNamePatterns)
+}
+
+// GetOnlyInjectForOwnerKinds returns the list of kinds of the controllers (e.g. Deployment or StatefulSet) whose pods are injected.
+// If the list is not empty, pods not owned by a controller of one of these kinds are skipped.
+func (c *Config) GetOnlyInjectForOwnerKinds() []string {
+	return splitAndTrim(c.OnlyInjectForOwnerKinds)
+}
+
+func (c *Config) GetAdditionalCASecrets() []string {
+	return splitAndTrim(c.AdditionalCASecrets)
+}
+
+// GetAllowedControlPlaneNamespaces returns the namespaces, other than the injector's, with a control plane that pods can use.
+func (c *Config) GetAllowedControlPlaneNamespaces() []string {
+	return splitAndTrim(c.AllowedControlPlaneNamespaces)
+}
+
+// GetCostAllocationLabels returns the keys of the pod labels that are copied into the sidecar's environment, for chargeback.
+func (c *Config) GetCostAllocationLabels() []string {
+	return splitAndTrim(c.CostAllocationLabels)
+}
+
+func (c *Config) GetAppIDCollisionCheck() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.AppIDCollisionCheck)
+}
+
+func (c *Config) GetAnnotateSidecarAddedResources() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.AnnotateSidecarAddedResources)
+}
+
+func (c *Config) GetRequireSidecarLimits() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.RequireSidecarLimits)
+}
+
+// GetAdmissionCacheTTL returns the duration for which computed patches are cached.
+func (c *Config) GetAdmissionCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(c.AdmissionCacheTTL)
+	if err != nil || ttl <= 0 || ttl > maxPatchCacheTTL {
+		return defaultPatchCacheTTL
+	}
+	return ttl
+}
+
+// GetCertExpiryReadinessBuffer returns how long before the expiry of its serving certificate the injector is reported as not ready, or 0 if disabled.
+func (c *Config) GetCertExpiryReadinessBuffer() time.Duration {
+	buffer, err := time.ParseDuration(c.CertExpiryReadinessBuffer)
+	if err != nil || buffer < 0 {
+		return 0
+	}
+	return buffer
+}
+
+// GetTrustAnchorsSource returns the ConfigMap or Secret with custom trust anchors to mount in the sidecar, or nil if not set.
+func (c *Config) GetTrustAnchorsSource() *patcher.TrustAnchorsSource {
+	source, _ := patcher.ParseTrustAnchorsSource(c.TrustAnchorsSource)
+	return source
+}
+
+func (c *Config) GetValidateTrustAnchorsSource() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.ValidateTrustAnchorsSource)
+}
+
+// GetSidecarDownwardAPIEnv returns true if the downward API env vars are added to the sidecar by default.
+func (c *Config) GetSidecarDownwardAPIEnv() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.SidecarDownwardAPIEnv)
+}
+
+func (c *Config) GetAutoRemapPorts() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.AutoRemapPorts)
+}
+
+func (c *Config) GetRequireAppID() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.RequireAppID)
+}
+
+func (c *Config) GetForbidInsecureAppProtocol() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.ForbidInsecureAppProtocol)
+}
+
+// GetSetPodSeccompRuntimeDefault returns true if the RuntimeDefault seccomp profile is set on the pods that are injected and don't set a profile.
+func (c *Config) GetSetPodSeccompRuntimeDefault() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.SetPodSeccompRuntimeDefault)
+}
+
+// GetSidecarQuotaFailOpen returns true if pods are admitted without the sidecar, rather than denied, once MaxInjectedSidecars is reached.
+func (c *Config) GetSidecarQuotaFailOpen() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.SidecarQuotaFailOpen)
+}
+
+// GetEnableTracing returns true if the injector emits a span for each admission request.
+func (c *Config) GetEnableTracing() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.EnableTracing)
+}
+
+// GetWarnBroadSecretScopes returns true if a warning is returned for pods whose Configuration doesn't restrict the secrets the app can access.
+func (c *Config) GetWarnBroadSecretScopes() bool {
+	// Default is false if empty
+	return utils.IsTruthy(c.WarnBroadSecretScopes)
+}
+
 func (c *Config) GetSkipPlacement() bool {
 	// Default is false if empty
 	return utils.IsTruthy(c.SkipPlacement)
 }
 
+func (c *Config) GetOnAmbiguousAppContainer() patcher.AmbiguousAppContainerMode {
+	mode, _ := patcher.ParseAmbiguousAppContainerMode(c.OnAmbiguousAppContainer)
+	if mode == "" {
+		return patcher.AmbiguousAppContainerAll
+	}
+	return mode
+}
+
+func (c *Config) GetOnWindowsPod() patcher.WindowsPodMode {
+	mode, _ := patcher.ParseWindowsPodMode(c.OnWindowsPod)
+	if mode == "" {
+		return patcher.WindowsPodInject
+	}
+	return mode
+}
+
+// GetRequiredPodQoS returns the QoS class that pods with the sidecar must be in.
+// An empty value means that any QoS class is allowed.
+func (c *Config) GetRequiredPodQoS() corev1.PodQOSClass {
+	class, _ := patcher.ParsePodQOSClass(c.RequiredPodQoS)
+	return class
+}
+
+// validate returns an error if the configuration contains invalid values.
+func (c *Config) validate() error {
+	if err := c.parseJSON(); err != nil {
+		return err
+	}
+	if _, err := patcher.ParseAmbiguousAppContainerMode(c.OnAmbiguousAppContainer); err != nil {
+		return err
+	}
+	if _, err := patcher.ParseWindowsPodMode(c.OnWindowsPod); err != nil {
+		return err
+	}
+	if _, err := patcher.ParsePodQOSClass(c.RequiredPodQoS); err != nil {
+		return err
+	}
+	switch c.SidecarTerminationMessagePolicy {
+	case "", string(corev1.TerminationMessageReadFile), string(corev1.TerminationMessageFallbackToLogsOnError):
+		// Valid
+	default:
+		return fmt.Errorf("invalid value for sidecar termination message policy: '%s'", c.SidecarTerminationMessagePolicy)
+	}
+	if c.MinAppContainers < 0 || c.MaxAppContainers < 0 {
+		return errors.New("min and max app containers must not be negative")
+	}
+	if c.MaxAppContainers > 0 && c.MinAppContainers > c.MaxAppContainers {
+		return fmt.Errorf("min app containers (%d) is greater than max app containers (%d)", c.MinAppContainers, c.MaxAppContainers)
+	}
+	if c.AdmissionCacheSize < 0 {
+		return errors.New("admission cache size must not be negative")
+	}
+	if c.SidecarLivenessProbeDelaySeconds < 0 || c.SidecarLivenessProbePeriodSeconds < 0 || c.SidecarLivenessProbeThreshold < 0 {
+		return errors.New("sidecar liveness probe delay, period, and threshold must not be negative")
+	}
+	if c.AdmissionCacheTTL != "" {
+		ttl, err := time.ParseDuration(c.AdmissionCacheTTL)
+		if err != nil {
+			return fmt.Errorf("invalid value for admission cache TTL: %w", err)
+		}
+		if ttl <= 0 || ttl > maxPatchCacheTTL {
+			return fmt.Errorf("admission cache TTL must be positive and at most %v", maxPatchCacheTTL)
+		}
+	}
+	if c.CertExpiryReadinessBuffer != "" {
+		buffer, err := time.ParseDuration(c.CertExpiryReadinessBuffer)
+		if err != nil {
+			return fmt.Errorf("invalid value for cert expiry readiness buffer: %w", err)
+		}
+		if buffer < 0 {
+			return errors.New("cert expiry readiness buffer must not be negative")
+		}
+	}
+	switch c.AuditSink {
+	case "", auditSinkStdout:
+		// Valid
+	case auditSinkWebhook:
+		u, err := url.Parse(c.AuditWebhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid value for audit webhook: '%s' is not a valid HTTP(S) URL", c.AuditWebhook)
+		}
+	default:
+		return fmt.Errorf("invalid value for audit sink: '%s'", c.AuditSink)
+	}
+	caSecrets := map[string]struct{}{}
+	for _, name := range c.GetAdditionalCASecrets() {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return fmt.Errorf("invalid secret name '%s' in additional CA secrets: %s", name, strings.Join(errs, ", "))
+		}
+		if _, ok := caSecrets[name]; ok {
+			return fmt.Errorf("secret '%s' is listed more than once in additional CA secrets", name)
+		}
+		caSecrets[name] = struct{}{}
+	}
+	for _, ns := range c.GetAllowedControlPlaneNamespaces() {
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+			return fmt.Errorf("invalid namespace '%s' in allowed control plane namespaces: %s", ns, strings.Join(errs, ", "))
+		}
+	}
+	for _, key := range c.GetCostAllocationLabels() {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid label key '%s' in cost allocation labels: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	if c.AuditBufferSize < 0 {
+		return errors.New("audit buffer size must not be negative")
+	}
+	if c.MaxPatchWarnBytes < 0 {
+		return errors.New("max patch warn bytes must not be negative")
+	}
+	if c.MaxInjectedSidecars < 0 {
+		return errors.New("max injected sidecars must not be negative")
+	}
+	idRange, err := patcher.ParseIDRange(c.SidecarAllowedIDRange)
+	if err != nil {
+		return fmt.Errorf("invalid value for sidecar allowed ID range: %w", err)
+	}
+	if c.SidecarMemoryAsFractionOfApp != "" {
+		f, err := strconv.ParseFloat(c.SidecarMemoryAsFractionOfApp, 64)
+		if err != nil || f <= 0 || f > 1 {
+			return fmt.Errorf("invalid value for sidecar memory as fraction of app '%s': must be a number greater than 0 and not greater than 1", c.SidecarMemoryAsFractionOfApp)
+		}
+	}
+	ids := []struct {
+		name string
+		val  string
+	}{
+		{"sidecar run as user", c.SidecarRunAsUser},
+		{"sidecar run as group", c.SidecarRunAsGroup},
+		{"sidecar fs group", c.SidecarFSGroup},
+	}
+	for _, id := range ids {
+		if id.val == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(id.val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", id.name, err)
+		}
+		if !idRange.Contains(v) {
+			return fmt.Errorf("value %d for %s is outside of the allowed range %s", v, id.name, idRange)
+		}
+	}
+	if _, err := patcher.ParseTrustAnchorsSource(c.TrustAnchorsSource); err != nil {
+		return fmt.Errorf("invalid value for sidecar trust anchors source: %w", err)
+	}
+	if c.NamespaceLabelSelector != "" {
+		c.parsedNamespaceLabelSelector, err = labels.Parse(c.NamespaceLabelSelector)
+		if err != nil {
+			return fmt.Errorf("invalid value for namespace label selector: %w", err)
+		}
+	}
+	if err := validateAnnotationKeys("default pod annotations", c.parsedDefaultPodAnnotations); err != nil {
+		return err
+	}
+	if err := validateAnnotationKeys("forced annotations", c.parsedForcedAnnotations); err != nil {
+		return err
+	}
+	for _, alias := range c.parsedSidecarHostAliases {
+		if net.ParseIP(alias.IP) == nil {
+			return fmt.Errorf("invalid IP '%s' in sidecar host aliases", alias.IP)
+		}
+		if len(alias.Hostnames) == 0 {
+			return fmt.Errorf("invalid host alias for IP '%s' in sidecar host aliases: at least one hostname is required", alias.IP)
+		}
+		for _, h := range alias.Hostnames {
+			if errs := validation.IsDNS1123Subdomain(h); len(errs) > 0 {
+				return fmt.Errorf("invalid hostname '%s' in sidecar host aliases: %s", h, strings.Join(errs, ", "))
+			}
+		}
+	}
+	for _, tsc := range c.parsedSidecarTopologySpread {
+		if errs := validation.IsQualifiedName(tsc.TopologyKey); len(errs) > 0 {
+			return fmt.Errorf("invalid topology key '%s' in sidecar topology spread: %s", tsc.TopologyKey, strings.Join(errs, ", "))
+		}
+		if tsc.MaxSkew < 1 {
+			return fmt.Errorf("invalid max skew %d for topology key '%s' in sidecar topology spread: must be a positive number", tsc.MaxSkew, tsc.TopologyKey)
+		}
+		switch tsc.WhenUnsatisfiable {
+		case corev1.DoNotSchedule, corev1.ScheduleAnyway:
+			// Valid
+		default:
+			return fmt.Errorf("invalid value '%s' for whenUnsatisfiable for topology key '%s' in sidecar topology spread: must be '%s' or '%s'", tsc.WhenUnsatisfiable, tsc.TopologyKey, corev1.DoNotSchedule, corev1.ScheduleAnyway)
+		}
+		if tsc.LabelSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(tsc.LabelSelector); err != nil {
+				return fmt.Errorf("invalid label selector for topology key '%s' in sidecar topology spread: %w", tsc.TopologyKey, err)
+			}
+		}
+	}
+	for image, digest := range c.parsedImageDigests {
+		if !patcher.ImageHasTag(image) {
+			return fmt.Errorf("invalid image '%s' in image digest map: must be an image reference with a tag", image)
+		}
+		if !patcher.IsValidImageDigest(digest) {
+			return fmt.Errorf("invalid digest '%s' for image '%s' in image digest map: must be in the format 'sha256:<hex>'", digest, image)
+		}
+	}
+	if err := c.validateResourceProfiles(); err != nil {
+		return err
+	}
+	if c.NamespaceLogLevels != "" {
+		c.parsedNamespaceLogLevels, err = namespacednamematcher.CreateNamespaceValueMatcherFromString(c.NamespaceLogLevels)
+		if err != nil {
+			return fmt.Errorf("invalid value for namespace log levels: %w", err)
+		}
+		for _, level := range c.parsedNamespaceLogLevels.Values() {
+			if !isValidLogLevel(level) {
+				return fmt.Errorf("invalid log level '%s' in namespace log levels", level)
+			}
+		}
+	}
+	if c.DefaultConfigPerNamespace != "" {
+		c.parsedNamespaceConfigs, err = namespacednamematcher.CreateNamespaceValueMatcherFromString(c.DefaultConfigPerNamespace)
+		if err != nil {
+			return fmt.Errorf("invalid value for default config per namespace: %w", err)
+		}
+		for _, name := range c.parsedNamespaceConfigs.Values() {
+			if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+				return fmt.Errorf("invalid configuration name '%s' in default config per namespace: %s", name, strings.Join(errs, ", "))
+			}
+		}
+	}
+	if c.EnforceMTLSPerNamespace != "" {
+		c.parsedNamespaceMTLS, err = namespacednamematcher.CreateNamespaceValueMatcherFromString(c.EnforceMTLSPerNamespace)
+		if err != nil {
+			return fmt.Errorf("invalid value for enforce mTLS per namespace: %w", err)
+		}
+		for _, val := range c.parsedNamespaceMTLS.Values() {
+			if _, err := strconv.ParseBool(val); err != nil {
+				return fmt.Errorf("invalid value '%s' in enforce mTLS per namespace: must be 'true' or 'false'", val)
+			}
+		}
+	}
+	for _, p := range c.GetSkipGenerateNamePatterns() {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern '%s' in skip generateName patterns: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// isValidLogLevel returns true if the value is a log level supported by daprd.
+func isValidLogLevel(level string) bool {
+	switch logger.LogLevel(level) {
+	case logger.DebugLevel, logger.InfoLevel, logger.WarnLevel, logger.ErrorLevel, logger.FatalLevel:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateResourceProfiles returns an error if a resource profile is invalid, or if the default profile or a profile mapped to a namespace doesn't exist.
+func (c *Config) validateResourceProfiles() error {
+	for name, profile := range c.parsedResourceProfiles {
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("invalid resource profile '%s': %w", name, err)
+		}
+	}
+
+	names := []string{}
+	if c.DefaultResourceProfile != "" {
+		names = append(names, c.DefaultResourceProfile)
+	}
+	if c.NamespaceResourceProfiles != "" {
+		var err error
+		c.parsedNamespaceResourceProfiles, err = namespacednamematcher.CreateNamespaceValueMatcherFromString(c.NamespaceResourceProfiles)
+		if err != nil {
+			return fmt.Errorf("invalid value for namespace resource profiles: %w", err)
+		}
+		names = append(names, c.parsedNamespaceResourceProfiles.Values()...)
+	}
+	for _, name := range names {
+		if _, ok := c.parsedResourceProfiles[name]; !ok {
+			return fmt.Errorf("unknown resource profile '%s'", name)
+		}
+	}
+	return nil
+}
+
+// parseOptionalID parses a user or group ID, returning nil if the value is empty or invalid.
+func parseOptionalID(val string) *int64 {
+	if val == "" {
+		return nil
+	}
+	v, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// validateAnnotationKeys returns an error if any key of the annotations set by the injector's configuration is not a valid annotation key.
+func validateAnnotationKeys(name string, an map[string]string) error {
+	for k := range an {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("invalid key '%s' in %s: %s", k, name, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// splitAndTrim splits a comma-separated list, removing whitespace and empty items.
+func splitAndTrim(val string) []string {
+	if val == "" {
+		return nil
+	}
+	parts := strings.Split(val, ",")
+	res := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
 func (c *Config) parseTolerationsJSON() {
 	if c.IgnoreEntrypointTolerations == "" {
 		return
@@ -137,3 +763,52 @@ func (c *Config) parseTolerationsJSON() {
 
 	c.parsedEntrypointTolerations = ts
 }
+
+// parseJSON parses the values of the options that contain JSON.
+// Entrypoint tolerations that can't be parsed are ignored with a warning, rather than returning an error.
+func (c *Config) parseJSON() (err error) {
+	c.parseTolerationsJSON()
+	c.parsedSidecarHostAliases, err = parseJSONEnv[[]corev1.HostAlias]("sidecar host aliases", c.SidecarHostAliases)
+	if err != nil {
+		return err
+	}
+	c.parsedSidecarTopologySpread, err = parseJSONEnv[[]corev1.TopologySpreadConstraint]("sidecar topology spread", c.SidecarTopologySpread)
+	if err != nil {
+		return err
+	}
+	c.parsedRuntimeClassAdjustments, err = parseJSONEnv[map[string]corev1.SecurityContext]("runtime class adjustments", c.RuntimeClassAdjustments)
+	if err != nil {
+		return err
+	}
+	c.parsedDefaultPodAnnotations, err = parseJSONEnv[map[string]string]("default pod annotations", c.DefaultPodAnnotations)
+	if err != nil {
+		return err
+	}
+	c.parsedForcedAnnotations, err = parseJSONEnv[map[string]string]("forced annotations", c.ForcedAnnotations)
+	if err != nil {
+		return err
+	}
+	c.parsedImageDigests, err = parseJSONEnv[map[string]string]("image digest map", c.ImageDigestMap)
+	if err != nil {
+		return err
+	}
+	c.parsedResourceProfiles, err = parseJSONEnv[map[string]patcher.ResourceProfile]("sidecar resource profiles", c.ResourceProfiles)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseJSONEnv parses the value of an option that contains JSON.
+// An empty value returns the zero value of T.
+func parseJSONEnv[T any](name string, val string) (T, error) {
+	var res T
+	if val == "" {
+		return res, nil
+	}
+	err := json.Unmarshal([]byte(val), &res)
+	if err != nil {
+		return res, fmt.Errorf("invalid value for %s: %w", name, err)
+	}
+	return res, nil
+}
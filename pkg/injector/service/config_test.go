@@ -14,10 +14,14 @@ limitations under the License.
 package service
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+
+	"github.com/dapr/kit/ptr"
 )
 
 func TestGetInjectorConfig(t *testing.T) {
@@ -92,6 +96,25 @@ func TestGetInjectorConfig(t *testing.T) {
 	})
 }
 
+func TestGetInjectPortEnvIntoApp(t *testing.T) {
+	c := NewConfigWithDefaults()
+	assert.True(t, c.GetInjectPortEnvIntoApp(), "default is true")
+
+	c.InjectPortEnvIntoApp = "false"
+	assert.False(t, c.GetInjectPortEnvIntoApp())
+
+	c.InjectPortEnvIntoApp = "1"
+	assert.True(t, c.GetInjectPortEnvIntoApp())
+}
+
+func TestGetSkipGenerateNamePatterns(t *testing.T) {
+	c := NewConfigWithDefaults()
+	assert.Empty(t, c.GetSkipGenerateNamePatterns())
+
+	c.SkipGenerateNamePatterns = "kube-*, ,*-job-* "
+	assert.Equal(t, []string{"kube-*", "*-job-*"}, c.GetSkipGenerateNamePatterns())
+}
+
 func TestImagePullPolicy(t *testing.T) {
 	testCases := []struct {
 		testName       string
@@ -128,6 +151,37 @@ func TestImagePullPolicy(t *testing.T) {
 	}
 }
 
+func TestTerminationMessagePolicy(t *testing.T) {
+	testCases := []struct {
+		testName       string
+		policy         string
+		expectedPolicy corev1.TerminationMessagePolicy
+	}{
+		{
+			"TestDefaultTerminationMessagePolicy",
+			"",
+			corev1.TerminationMessageFallbackToLogsOnError,
+		},
+		{
+			"TestFileTerminationMessagePolicy",
+			"File",
+			corev1.TerminationMessageReadFile,
+		},
+		{
+			"TestFallbackToLogsOnErrorTerminationMessagePolicy",
+			"FallbackToLogsOnError",
+			corev1.TerminationMessageFallbackToLogsOnError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			c := NewConfigWithDefaults()
+			c.SidecarTerminationMessagePolicy = tc.policy
+			assert.Equal(t, tc.expectedPolicy, c.GetTerminationMessagePolicy())
+		})
+	}
+}
+
 func TestTolerationsParsing(t *testing.T) {
 	testCases := []struct {
 		name   string
@@ -186,3 +240,279 @@ func TestTolerationsParsing(t *testing.T) {
 		})
 	}
 }
+
+func TestRuntimeClassAdjustmentsParsing(t *testing.T) {
+	testCases := []struct {
+		name   string
+		expect map[string]corev1.SecurityContext
+		input  string
+		err    bool
+	}{
+		{
+			"empty adjustments",
+			nil,
+			"",
+			false,
+		},
+		{
+			"gVisor adjustments",
+			map[string]corev1.SecurityContext{
+				"gvisor": {
+					ReadOnlyRootFilesystem: ptr.Of(false),
+				},
+			},
+			`{"gvisor":{"readOnlyRootFilesystem":false}}`,
+			false,
+		},
+		{
+			"invalid JSON",
+			nil,
+			`hi`,
+			true,
+		},
+		{
+			"invalid JSON structure",
+			nil,
+			`[]`,
+			true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Config{
+				RuntimeClassAdjustments: tc.input,
+			}
+			err := c.parseJSON()
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.EqualValues(t, tc.expect, c.GetRuntimeClassAdjustments())
+		})
+	}
+}
+
+func TestDefaultPodAnnotationsParsing(t *testing.T) {
+	testCases := []struct {
+		name   string
+		expect map[string]string
+		input  string
+		err    bool
+	}{
+		{
+			"empty annotations",
+			nil,
+			"",
+			false,
+		},
+		{
+			"valid annotations",
+			map[string]string{
+				"sidecar.istio.io/inject": "false",
+				"prometheus.io/scrape":    "true",
+			},
+			`{"sidecar.istio.io/inject":"false","prometheus.io/scrape":"true"}`,
+			false,
+		},
+		{
+			"invalid JSON",
+			nil,
+			`hi`,
+			true,
+		},
+		{
+			"invalid JSON structure",
+			nil,
+			`{"a":1}`,
+			true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Config{
+				DefaultPodAnnotations: tc.input,
+			}
+			err := c.parseJSON()
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.EqualValues(t, tc.expect, c.GetDefaultPodAnnotations())
+		})
+	}
+}
+
+func TestSidecarHostAliases(t *testing.T) {
+	t.Run("parsed from JSON", func(t *testing.T) {
+		c := &Config{
+			SidecarHostAliases: `[{"ip":"10.0.0.10","hostnames":["vault.internal"]}]`,
+		}
+		require.NoError(t, c.parseJSON())
+		assert.Equal(t, []corev1.HostAlias{
+			{IP: "10.0.0.10", Hostnames: []string{"vault.internal"}},
+		}, c.GetSidecarHostAliases())
+		assert.NoError(t, c.validate())
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		c := &Config{
+			SidecarHostAliases: `{"ip":"10.0.0.10"}`,
+		}
+		err := c.validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "sidecar host aliases")
+	})
+
+	invalid := map[string]string{
+		"invalid IP":       `[{"ip":"not-an-ip","hostnames":["vault.internal"]}]`,
+		"no hostnames":     `[{"ip":"10.0.0.10"}]`,
+		"invalid hostname": `[{"ip":"10.0.0.10","hostnames":["Not_Valid"]}]`,
+	}
+	for name, val := range invalid {
+		t.Run(name, func(t *testing.T) {
+			c := &Config{
+				SidecarHostAliases: val,
+			}
+			err := c.validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "sidecar host aliases")
+		})
+	}
+}
+
+func TestSidecarTopologySpread(t *testing.T) {
+	t.Run("parsed from JSON", func(t *testing.T) {
+		c := &Config{
+			SidecarTopologySpread: `[{"maxSkew":1,"topologyKey":"topology.kubernetes.io/zone","whenUnsatisfiable":"ScheduleAnyway"}]`,
+		}
+		require.NoError(t, c.parseJSON())
+		assert.Equal(t, []corev1.TopologySpreadConstraint{
+			{MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: corev1.ScheduleAnyway},
+		}, c.GetSidecarTopologySpread())
+		assert.NoError(t, c.validate())
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		c := &Config{
+			SidecarTopologySpread: `{"maxSkew":1}`,
+		}
+		err := c.validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "sidecar topology spread")
+	})
+
+	invalid := map[string]string{
+		"missing topology key":       `[{"maxSkew":1,"whenUnsatisfiable":"ScheduleAnyway"}]`,
+		"invalid topology key":       `[{"maxSkew":1,"topologyKey":"not a key","whenUnsatisfiable":"ScheduleAnyway"}]`,
+		"invalid max skew":           `[{"maxSkew":0,"topologyKey":"topology.kubernetes.io/zone","whenUnsatisfiable":"ScheduleAnyway"}]`,
+		"missing when unsatisfiable": `[{"maxSkew":1,"topologyKey":"topology.kubernetes.io/zone"}]`,
+		"invalid when unsatisfiable": `[{"maxSkew":1,"topologyKey":"topology.kubernetes.io/zone","whenUnsatisfiable":"Never"}]`,
+		"invalid label selector":     `[{"maxSkew":1,"topologyKey":"topology.kubernetes.io/zone","whenUnsatisfiable":"ScheduleAnyway","labelSelector":{"matchExpressions":[{"key":"app","operator":"Near"}]}}]`,
+	}
+	for name, val := range invalid {
+		t.Run(name, func(t *testing.T) {
+			c := &Config{
+				SidecarTopologySpread: val,
+			}
+			err := c.validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "sidecar topology spread")
+		})
+	}
+}
+
+func TestForcedAnnotations(t *testing.T) {
+	t.Run("parsed from JSON", func(t *testing.T) {
+		c := &Config{
+			ForcedAnnotations: `{"dapr.io/log-as-json":"true"}`,
+		}
+		require.NoError(t, c.parseJSON())
+		assert.Equal(t, map[string]string{"dapr.io/log-as-json": "true"}, c.GetForcedAnnotations())
+		assert.NoError(t, c.validate())
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		c := &Config{
+			ForcedAnnotations: `{"a":1}`,
+		}
+		err := c.validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "forced annotations")
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		c := &Config{
+			ForcedAnnotations: `{"not a/valid/key":"x"}`,
+		}
+		err := c.validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "forced annotations")
+	})
+}
+
+func TestImageDigestMap(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("0f", 32)
+
+	t.Run("parsed from JSON", func(t *testing.T) {
+		c := &Config{
+			ImageDigestMap: `{"daprio/daprd:1.12.0":"` + digest + `","localhost:5000/daprd:edge":"` + digest + `"}`,
+		}
+		require.NoError(t, c.parseJSON())
+		assert.Equal(t, map[string]string{
+			"daprio/daprd:1.12.0":       digest,
+			"localhost:5000/daprd:edge": digest,
+		}, c.GetImageDigestMap())
+		assert.NoError(t, c.validate())
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		c := &Config{
+			ImageDigestMap: `["daprio/daprd:1.12.0"]`,
+		}
+		err := c.validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "image digest map")
+	})
+
+	t.Run("image without a tag", func(t *testing.T) {
+		for _, image := range []string{"daprio/daprd", "localhost:5000/daprd", "daprio/daprd@" + digest} {
+			c := &Config{
+				ImageDigestMap: `{"` + image + `":"` + digest + `"}`,
+			}
+			err := c.validate()
+			require.Error(t, err, image)
+			assert.Contains(t, err.Error(), "image digest map")
+		}
+	})
+
+	t.Run("invalid digest", func(t *testing.T) {
+		for _, d := range []string{"1.12.0", "sha256:abc", "sha512:" + strings.Repeat("0f", 32)} {
+			c := &Config{
+				ImageDigestMap: `{"daprio/daprd:1.12.0":"` + d + `"}`,
+			}
+			err := c.validate()
+			require.Error(t, err, d)
+			assert.Contains(t, err.Error(), "invalid digest")
+		}
+	})
+}
+
+func TestDefaultPodAnnotationsValidation(t *testing.T) {
+	t.Run("valid keys", func(t *testing.T) {
+		c := &Config{
+			DefaultPodAnnotations: `{"sidecar.istio.io/inject":"false","team":"payments"}`,
+		}
+		assert.NoError(t, c.validate())
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		c := &Config{
+			DefaultPodAnnotations: `{"not a/valid/key":"x"}`,
+		}
+		err := c.validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not a/valid/key")
+	})
+}
@@ -15,6 +15,7 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -24,9 +25,13 @@ import (
 	admissionv1 "k8s.io/api/admission/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
+	"github.com/dapr/dapr/pkg/injector/patcher"
 	"github.com/dapr/dapr/utils"
 )
 
+// GroupVersionKind of the admission reviews the webhook is registered for.
+var admissionReviewGVK = admissionv1.SchemeGroupVersion.WithKind("AdmissionReview")
+
 func (i *injector) handleRequest(w http.ResponseWriter, r *http.Request) {
 	RecordSidecarInjectionRequestsCount()
 
@@ -53,22 +58,33 @@ func (i *injector) handleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var patchOps jsonpatch.Patch
+	ctx, span := i.startAdmissionSpan(r)
+
+	var (
+		patchOps jsonpatch.Patch
+		warnings []string
+	)
 	patchedSuccessfully := false
 
 	ar := admissionv1.AdmissionReview{}
 	_, gvk, err := i.deserializer.Decode(body, nil, &ar)
 	if err != nil {
 		log.Errorf("Can't decode body: %v", err)
+		err = patcher.NewDenialError(patcher.DenialReasonMalformedRequest, fmt.Errorf("could not decode the admission review: %w", err))
+	} else if ar.Request == nil {
+		log.Error("Admission review has no request")
+		err = patcher.NewDenialError(patcher.DenialReasonMalformedRequest, errors.New("the admission review has no request"))
 	} else {
 		allowServiceAccountUser := i.allowServiceAccountUser(ar.Request.UserInfo.Username)
 
 		if !(allowServiceAccountUser || utils.Contains(i.authUIDs, ar.Request.UserInfo.UID) || utils.Contains(ar.Request.UserInfo.Groups, systemGroup)) {
 			log.Errorf("service account '%s' not on the list of allowed controller accounts", ar.Request.UserInfo.Username)
+			i.recordSkippedRequest(&ar)
 		} else if ar.Request.Kind.Kind != "Pod" {
 			log.Errorf("invalid kind for review: %s", ar.Kind)
+			i.recordSkippedRequest(&ar)
 		} else {
-			patchOps, err = i.getPodPatchOperations(r.Context(), &ar)
+			patchOps, warnings, err = i.getPodPatchOperations(ctx, &ar)
 			if err == nil {
 				patchedSuccessfully = true
 			}
@@ -76,6 +92,7 @@ func (i *injector) handleRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	diagAppID := getAppIDFromRequest(ar.Request)
+	endAdmissionSpan(span, &ar, diagAppID, patchOps, err)
 
 	var admissionResponse *admissionv1.AdmissionResponse
 	if err != nil {
@@ -84,7 +101,8 @@ func (i *injector) handleRequest(w http.ResponseWriter, r *http.Request) {
 		RecordFailedSidecarInjectionCount(diagAppID, "patch")
 	} else if len(patchOps) == 0 {
 		admissionResponse = &admissionv1.AdmissionResponse{
-			Allowed: true,
+			Allowed:  true,
+			Warnings: warnings,
 		}
 	} else {
 		var patchBytes []byte
@@ -92,9 +110,13 @@ func (i *injector) handleRequest(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			admissionResponse = errorToAdmissionResponse(err)
 		} else {
+			if warning := i.checkPatchSize(diagAppID, len(patchBytes)); warning != "" {
+				warnings = append(warnings, warning)
+			}
 			admissionResponse = &admissionv1.AdmissionResponse{
-				Allowed: true,
-				Patch:   patchBytes,
+				Allowed:  true,
+				Patch:    patchBytes,
+				Warnings: warnings,
 				PatchType: func() *admissionv1.PatchType {
 					pt := admissionv1.PatchTypeJSONPatch
 					return &pt
@@ -108,8 +130,12 @@ func (i *injector) handleRequest(w http.ResponseWriter, r *http.Request) {
 	}
 	if admissionResponse != nil && ar.Request != nil {
 		admissionReview.Response.UID = ar.Request.UID
-		admissionReview.SetGroupVersionKind(*gvk)
 	}
+	if gvk == nil || gvk.Empty() {
+		// The body couldn't be decoded, so respond with the version of the API the webhook is registered for
+		gvk = &admissionReviewGVK
+	}
+	admissionReview.SetGroupVersionKind(*gvk)
 
 	respBytes, err := json.Marshal(admissionReview)
 	if err != nil {
@@ -150,3 +176,14 @@ func (i *injector) allowServiceAccountUser(reviewRequestUserInfo string) (allowe
 	}
 	return i.namespaceNameMatcher.MatchesNamespacedName(namespacedNameParts[0], namespacedNameParts[1])
 }
+
+// checkPatchSize returns a warning, logging it and recording a metric, if the size of the patch exceeds the configured threshold.
+// Very large patches (e.g. because of long lists of env vars) can strain the API server.
+func (i *injector) checkPatchSize(appID string, size int) string {
+	if i.config.MaxPatchWarnBytes <= 0 || size <= i.config.MaxPatchWarnBytes {
+		return ""
+	}
+	log.Warnf("Patch for app '%s' is %d bytes, which exceeds the threshold of %d bytes: very large patches can strain the API server", appID, size, i.config.MaxPatchWarnBytes)
+	RecordOversizedPatchCount(appID)
+	return fmt.Sprintf("the patch that injects the sidecar is %d bytes, which exceeds the threshold of %d bytes", size, i.config.MaxPatchWarnBytes)
+}
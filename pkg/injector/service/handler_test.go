@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	admissionv1 "k8s.io/api/admission/v1"
 	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -113,6 +114,25 @@ func TestHandleRequest(t *testing.T) {
 			http.StatusOK,
 			true,
 		},
+		{
+			"TestSidecarInjectUpdateIsNotPatched",
+			admissionv1.AdmissionReview{
+				Request: &admissionv1.AdmissionRequest{
+					UID:       uuid.NewUUID(),
+					Kind:      metav1.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"},
+					Name:      "test-app",
+					Namespace: "test-ns",
+					Operation: "UPDATE",
+					UserInfo: authenticationv1.UserInfo{
+						Groups: []string{systemGroup},
+					},
+					Object: runtime.RawExtension{Raw: podBytes},
+				},
+			},
+			runtime.ContentTypeJSON,
+			http.StatusOK,
+			false,
+		},
 		{
 			"TestSidecarInjectWrongContentType",
 			admissionv1.AdmissionReview{},
@@ -302,3 +322,213 @@ func TestHandleRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleRequestMalformed(t *testing.T) {
+	i, err := NewInjector(Options{
+		Config: Config{
+			SidecarImage:            "test-image",
+			Namespace:               "test-ns",
+			ControlPlaneTrustDomain: "test-trust-domain",
+		},
+		DaprClient: fake.NewSimpleClientset(),
+		KubeClient: kubernetesfake.NewSimpleClientset(),
+	})
+	require.NoError(t, err)
+	injector := i.(*injector)
+
+	ts := httptest.NewServer(http.HandlerFunc(injector.handleRequest))
+	defer ts.Close()
+
+	getResponse := func(t *testing.T, body []byte) *admissionv1.AdmissionReview {
+		t.Helper()
+
+		resp, err := http.Post(ts.URL, runtime.ContentTypeJSON, bytes.NewBuffer(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var ar admissionv1.AdmissionReview
+		err = json.NewDecoder(resp.Body).Decode(&ar)
+		require.NoError(t, err)
+		require.NotNil(t, ar.Response)
+		return &ar
+	}
+
+	assertMalformedDenial := func(t *testing.T, ar *admissionv1.AdmissionReview) {
+		t.Helper()
+
+		assert.False(t, ar.Response.Allowed)
+		assert.Empty(t, ar.Response.Patch)
+		require.NotNil(t, ar.Response.Result)
+		assert.Equal(t, metav1.StatusFailure, ar.Response.Result.Status)
+		assert.Equal(t, metav1.StatusReason("MalformedRequest"), ar.Response.Result.Reason)
+	}
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		for _, body := range []string{`{`, `not json`, `{"request":"x"}`, `[1,2,3]`} {
+			ar := getResponse(t, []byte(body))
+			assertMalformedDenial(t, ar)
+			assert.Equal(t, "AdmissionReview", ar.Kind)
+			assert.Equal(t, admissionv1.SchemeGroupVersion.String(), ar.APIVersion)
+		}
+	})
+
+	t.Run("no request", func(t *testing.T) {
+		ar := getResponse(t, []byte(`{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview"}`))
+		assertMalformedDenial(t, ar)
+		assert.Contains(t, ar.Response.Result.Message, "no request")
+	})
+
+	t.Run("invalid pod JSON", func(t *testing.T) {
+		uid := uuid.NewUUID()
+		requestBytes, err := json.Marshal(admissionv1.AdmissionReview{
+			Request: &admissionv1.AdmissionRequest{
+				UID:       uid,
+				Kind:      metav1.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"},
+				Name:      "test-app",
+				Namespace: "test-ns",
+				Operation: "CREATE",
+				UserInfo: authenticationv1.UserInfo{
+					Groups: []string{systemGroup},
+				},
+				Object: runtime.RawExtension{Raw: []byte(`{"metadata":{"annotations":"not a map"}}`)},
+			},
+		})
+		require.NoError(t, err)
+
+		ar := getResponse(t, requestBytes)
+		assertMalformedDenial(t, ar)
+		assert.Equal(t, uid, ar.Response.UID)
+		assert.Contains(t, ar.Response.Result.Message, "could not unmarshal raw object")
+	})
+}
+
+func TestHandleRequestWarnings(t *testing.T) {
+	i, err := NewInjector(Options{
+		Config: Config{
+			SidecarImage:            "test-image",
+			Namespace:               "test-ns",
+			ControlPlaneTrustDomain: "test-trust-domain",
+		},
+		DaprClient: fake.NewSimpleClientset(),
+		KubeClient: kubernetesfake.NewSimpleClientset(),
+	})
+	require.NoError(t, err)
+	injector := i.(*injector)
+	injector.currentTrustAnchors = func() ([]byte, error) {
+		return nil, nil
+	}
+	injector.signDaprdCertificate = func(context.Context, string) ([]byte, []byte, error) {
+		return []byte("test-cert"), []byte("test-key"), nil
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(injector.handleRequest))
+	defer ts.Close()
+
+	getResponse := func(t *testing.T, podAnnotations map[string]string) *admissionv1.AdmissionResponse {
+		t.Helper()
+
+		podBytes, err := json.Marshal(corev1.Pod{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "Pod",
+				APIVersion: "v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-app",
+				Namespace:   "default",
+				Annotations: podAnnotations,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name:  "main",
+						Image: "docker.io/app:latest",
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		requestBytes, err := json.Marshal(admissionv1.AdmissionReview{
+			Request: &admissionv1.AdmissionRequest{
+				UID:       uuid.NewUUID(),
+				Kind:      metav1.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"},
+				Name:      "test-app",
+				Namespace: "test-ns",
+				Operation: "CREATE",
+				UserInfo: authenticationv1.UserInfo{
+					Groups: []string{systemGroup},
+				},
+				Object: runtime.RawExtension{Raw: podBytes},
+			},
+		})
+		require.NoError(t, err)
+
+		resp, err := http.Post(ts.URL, runtime.ContentTypeJSON, bytes.NewBuffer(requestBytes))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var ar admissionv1.AdmissionReview
+		err = json.NewDecoder(resp.Body).Decode(&ar)
+		require.NoError(t, err)
+		require.NotNil(t, ar.Response)
+		return ar.Response
+	}
+
+	t.Run("no warnings", func(t *testing.T) {
+		res := getResponse(t, map[string]string{
+			"dapr.io/enabled":  "true",
+			"dapr.io/app-id":   "test-app",
+			"dapr.io/app-port": "3000",
+		})
+		assert.True(t, res.Allowed)
+		assert.NotEmpty(t, res.Patch)
+		assert.Empty(t, res.Warnings)
+	})
+
+	t.Run("warnings for soft issues", func(t *testing.T) {
+		res := getResponse(t, map[string]string{
+			"dapr.io/enabled": "true",
+			"dapr.io/app-id":  "test-app",
+			"dapr.io/app-ssl": "true",
+		})
+		assert.True(t, res.Allowed)
+		assert.NotEmpty(t, res.Patch)
+		require.Len(t, res.Warnings, 2)
+		assert.Contains(t, res.Warnings[0], "dapr.io/app-ssl")
+		assert.Contains(t, res.Warnings[1], "dapr.io/app-port")
+	})
+
+	t.Run("warning for oversized patches", func(t *testing.T) {
+		injector.config.MaxPatchWarnBytes = 100
+		defer func() {
+			injector.config.MaxPatchWarnBytes = 0
+		}()
+
+		res := getResponse(t, map[string]string{
+			"dapr.io/enabled":  "true",
+			"dapr.io/app-id":   "test-app",
+			"dapr.io/app-port": "3000",
+		})
+		assert.True(t, res.Allowed)
+		assert.Greater(t, len(res.Patch), 100)
+		require.Len(t, res.Warnings, 1)
+		assert.Contains(t, res.Warnings[0], "exceeds the threshold of 100 bytes")
+	})
+
+	t.Run("no warning for patches below the threshold", func(t *testing.T) {
+		injector.config.MaxPatchWarnBytes = 1 << 20
+		defer func() {
+			injector.config.MaxPatchWarnBytes = 0
+		}()
+
+		res := getResponse(t, map[string]string{
+			"dapr.io/enabled":  "true",
+			"dapr.io/app-id":   "test-app",
+			"dapr.io/app-port": "3000",
+		})
+		assert.True(t, res.Allowed)
+		assert.Empty(t, res.Warnings)
+	})
+}
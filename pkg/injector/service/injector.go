@@ -23,6 +23,7 @@ import (
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -33,6 +34,7 @@ import (
 	scheme "github.com/dapr/dapr/pkg/client/clientset/versioned"
 	"github.com/dapr/dapr/pkg/injector/annotations"
 	"github.com/dapr/dapr/pkg/injector/namespacednamematcher"
+	"github.com/dapr/dapr/pkg/injector/patcher"
 	"github.com/dapr/kit/logger"
 )
 
@@ -64,6 +66,7 @@ type (
 type Injector interface {
 	Run(context.Context, *tls.Config, signDaprdCertificateFn, currentTrustAnchorsFn) error
 	Ready(context.Context) error
+	ReportReadiness(context.Context, ReadinessReporter) error
 }
 
 type Options struct {
@@ -74,6 +77,9 @@ type Options struct {
 
 	ControlPlaneNamespace   string
 	ControlPlaneTrustDomain string
+
+	// Version of the injector, which is stamped on the injected pods
+	InjectorVersion string
 }
 
 type injector struct {
@@ -86,19 +92,45 @@ type injector struct {
 
 	controlPlaneNamespace   string
 	controlPlaneTrustDomain string
+	injectorVersion         string
 	currentTrustAnchors     currentTrustAnchorsFn
 	signDaprdCertificate    signDaprdCertificateFn
 
 	namespaceNameMatcher *namespacednamematcher.EqualPrefixNameNamespaceMatcher
+	appIDs               *appIDTracker
+	patchCache           *patchCache
+	namespaceLabels      *namespaceLabelMatcher
+	sidecarQuota         *sidecarQuota
+	audit                *auditLogger
+	tracer               trace.Tracer
 	ready                chan struct{}
+
+	// Interval for checking the expiry of the serving certificate, when CertExpiryReadinessBuffer is set
+	certExpiryCheckInterval time.Duration
+
+	// Resource profiles for the sidecar, by namespace
+	namespaceResourceProfiles *namespacednamematcher.NamespaceValueMatcher
+
+	// Log levels for the sidecar, by namespace
+	namespaceLogLevels *namespacednamematcher.NamespaceValueMatcher
+
+	// Names of the default Configuration resources, by namespace
+	namespaceConfigs *namespacednamematcher.NamespaceValueMatcher
+
+	// Enforced values for enabling mTLS, by namespace
+	namespaceMTLS *namespacednamematcher.NamespaceValueMatcher
 }
 
 // errorToAdmissionResponse is a helper function to create an AdmissionResponse
 // with an embedded error.
+// The result includes the reason code for the denial, both in the status' reason and in the message, in the format "DaprReason: <code>".
 func errorToAdmissionResponse(err error) *admissionv1.AdmissionResponse {
+	reason := patcher.GetDenialReason(err)
 	return &admissionv1.AdmissionResponse{
 		Result: &metav1.Status{
-			Message: err.Error(),
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReason(reason),
+			Message: "DaprReason: " + string(reason) + ": " + err.Error(),
 		},
 	}
 }
@@ -130,6 +162,11 @@ func getAppIDFromRequest(req *admissionv1.AdmissionRequest) (appID string) {
 
 // NewInjector returns a new Injector instance with the given config.
 func NewInjector(opts Options) (Injector, error) {
+	err := opts.Config.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid injector configuration: %w", err)
+	}
+
 	mux := http.NewServeMux()
 
 	i := &injector{
@@ -147,6 +184,7 @@ func NewInjector(opts Options) (Injector, error) {
 		authUIDs:                opts.AuthUIDs,
 		controlPlaneNamespace:   opts.ControlPlaneNamespace,
 		controlPlaneTrustDomain: opts.ControlPlaneTrustDomain,
+		injectorVersion:         opts.InjectorVersion,
 		ready:                   make(chan struct{}),
 	}
 
@@ -156,6 +194,35 @@ func NewInjector(opts Options) (Injector, error) {
 	}
 	i.namespaceNameMatcher = matcher
 
+	// Matchers are parsed when the configuration is validated
+	i.namespaceResourceProfiles = opts.Config.parsedNamespaceResourceProfiles
+	i.namespaceLogLevels = opts.Config.parsedNamespaceLogLevels
+	i.namespaceConfigs = opts.Config.parsedNamespaceConfigs
+	i.namespaceMTLS = opts.Config.parsedNamespaceMTLS
+
+	if opts.Config.parsedNamespaceLabelSelector != nil {
+		i.namespaceLabels = newNamespaceLabelMatcher(opts.KubeClient, opts.Config.parsedNamespaceLabelSelector)
+	}
+
+	if opts.Config.MaxInjectedSidecars > 0 {
+		i.sidecarQuota = newSidecarQuota(opts.KubeClient, opts.Config.MaxInjectedSidecars)
+	}
+
+	if opts.Config.GetAppIDCollisionCheck() {
+		i.appIDs = newAppIDTracker(defaultAppIDTrackerTTL)
+	}
+
+	if opts.Config.AdmissionCacheSize > 0 {
+		i.patchCache = newPatchCache(opts.Config.AdmissionCacheSize, opts.Config.GetAdmissionCacheTTL())
+	}
+
+	if opts.Config.AuditSink != "" {
+		i.audit = newAuditLogger(newAuditWriter(opts.Config), opts.Config.AuditBufferSize)
+	}
+
+	i.tracer = newTracer(opts.Config)
+	i.certExpiryCheckInterval = defaultCertExpiryCheckInterval
+
 	mux.HandleFunc("/mutate", i.handleRequest)
 	return i, nil
 }
@@ -227,6 +294,18 @@ func (i *injector) Run(ctx context.Context, tlsConfig *tls.Config, signDaprdFn s
 	i.signDaprdCertificate = signDaprdFn
 	i.server.TLSConfig = tlsConfig
 
+	if i.namespaceLabels != nil {
+		i.namespaceLabels.Start(ctx)
+	}
+
+	if i.sidecarQuota != nil {
+		i.sidecarQuota.Start(ctx)
+	}
+
+	if i.audit != nil {
+		i.audit.Start(ctx)
+	}
+
 	errCh := make(chan error, 1)
 	go func() {
 		err := i.server.ListenAndServeTLS("", "")
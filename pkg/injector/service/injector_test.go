@@ -28,6 +28,7 @@ import (
 	kubernetesfake "k8s.io/client-go/kubernetes/fake"
 
 	"github.com/dapr/dapr/pkg/injector/namespacednamematcher"
+	"github.com/dapr/dapr/pkg/injector/patcher"
 )
 
 func TestConfigCorrectValues(t *testing.T) {
@@ -63,6 +64,366 @@ func TestNewInjectorBadAllowedPrefixedServiceAccountConfig(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestNewInjectorInvalidConfig(t *testing.T) {
+	t.Run("invalid ambiguous app container mode", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:            "c",
+				Namespace:               "e",
+				OnAmbiguousAppContainer: "random",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid skip generateName pattern", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:             "c",
+				Namespace:                "e",
+				SkipGenerateNamePatterns: "valid-*,[invalid",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("min app containers greater than max", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:     "c",
+				Namespace:        "e",
+				MinAppContainers: 3,
+				MaxAppContainers: 2,
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid sidecar allowed ID range", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:          "c",
+				Namespace:             "e",
+				SidecarAllowedIDRange: "2000-1000",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("sidecar user ID outside of the allowed range", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:          "c",
+				Namespace:             "e",
+				SidecarRunAsUser:      "0",
+				SidecarAllowedIDRange: "1000-2000",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("sidecar fs group is not a number", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:   "c",
+				Namespace:      "e",
+				SidecarFSGroup: "root",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid namespace label selector", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:           "c",
+				Namespace:              "e",
+				NamespaceLabelSelector: "dapr.io/inject in (true",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid trust anchors source", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:       "c",
+				Namespace:          "e",
+				TrustAnchorsSource: "pod/my-ca",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid sidecar termination message policy", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:                    "c",
+				Namespace:                       "e",
+				SidecarTerminationMessagePolicy: "Logs",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid admission cache size", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:       "c",
+				Namespace:          "e",
+				AdmissionCacheSize: -1,
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid admission cache TTL", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:      "c",
+				Namespace:         "e",
+				AdmissionCacheTTL: "soon",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("admission cache TTL too long", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:      "c",
+				Namespace:         "e",
+				AdmissionCacheTTL: "1h",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid Windows pod mode", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage: "c",
+				Namespace:    "e",
+				OnWindowsPod: "linux",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("negative sidecar liveness probe period", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:                      "c",
+				Namespace:                         "e",
+				SidecarLivenessProbePeriodSeconds: -1,
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid audit sink", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage: "c",
+				Namespace:    "e",
+				AuditSink:    "syslog",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("audit webhook sink without a valid URL", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage: "c",
+				Namespace:    "e",
+				AuditSink:    "webhook",
+				AuditWebhook: "audit.example.com/records",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid additional CA secret name", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:        "c",
+				Namespace:           "e",
+				AdditionalCASecrets: "corp-ca,Partner_CA",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid sidecar memory as fraction of app", func(t *testing.T) {
+		for _, val := range []string{"half", "0", "-0.5", "1.5"} {
+			_, err := NewInjector(Options{
+				Config: Config{
+					SidecarImage:                 "c",
+					Namespace:                    "e",
+					SidecarMemoryAsFractionOfApp: val,
+				},
+			})
+			assert.Error(t, err, val)
+		}
+	})
+
+	t.Run("negative max patch warn bytes", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:      "c",
+				Namespace:         "e",
+				MaxPatchWarnBytes: -1,
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid namespace log levels mapping", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:       "c",
+				Namespace:          "e",
+				NamespaceLogLevels: "dev-*",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown namespace log level", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:       "c",
+				Namespace:          "e",
+				NamespaceLogLevels: "dev-*=verbose",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("negative max injected sidecars", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:        "c",
+				Namespace:           "e",
+				MaxInjectedSidecars: -1,
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid default config per namespace mapping", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:              "c",
+				Namespace:                 "e",
+				DefaultConfigPerNamespace: "prod-*=",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid configuration name in default config per namespace", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:              "c",
+				Namespace:                 "e",
+				DefaultConfigPerNamespace: "prod-*=My_Config",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid label key in cost allocation labels", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:         "c",
+				Namespace:            "e",
+				CostAllocationLabels: "team,not a label",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid namespace in allowed control plane namespaces", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:                  "c",
+				Namespace:                     "e",
+				AllowedControlPlaneNamespaces: "dapr-tenant-a,Not_Valid",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid cert expiry readiness buffer", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:              "c",
+				Namespace:                 "e",
+				CertExpiryReadinessBuffer: "soon",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("negative cert expiry readiness buffer", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:              "c",
+				Namespace:                 "e",
+				CertExpiryReadinessBuffer: "-1m",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid required pod QoS class", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:   "c",
+				Namespace:      "e",
+				RequiredPodQoS: "premium",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid enforce mTLS per namespace", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:            "c",
+				Namespace:               "e",
+				EnforceMTLSPerNamespace: "prod-*",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid value in enforce mTLS per namespace", func(t *testing.T) {
+		_, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:            "c",
+				Namespace:               "e",
+				EnforceMTLSPerNamespace: "prod-*=always",
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("valid ambiguous app container mode", func(t *testing.T) {
+		i, err := NewInjector(Options{
+			Config: Config{
+				SidecarImage:            "c",
+				Namespace:               "e",
+				OnAmbiguousAppContainer: "deny",
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, patcher.AmbiguousAppContainerDeny, i.(*injector).config.GetOnAmbiguousAppContainer())
+	})
+}
+
 func TestGetAppIDFromRequest(t *testing.T) {
 	t.Run("can handle nil", func(t *testing.T) {
 		appID := getAppIDFromRequest(nil)
@@ -110,6 +471,41 @@ func TestGetAppIDFromRequest(t *testing.T) {
 	})
 }
 
+func TestErrorToAdmissionResponse(t *testing.T) {
+	t.Run("denial with a reason code", func(t *testing.T) {
+		res := errorToAdmissionResponse(patcher.NewDenialError(patcher.DenialReasonInvalidAppPort, errors.New("invalid port")))
+		assert.False(t, res.Allowed)
+		assert.Equal(t, metav1.StatusFailure, res.Result.Status)
+		assert.Equal(t, metav1.StatusReason("InvalidAppPort"), res.Result.Reason)
+		assert.Equal(t, "DaprReason: InvalidAppPort: invalid port", res.Result.Message)
+	})
+
+	t.Run("error without a reason code", func(t *testing.T) {
+		res := errorToAdmissionResponse(errors.New("failed"))
+		assert.Equal(t, metav1.StatusReason("Internal"), res.Result.Reason)
+		assert.Equal(t, "DaprReason: Internal: failed", res.Result.Message)
+	})
+
+	t.Run("denied pod", func(t *testing.T) {
+		inj := newTestInjector(t, Config{})
+		_, err := patchTestPod(t, inj, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: "default",
+				Annotations: map[string]string{
+					"dapr.io/enabled":  "true",
+					"dapr.io/app-id":   "myapp",
+					"dapr.io/app-port": "-1",
+				},
+			},
+		})
+		assert.Error(t, err)
+		res := errorToAdmissionResponse(err)
+		assert.Equal(t, metav1.StatusReason("InvalidAppPort"), res.Result.Reason)
+		assert.Contains(t, res.Result.Message, "DaprReason: InvalidAppPort: ")
+	})
+}
+
 func TestAllowedControllersServiceAccountUID(t *testing.T) {
 	client := kubernetesfake.NewSimpleClientset()
 
@@ -41,6 +41,10 @@ var (
 		"injector/sidecar_injection/failed_total",
 		"The total number of failed sidecar injections.",
 		stats.UnitDimensionless)
+	oversizedPatchesTotal = stats.Int64(
+		"injector/sidecar_injection/oversized_patches_total",
+		"The total number of sidecar injection patches exceeding the configured size threshold.",
+		stats.UnitDimensionless)
 
 	noKeys = []tag.Key{}
 
@@ -66,12 +70,18 @@ func RecordFailedSidecarInjectionCount(appID, reason string) {
 	stats.RecordWithTags(context.Background(), diagUtils.WithTags(failedSidecarInjectedTotal.Name(), appIDKey, appID, failedReasonKey, reason), failedSidecarInjectedTotal.M(1))
 }
 
+// RecordOversizedPatchCount records the number of patches exceeding the configured size threshold.
+func RecordOversizedPatchCount(appID string) {
+	stats.RecordWithTags(context.Background(), diagUtils.WithTags(oversizedPatchesTotal.Name(), appIDKey, appID), oversizedPatchesTotal.M(1))
+}
+
 // InitMetrics initialize the injector service metrics.
 func InitMetrics() error {
 	err := view.Register(
 		diagUtils.NewMeasureView(sidecarInjectionRequestsTotal, noKeys, view.Count()),
 		diagUtils.NewMeasureView(succeededSidecarInjectedTotal, []tag.Key{appIDKey}, view.Count()),
 		diagUtils.NewMeasureView(failedSidecarInjectedTotal, []tag.Key{appIDKey, failedReasonKey}, view.Count()),
+		diagUtils.NewMeasureView(oversizedPatchesTotal, []tag.Key{appIDKey}, view.Count()),
 	)
 
 	return err
@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Resync period for the namespaces informer.
+const namespaceInformerResync = 10 * time.Minute
+
+// namespaceLabelMatcher checks whether a namespace's labels match a selector.
+// Namespaces are read from a shared informer cache to avoid an API call for each admission request.
+type namespaceLabelMatcher struct {
+	selector   labels.Selector
+	kubeClient kubernetes.Interface
+	factory    informers.SharedInformerFactory
+	lister     corev1listers.NamespaceLister
+	synced     cache.InformerSynced
+}
+
+func newNamespaceLabelMatcher(kubeClient kubernetes.Interface, selector labels.Selector) *namespaceLabelMatcher {
+	factory := informers.NewSharedInformerFactory(kubeClient, namespaceInformerResync)
+	informer := factory.Core().V1().Namespaces()
+	return &namespaceLabelMatcher{
+		selector:   selector,
+		kubeClient: kubeClient,
+		factory:    factory,
+		lister:     informer.Lister(),
+		synced:     informer.Informer().HasSynced,
+	}
+}
+
+// Start starts the informer.
+// It doesn't wait for the cache to be synced, so the webhook server isn't blocked (for example, if the injector lacks permissions to watch namespaces).
+func (m *namespaceLabelMatcher) Start(ctx context.Context) {
+	if m.factory == nil {
+		return
+	}
+	m.factory.Start(ctx.Done())
+}
+
+// Matches returns true if the labels of the namespace match the selector.
+// If the cache isn't synced yet, or the namespace isn't in the cache (for example, because it was just created), it's retrieved from the API server.
+func (m *namespaceLabelMatcher) Matches(ctx context.Context, namespace string) (bool, error) {
+	var (
+		ns  *corev1.Namespace
+		err error
+	)
+	if m.synced == nil || m.synced() {
+		ns, err = m.lister.Get(namespace)
+	}
+	if ns == nil && (err == nil || apierrors.IsNotFound(err)) {
+		log.Debugf("Namespace '%s' not found in cache, retrieving it from the API server", namespace)
+		ns, err = m.kubeClient.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("error when fetching namespace '%s': %w", namespace, err)
+	}
+	return m.selector.Matches(labels.Set(ns.Labels)), nil
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func countNamespaceGets(kubeClient *kubernetesfake.Clientset) int {
+	n := 0
+	for _, a := range kubeClient.Actions() {
+		if a.GetVerb() == "get" && a.GetResource().Resource == "namespaces" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestNamespaceLabelMatcher(t *testing.T) {
+	selector, err := labels.Parse("dapr.io/inject=true")
+	require.NoError(t, err)
+
+	newNamespace := func(name string, lbls map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: lbls,
+			},
+		}
+	}
+
+	t.Run("labels are read from the informer cache", func(t *testing.T) {
+		kubeClient := kubernetesfake.NewSimpleClientset(
+			newNamespace("enabled", map[string]string{"dapr.io/inject": "true"}),
+			newNamespace("disabled", map[string]string{"dapr.io/inject": "false"}),
+		)
+		m := newNamespaceLabelMatcher(kubeClient, selector)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		m.Start(ctx)
+		require.True(t, cache.WaitForCacheSync(ctx.Done(), m.synced))
+
+		ok, err := m.Matches(ctx, "enabled")
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = m.Matches(ctx, "disabled")
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		assert.Equal(t, 0, countNamespaceGets(kubeClient))
+	})
+
+	t.Run("namespaces missing from a stale cache are retrieved from the API server", func(t *testing.T) {
+		kubeClient := kubernetesfake.NewSimpleClientset(
+			newNamespace("new", map[string]string{"dapr.io/inject": "true"}),
+		)
+		// Create a cache that doesn't contain the namespace yet
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		require.NoError(t, indexer.Add(newNamespace("cached", map[string]string{"dapr.io/inject": "true"})))
+		m := &namespaceLabelMatcher{
+			selector:   selector,
+			kubeClient: kubeClient,
+			lister:     corev1listers.NewNamespaceLister(indexer),
+		}
+
+		ok, err := m.Matches(context.Background(), "cached")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 0, countNamespaceGets(kubeClient))
+
+		ok, err = m.Matches(context.Background(), "new")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 1, countNamespaceGets(kubeClient))
+
+		ok, err = m.Matches(context.Background(), "notfound")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("namespaces are retrieved from the API server until the cache is synced", func(t *testing.T) {
+		kubeClient := kubernetesfake.NewSimpleClientset(
+			newNamespace("myns", map[string]string{"dapr.io/inject": "true"}),
+		)
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		require.NoError(t, indexer.Add(newNamespace("myns", map[string]string{"dapr.io/inject": "false"})))
+		m := &namespaceLabelMatcher{
+			selector:   selector,
+			kubeClient: kubeClient,
+			lister:     corev1listers.NewNamespaceLister(indexer),
+			synced: func() bool {
+				return false
+			},
+		}
+
+		ok, err := m.Matches(context.Background(), "myns")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 1, countNamespaceGets(kubeClient))
+	})
+
+	t.Run("API errors are returned", func(t *testing.T) {
+		kubeClient := kubernetesfake.NewSimpleClientset()
+		kubeClient.PrependReactor("get", "namespaces", func(action clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("simulated")
+		})
+		m := &namespaceLabelMatcher{
+			selector:   selector,
+			kubeClient: kubeClient,
+			lister:     corev1listers.NewNamespaceLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		}
+
+		_, err := m.Matches(context.Background(), "myns")
+		require.Error(t, err)
+	})
+}
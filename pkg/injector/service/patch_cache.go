@@ -0,0 +1,165 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dapr/dapr/pkg/injector/annotations"
+	"github.com/dapr/kit/ptr"
+)
+
+// Default and maximum durations for which a computed patch is cached.
+const (
+	defaultPatchCacheTTL = time.Minute
+	maxPatchCacheTTL     = 10 * time.Minute
+)
+
+// Placeholders for the certificate of the sidecar in computed patches.
+// They are replaced with a certificate signed for each pod, so certificates are never cached or shared between pods.
+const (
+	daprdCertChainPlaceholder = "$(DAPR_INJECTOR_CERT_CHAIN)"
+	daprdCertKeyPlaceholder   = "$(DAPR_INJECTOR_CERT_KEY)"
+)
+
+// patchCache caches the patches computed for pods, so identical pods that are retried by controllers don't require re-computing the patch.
+// The cache is bounded in size and entries expire after a TTL.
+type patchCache struct {
+	lru *expirable.LRU[string, patchCacheEntry]
+}
+
+type patchCacheEntry struct {
+	patch        jsonpatch.Patch
+	warnings     []string
+	appID        string
+	sidecarImage string
+	// cacheable is false if the patch depends on lookups of resources in the cluster, which must be performed for each pod.
+	cacheable bool
+}
+
+func newPatchCache(size int, ttl time.Duration) *patchCache {
+	return &patchCache{
+		lru: expirable.NewLRU[string, patchCacheEntry](size, nil, ttl),
+	}
+}
+
+// Get returns the entry for the key, if present.
+// The returned entry can be modified by the caller.
+func (c *patchCache) Get(key string) (patchCacheEntry, bool) {
+	entry, ok := c.lru.Get(key)
+	if !ok {
+		return patchCacheEntry{}, false
+	}
+
+	// Return copies of the slices so callers can append to them
+	entry.patch = append(jsonpatch.Patch(nil), entry.patch...)
+	entry.warnings = append([]string(nil), entry.warnings...)
+	return entry, true
+}
+
+// Add adds an entry to the cache.
+func (c *patchCache) Add(key string, entry patchCacheEntry) {
+	c.lru.Add(key, entry)
+}
+
+// Len returns the number of entries in the cache.
+func (c *patchCache) Len() int {
+	return c.lru.Len()
+}
+
+// getPatchCacheKey returns the key for the patch of the pod in the cache.
+// The key is a hash of the fields of the pod that are relevant for computing the patch, of the injector's configuration, of the current trust anchors, and of whether mTLS is enabled, so changes to any of these don't return stale patches.
+// If the pod doesn't set an app ID, the name of the pod is included too, because it's used as the app ID.
+func getPatchCacheKey(namespace string, pod *corev1.Pod, config Config, trustAnchors []byte, mtlsEnabled bool) (string, error) {
+	var name string
+	if pod.Annotations[annotations.KeyAppID] == "" {
+		name = pod.Name
+	}
+	data, err := json.Marshal(struct {
+		Namespace       string                  `json:"namespace"`
+		Name            string                  `json:"name,omitempty"`
+		GenerateName    string                  `json:"generateName"`
+		Labels          map[string]string       `json:"labels"`
+		Annotations     map[string]string       `json:"annotations"`
+		OwnerReferences []metav1.OwnerReference `json:"ownerReferences"`
+		Spec            corev1.PodSpec          `json:"spec"`
+		Config          Config                  `json:"config"`
+		TrustAnchors    []byte                  `json:"trustAnchors"`
+		MTLSEnabled     bool                    `json:"mtlsEnabled"`
+	}{
+		Namespace:       namespace,
+		Name:            name,
+		GenerateName:    pod.GenerateName,
+		Labels:          pod.Labels,
+		Annotations:     pod.Annotations,
+		OwnerReferences: pod.OwnerReferences,
+		Spec:            pod.Spec,
+		Config:          config,
+		TrustAnchors:    trustAnchors,
+		MTLSEnabled:     mtlsEnabled,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to compute the cache key for the pod: %w", err)
+	}
+
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// setDaprdCertificate returns a copy of the patch in which the placeholders for the certificate of the sidecar are replaced with a newly-signed certificate.
+func (i *injector) setDaprdCertificate(ctx context.Context, namespace string, patch jsonpatch.Patch) (jsonpatch.Patch, error) {
+	daprdCert, daprdPrivateKey, err := i.signDaprdCertificate(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	replacer := strings.NewReplacer(
+		daprdCertChainPlaceholder, escapeJSONString(daprdCert),
+		daprdCertKeyPlaceholder, escapeJSONString(daprdPrivateKey),
+	)
+	res := make(jsonpatch.Patch, len(patch))
+	for n, op := range patch {
+		res[n] = op
+		val, ok := op["value"]
+		if !ok || val == nil || !bytes.Contains(*val, []byte(daprdCertChainPlaceholder)) {
+			continue
+		}
+
+		// Operations may be shared with the cache, so they are copied before being modified
+		res[n] = make(jsonpatch.Operation, len(op))
+		for k, v := range op {
+			res[n][k] = v
+		}
+		res[n]["value"] = ptr.Of(json.RawMessage(replacer.Replace(string(*val))))
+	}
+	return res, nil
+}
+
+// escapeJSONString returns the value encoded as the content of a JSON string, without the quotes.
+func escapeJSONString(val []byte) string {
+	enc, _ := json.Marshal(string(val))
+	return string(enc[1 : len(enc)-1])
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/dapr/dapr/pkg/injector/patcher"
+	securityConsts "github.com/dapr/dapr/pkg/security/consts"
+	"github.com/dapr/kit/ptr"
+)
+
+func TestPatchCache(t *testing.T) {
+	getPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "myapp-",
+				Namespace:    "default",
+				Annotations: map[string]string{
+					"dapr.io/enabled": "true",
+					"dapr.io/app-id":  "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+	}
+
+	newInjector := func(t *testing.T, cfg Config) (*injector, *int) {
+		inj := newTestInjector(t, cfg)
+		signed := 0
+		inj.signDaprdCertificate = func(context.Context, string) ([]byte, []byte, error) {
+			signed++
+			return []byte(fmt.Sprintf("test-cert-%d\n", signed)), []byte(fmt.Sprintf("test-key-%d", signed)), nil
+		}
+		return inj, &signed
+	}
+	getCertEnv := func(t *testing.T, pod *corev1.Pod) map[string]string {
+		t.Helper()
+
+		env := map[string]string{}
+		for _, e := range getTestDaprdContainer(t, pod).Env {
+			if e.Name == securityConsts.CertChainEnvVar || e.Name == securityConsts.CertKeyEnvVar {
+				env[e.Name] = e.Value
+			}
+		}
+		return env
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		inj, signed := newInjector(t, Config{})
+		require.Nil(t, inj.patchCache)
+
+		_, err := patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+		_, err = patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+		assert.Equal(t, 2, *signed)
+	})
+
+	t.Run("cache hit re-uses the patch with a new certificate", func(t *testing.T) {
+		inj, signed := newInjector(t, Config{AdmissionCacheSize: 10})
+
+		pod1, err := patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+		pod2, err := patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, *signed)
+		assert.Equal(t, 1, inj.patchCache.Len())
+		assert.Equal(t, map[string]string{
+			securityConsts.CertChainEnvVar: "test-cert-1\n",
+			securityConsts.CertKeyEnvVar:   "test-key-1",
+		}, getCertEnv(t, pod1))
+		assert.Equal(t, map[string]string{
+			securityConsts.CertChainEnvVar: "test-cert-2\n",
+			securityConsts.CertKeyEnvVar:   "test-key-2",
+		}, getCertEnv(t, pod2))
+
+		// Apart from the certificate, the pods are identical
+		pod1.Spec.Containers[1].Env = nil
+		pod2.Spec.Containers[1].Env = nil
+		assert.Equal(t, pod1, pod2)
+	})
+
+	t.Run("cached entries don't contain the certificate", func(t *testing.T) {
+		inj, _ := newInjector(t, Config{AdmissionCacheSize: 10})
+
+		key, err := getPatchCacheKey("default", getPod(), inj.config, nil, true)
+		require.NoError(t, err)
+		_, err = patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+
+		entry, ok := inj.patchCache.Get(key)
+		require.True(t, ok)
+		data, err := json.Marshal(entry.patch)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "test-cert")
+		assert.NotContains(t, string(data), "test-key")
+	})
+
+	t.Run("different pods are cached separately", func(t *testing.T) {
+		inj, _ := newInjector(t, Config{AdmissionCacheSize: 10})
+
+		_, err := patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+
+		pod := getPod()
+		pod.Annotations["dapr.io/log-level"] = "debug"
+		_, err = patchTestPod(t, inj, pod)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, inj.patchCache.Len())
+	})
+
+	t.Run("pods without an app ID are cached by name", func(t *testing.T) {
+		inj, _ := newInjector(t, Config{AdmissionCacheSize: 10})
+
+		for _, name := range []string{"myapp-1", "myapp-2"} {
+			pod := getPod()
+			pod.Name = name
+			delete(pod.Annotations, "dapr.io/app-id")
+			patched, err := patchTestPod(t, inj, pod)
+			require.NoError(t, err)
+			assert.Contains(t, getTestDaprdContainer(t, patched).Args, name)
+		}
+		assert.Equal(t, 2, inj.patchCache.Len())
+	})
+
+	t.Run("patches that depend on lookups are not cached", func(t *testing.T) {
+		inj, _ := newInjector(t, Config{AdmissionCacheSize: 10, WarnBroadSecretScopes: "true"})
+
+		_, err := patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+		assert.Equal(t, 0, inj.patchCache.Len())
+
+		inj.config.WarnBroadSecretScopes = ""
+		inj.config.TrustAnchorsSource = "configmap/ca"
+		inj.config.ValidateTrustAnchorsSource = "true"
+		inj.kubeClient = kubernetesfake.NewSimpleClientset(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "default"},
+		})
+		_, err = patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+		assert.Equal(t, 0, inj.patchCache.Len())
+	})
+
+	t.Run("pods with different owners are cached separately", func(t *testing.T) {
+		inj, _ := newInjector(t, Config{AdmissionCacheSize: 10, OnlyInjectForOwnerKinds: "StatefulSet"})
+
+		pod := getPod()
+		pod.OwnerReferences = []metav1.OwnerReference{
+			{Kind: "StatefulSet", Name: "myapp", Controller: ptr.Of(true)},
+		}
+		patched, err := patchTestPod(t, inj, pod)
+		require.NoError(t, err)
+		assert.Len(t, patched.Spec.Containers, 2)
+
+		patched, err = patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+		assert.Len(t, patched.Spec.Containers, 1)
+	})
+
+	t.Run("config reload invalidates the cache", func(t *testing.T) {
+		inj, _ := newInjector(t, Config{AdmissionCacheSize: 10})
+
+		pod, err := patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+		assert.Equal(t, "test-image", getTestDaprdContainer(t, pod).Image)
+
+		inj.config.SidecarImage = "new-image"
+		pod, err = patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+		assert.Equal(t, "new-image", getTestDaprdContainer(t, pod).Image)
+		assert.Equal(t, 2, inj.patchCache.Len())
+	})
+
+	t.Run("trust anchors rotation invalidates the cache", func(t *testing.T) {
+		inj, _ := newInjector(t, Config{AdmissionCacheSize: 10})
+
+		_, err := patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+
+		inj.currentTrustAnchors = func() ([]byte, error) {
+			return []byte("new-trust-anchors"), nil
+		}
+		_, err = patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+		assert.Equal(t, 2, inj.patchCache.Len())
+	})
+
+	t.Run("cache is bounded", func(t *testing.T) {
+		c := newPatchCache(2, time.Minute)
+		c.Add("a", patchCacheEntry{appID: "a"})
+		c.Add("b", patchCacheEntry{appID: "b"})
+		c.Add("c", patchCacheEntry{appID: "c"})
+
+		assert.Equal(t, 2, c.Len())
+		_, ok := c.Get("a")
+		assert.False(t, ok)
+		entry, ok := c.Get("c")
+		assert.True(t, ok)
+		assert.Equal(t, "c", entry.appID)
+	})
+
+	t.Run("entries returned by the cache can be modified", func(t *testing.T) {
+		c := newPatchCache(2, time.Minute)
+		c.Add("a", patchCacheEntry{
+			patch:    make(jsonpatch.Patch, 1, 10),
+			warnings: make([]string, 1, 10),
+		})
+
+		entry, _ := c.Get("a")
+		entry.patch = append(entry.patch, patcher.NewPatchOperation("add", "/foo", "bar"))
+		entry.warnings = append(entry.warnings, "warning")
+
+		entry, _ = c.Get("a")
+		assert.Len(t, entry.patch, 1)
+		assert.Len(t, entry.warnings, 1)
+	})
+}
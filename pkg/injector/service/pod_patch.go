@@ -17,13 +17,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
 
 	jsonpatch "github.com/evanphx/json-patch/v5"
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	scheme "github.com/dapr/dapr/pkg/client/clientset/versioned"
+	"github.com/dapr/dapr/pkg/injector/annotations"
 	injectorConsts "github.com/dapr/dapr/pkg/injector/consts"
 	"github.com/dapr/dapr/pkg/injector/patcher"
 	"github.com/dapr/dapr/pkg/security/token"
@@ -34,11 +40,20 @@ const (
 	defaultMtlsEnabled = true
 )
 
-func (i *injector) getPodPatchOperations(ctx context.Context, ar *admissionv1.AdmissionReview) (patchOps jsonpatch.Patch, err error) {
+func (i *injector) getPodPatchOperations(ctx context.Context, ar *admissionv1.AdmissionReview) (patchOps jsonpatch.Patch, warnings []string, err error) {
 	pod := &corev1.Pod{}
+	var entry patchCacheEntry
+
+	// Emit an audit record with the decision, if enabled
+	if i.audit != nil {
+		defer func() {
+			i.audit.Record(newAuditRecord(ar, pod, entry, patchOps, err))
+		}()
+	}
+
 	err = json.Unmarshal(ar.Request.Object.Raw, pod)
 	if err != nil {
-		return nil, fmt.Errorf("could not unmarshal raw object: %w", err)
+		return nil, nil, patcher.NewDenialError(patcher.DenialReasonMalformedRequest, fmt.Errorf("could not unmarshal raw object: %w", err))
 	}
 
 	log.Infof(
@@ -46,40 +61,219 @@ func (i *injector) getPodPatchOperations(ctx context.Context, ar *admissionv1.Ad
 		ar.Request.Kind, ar.Request.Namespace, ar.Request.Name, pod.Name, ar.Request.UID, ar.Request.Operation, ar.Request.UserInfo,
 	)
 
-	// Keep DNS resolution outside of GetSidecarContainer for unit testing.
-	placementAddress := patcher.ServiceAddress(patcher.ServicePlacement, i.config.Namespace, i.config.KubeClusterDomain)
-	sentryAddress := patcher.ServiceAddress(patcher.ServiceSentry, i.config.Namespace, i.config.KubeClusterDomain)
-	operatorAddress := patcher.ServiceAddress(patcher.ServiceAPI, i.config.Namespace, i.config.KubeClusterDomain)
+	// Containers can only be added when pods are created, so other operations are never patched
+	switch ar.Request.Operation {
+	case admissionv1.Update:
+		return nil, i.getPodUpdateWarnings(ar.Request.Namespace, pod), nil
+	case admissionv1.Delete, admissionv1.Connect:
+		return nil, nil, nil
+	}
+
+	// Skip pods in namespaces whose labels don't match the selector, if configured
+	if i.namespaceLabels != nil {
+		var ok bool
+		ok, err = i.namespaceLabels.Matches(ctx, ar.Request.Namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			log.Debugf("Skipping injection for pod in namespace '%s' not matching the namespace label selector", ar.Request.Namespace)
+			return nil, nil, nil
+		}
+	}
 
 	trustAnchors, err := i.currentTrustAnchors()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	daprdCert, daprdPrivateKey, err := i.signDaprdCertificate(ctx, ar.Request.Namespace)
+	mtlsEnabled := mTLSEnabled(i.daprClient)
+
+	// Re-use the patch computed for an identical pod, if caching is enabled
+	var (
+		cacheKey string
+		cached   bool
+	)
+	if i.patchCache != nil {
+		cacheKey, err = getPatchCacheKey(ar.Request.Namespace, pod, i.config, trustAnchors, mtlsEnabled)
+		if err != nil {
+			return nil, nil, err
+		}
+		entry, cached = i.patchCache.Get(cacheKey)
+	}
+	if !cached {
+		entry, err = i.getSidecarPatch(ctx, ar, pod, trustAnchors, mtlsEnabled)
+		if err != nil {
+			return nil, nil, err
+		}
+		if i.patchCache != nil && entry.cacheable {
+			i.patchCache.Add(cacheKey, entry)
+		}
+	}
+
+	if len(entry.patch) == 0 {
+		return nil, nil, nil
+	}
+	// Stop injecting once the cap on the number of sidecars in the cluster is reached, if configured
+	if i.sidecarQuota != nil {
+		var quotaWarning string
+		quotaWarning, err = i.checkSidecarQuota()
+		if err != nil {
+			return nil, nil, err
+		}
+		if quotaWarning != "" {
+			return nil, []string{quotaWarning}, nil
+		}
+	}
+
+	// The certificate is signed for each pod, and it's never cached
+	patch, err := i.setDaprdCertificate(ctx, ar.Request.Namespace, entry.patch)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	warnings = entry.warnings
 
+	if i.appIDs != nil {
+		collisionPatch, collisionWarning := i.checkAppIDCollision(ar.Request.Namespace, entry.appID, pod)
+		if collisionWarning != "" {
+			patch = append(patch, collisionPatch...)
+			warnings = append(warnings, collisionWarning)
+		}
+	}
+
+	return patch, warnings, nil
+}
+
+// getPodUpdateWarnings returns the warnings for an update of a pod that has Dapr enabled but doesn't have the sidecar, for example because the sidecar was removed by another controller.
+// The list of containers of a pod is immutable, so the sidecar can't be injected at this point: the pod needs to be re-created.
+// Updates are only sent to the injector if the webhook is registered for them, with the webhookUpdateWarnings value of the Helm chart.
+// The sidecar configuration is created in the same way as for new pods, so pods that wouldn't be injected when created don't get the warning.
+func (i *injector) getPodUpdateWarnings(namespace string, pod *corev1.Pod) []string {
+	sidecar := i.newSidecarConfig(namespace, pod)
+	sidecar.SetFromPodAnnotations()
+	if !sidecar.NeedsPatching() {
+		return nil
+	}
+
+	log.Warnf("Pod '%s' in namespace '%s' has Dapr enabled but doesn't have the sidecar: it can't be injected in an existing pod", pod.Name, namespace)
+	return []string{"the pod has Dapr enabled but doesn't have the Dapr sidecar, which can only be injected when the pod is created: re-create the pod to inject the sidecar"}
+}
+
+// getSidecarPatch returns the patch that injects the sidecar in the pod, together with the warnings and the app ID.
+// The returned patch is empty if the pod doesn't need to be patched.
+// The certificate of the sidecar is replaced by placeholders, which are filled by setDaprdCertificate.
+func (i *injector) getSidecarPatch(ctx context.Context, ar *admissionv1.AdmissionReview, pod *corev1.Pod, trustAnchors []byte, mtlsEnabled bool) (patchCacheEntry, error) {
 	// Create the sidecar configuration object from the pod
-	sidecar := patcher.NewSidecarConfig(pod)
+	sidecar := i.newSidecarConfig(ar.Request.Namespace, pod)
 	sidecar.GetInjectedComponentContainers = i.getInjectedComponentContainers
+	if i.config.GetValidateTrustAnchorsSource() {
+		sidecar.TrustAnchorsSourceExists = func(source patcher.TrustAnchorsSource, namespace string) (bool, error) {
+			return i.trustAnchorsSourceExists(ctx, source, namespace)
+		}
+	}
+	sidecar.MTLSEnabled = mtlsEnabled
+	sidecar.CurrentTrustAnchors = trustAnchors
+	sidecar.CertChain = daprdCertChainPlaceholder
+	sidecar.CertKey = daprdCertKeyPlaceholder
+
+	// Set the configuration from annotations
+	sidecar.SetFromPodAnnotations()
+
+	// Direct the sidecar to a different control plane if requested
+	if sidecar.ControlPlaneNamespaceOverride != "" {
+		err := i.setControlPlaneNamespace(sidecar, sidecar.ControlPlaneNamespaceOverride)
+		if err != nil {
+			return patchCacheEntry{}, err
+		}
+	}
+
+	// Get the patch to apply to the pod
+	// Patch may be empty if there's nothing that needs to be done
+	patch, err := sidecar.GetPatch()
+	if err != nil {
+		return patchCacheEntry{}, err
+	}
+
+	if len(patch) == 0 {
+		return patchCacheEntry{cacheable: true}, nil
+	}
+
+	warnings := sidecar.GetWarnings()
+	if i.config.GetWarnBroadSecretScopes() {
+		warnings = append(warnings, i.getSecretScopesWarnings(ctx, ar.Request.Namespace, sidecar.Config)...)
+	}
+
+	return patchCacheEntry{
+		patch:        patch,
+		warnings:     warnings,
+		appID:        sidecar.GetAppID(),
+		sidecarImage: sidecar.SidecarImage,
+		// Patches that depend on the trust anchors source, the pluggable components, or the secret scopes are re-computed for each pod, so the lookups are never stale
+		cacheable: (sidecar.TrustAnchorsSource == nil || sidecar.TrustAnchorsSourceExists == nil) &&
+			!sidecar.InjectPluggableComponents &&
+			!i.config.GetWarnBroadSecretScopes(),
+	}, nil
+}
+
+// newSidecarConfig returns the configuration of the sidecar for the pod in the namespace, with the defaults from the injector's configuration.
+// Values from the pod's annotations are not set yet, so callers can add more defaults before calling SetFromPodAnnotations.
+func (i *injector) newSidecarConfig(namespace string, pod *corev1.Pod) *patcher.SidecarConfig {
+	// Keep DNS resolution outside of GetSidecarContainer for unit testing.
+	placementAddress := patcher.ServiceAddress(patcher.ServicePlacement, i.config.Namespace, i.config.KubeClusterDomain)
+	sentryAddress := patcher.ServiceAddress(patcher.ServiceSentry, i.config.Namespace, i.config.KubeClusterDomain)
+	operatorAddress := patcher.ServiceAddress(patcher.ServiceAPI, i.config.Namespace, i.config.KubeClusterDomain)
+
+	// Create the sidecar configuration object from the pod
+	sidecar := patcher.NewSidecarConfig(pod)
 	sidecar.Mode = injectorConsts.ModeKubernetes
-	sidecar.Namespace = ar.Request.Namespace
-	sidecar.MTLSEnabled = mTLSEnabled(i.daprClient)
-	sidecar.Identity = ar.Request.Namespace + ":" + pod.Spec.ServiceAccountName
+	sidecar.Namespace = namespace
+	if i.namespaceMTLS != nil {
+		// Enforced values can't be overridden by annotations
+		if val, ok := i.namespaceMTLS.Get(namespace); ok {
+			// Values are validated in the configuration
+			enforced, _ := strconv.ParseBool(val)
+			sidecar.ForcedMTLSEnabled = &enforced
+		}
+	}
+	sidecar.Identity = namespace + ":" + pod.Spec.ServiceAccountName
 	sidecar.IgnoreEntrypointTolerations = i.config.GetIgnoreEntrypointTolerations()
 	sidecar.ImagePullPolicy = i.config.GetPullPolicy()
+	sidecar.TerminationMessagePolicy = i.config.GetTerminationMessagePolicy()
 	sidecar.OperatorAddress = operatorAddress
 	sidecar.SentryAddress = sentryAddress
 	sidecar.RunAsNonRoot = i.config.GetRunAsNonRoot()
 	sidecar.ReadOnlyRootFilesystem = i.config.GetReadOnlyRootFilesystem()
 	sidecar.SidecarDropALLCapabilities = i.config.GetDropCapabilities()
+	sidecar.OnAmbiguousAppContainer = i.config.GetOnAmbiguousAppContainer()
+	sidecar.OnWindowsPod = i.config.GetOnWindowsPod()
+	sidecar.InjectPortEnvIntoApp = i.config.GetInjectPortEnvIntoApp()
+	sidecar.SkipGenerateNamePatterns = i.config.GetSkipGenerateNamePatterns()
+	sidecar.OnlyInjectForOwnerKinds = i.config.GetOnlyInjectForOwnerKinds()
+	sidecar.RuntimeClassAdjustments = i.config.GetRuntimeClassAdjustments()
+	sidecar.MinAppContainers = i.config.MinAppContainers
+	sidecar.MaxAppContainers = i.config.MaxAppContainers
+	sidecar.SidecarAllowedIDRange = i.config.GetSidecarAllowedIDRange()
+	sidecar.MemoryLimitFractionOfApp = i.config.GetSidecarMemoryAsFractionOfApp()
+	sidecar.AnnotateAddedResources = i.config.GetAnnotateSidecarAddedResources()
+	sidecar.SetPodSeccompRuntimeDefault = i.config.GetSetPodSeccompRuntimeDefault()
+	sidecar.RequireLimits = i.config.GetRequireSidecarLimits()
+	sidecar.RequiredPodQoS = i.config.GetRequiredPodQoS()
+	sidecar.AutoRemapPorts = i.config.GetAutoRemapPorts()
+	sidecar.RequireAppID = i.config.GetRequireAppID()
+	sidecar.ForbidInsecureAppProtocol = i.config.GetForbidInsecureAppProtocol()
+	sidecar.DefaultPodAnnotations = i.config.GetDefaultPodAnnotations()
+	sidecar.ForcedAnnotations = i.config.GetForcedAnnotations()
+	sidecar.ImageDigests = i.config.GetImageDigestMap()
+	sidecar.AdditionalCASecrets = i.config.GetAdditionalCASecrets()
+	sidecar.CostAllocationLabels = i.config.GetCostAllocationLabels()
+	sidecar.SidecarHostAliases = i.config.GetSidecarHostAliases()
+	sidecar.SidecarTopologySpread = i.config.GetSidecarTopologySpread()
+	sidecar.TrustAnchorsSource = i.config.GetTrustAnchorsSource()
+	sidecar.EnableNativeSidecars = i.config.GetEnableNativeSidecars()
+	sidecar.AllowControlPlaneAddresses = i.config.GetAllowControlPlaneAddresses()
 	sidecar.ControlPlaneNamespace = i.controlPlaneNamespace
 	sidecar.ControlPlaneTrustDomain = i.controlPlaneTrustDomain
-	sidecar.CurrentTrustAnchors = trustAnchors
-	sidecar.CertChain = string(daprdCert)
-	sidecar.CertKey = string(daprdPrivateKey)
 	sidecar.DisableTokenVolume = !token.HasKubernetesToken()
+	sidecar.InjectorVersion = i.injectorVersion
 
 	// Set the placement address unless it's skipped
 	// Even if the placement is skipped, however,the placement address will still be included if explicitly set in the annotations
@@ -88,19 +282,93 @@ func (i *injector) getPodPatchOperations(ctx context.Context, ar *admissionv1.Ad
 		sidecar.PlacementAddress = placementAddress
 	}
 
+	// Default values for the resource requests and limits, which can be overridden by annotations
+	if profile, ok := i.getResourceProfile(namespace); ok {
+		sidecar.SetResourceProfile(profile)
+	}
+
+	// Default value for the log level, which can be overridden by annotations
+	if i.namespaceLogLevels != nil {
+		if level, ok := i.namespaceLogLevels.Get(namespace); ok {
+			sidecar.LogLevel = level
+		}
+	}
+
+	// Default value for the Configuration resource, which can be overridden by annotations
+	if i.namespaceConfigs != nil {
+		if name, ok := i.namespaceConfigs.Get(namespace); ok {
+			sidecar.Config = name
+		}
+	}
+
 	// Default value for the sidecar image, which can be overridden by annotations
 	sidecar.SidecarImage = i.config.SidecarImage
+	if i.config.WindowsSidecarImage != "" && sidecar.OnWindowsPod == patcher.WindowsPodWindows && patcher.IsWindowsPod(pod) {
+		sidecar.SidecarImage = i.config.WindowsSidecarImage
+	}
 
-	// Set the configuration from annotations
-	sidecar.SetFromPodAnnotations()
+	// Default values for the user and group IDs, which can be overridden by annotations
+	sidecar.SidecarRunAsUser = i.config.GetSidecarRunAsUser()
+	sidecar.SidecarRunAsGroup = i.config.GetSidecarRunAsGroup()
+	sidecar.SidecarFSGroup = i.config.GetSidecarFSGroup()
 
-	// Get the patch to apply to the pod
-	// Patch may be empty if there's nothing that needs to be done
-	patch, err := sidecar.GetPatch()
-	if err != nil {
-		return nil, err
+	// Default value for enabling metrics, which can be overridden by annotations
+	sidecar.EnableMetrics = i.config.GetEnableMetrics()
+
+	// Default value for adding the downward API env vars, which can be overridden by annotations
+	sidecar.SidecarDownwardAPIEnv = i.config.GetSidecarDownwardAPIEnv()
+
+	// Default values for the liveness probe, which can be overridden by annotations
+	if i.config.SidecarLivenessProbeDelaySeconds > 0 {
+		sidecar.SidecarLivenessProbeDelaySeconds = i.config.SidecarLivenessProbeDelaySeconds
 	}
-	return patch, nil
+	if i.config.SidecarLivenessProbePeriodSeconds > 0 {
+		sidecar.SidecarLivenessProbePeriodSeconds = i.config.SidecarLivenessProbePeriodSeconds
+	}
+	if i.config.SidecarLivenessProbeThreshold > 0 {
+		sidecar.SidecarLivenessProbeThreshold = i.config.SidecarLivenessProbeThreshold
+	}
+
+	return sidecar
+}
+
+// setControlPlaneNamespace updates the sidecar configuration so it uses the control plane services (sentry, operator, and placement) deployed in the namespace.
+// Only the injector's namespace and the namespaces allowed in the injector's configuration can be used.
+// Addresses that don't point at the injector's control plane, because they were set in the annotations, are preserved.
+func (i *injector) setControlPlaneNamespace(sidecar *patcher.SidecarConfig, namespace string) error {
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return patcher.NewDenialError(patcher.DenialReasonInvalidAnnotation, fmt.Errorf("invalid value for annotation %s: %s", annotations.KeyControlPlaneNamespace, strings.Join(errs, ", ")))
+	}
+	if namespace != i.config.Namespace && !slices.Contains(i.config.GetAllowedControlPlaneNamespaces(), namespace) {
+		return patcher.NewDenialError(patcher.DenialReasonInvalidAnnotation, fmt.Errorf("invalid value for annotation %s: control plane namespace '%s' is not allowed", annotations.KeyControlPlaneNamespace, namespace))
+	}
+
+	sidecar.ControlPlaneNamespace = namespace
+	if sidecar.SentryAddress == patcher.ServiceAddress(patcher.ServiceSentry, i.config.Namespace, i.config.KubeClusterDomain) {
+		sidecar.SentryAddress = patcher.ServiceAddress(patcher.ServiceSentry, namespace, i.config.KubeClusterDomain)
+	}
+	if sidecar.OperatorAddress == patcher.ServiceAddress(patcher.ServiceAPI, i.config.Namespace, i.config.KubeClusterDomain) {
+		sidecar.OperatorAddress = patcher.ServiceAddress(patcher.ServiceAPI, namespace, i.config.KubeClusterDomain)
+	}
+	if sidecar.PlacementAddress == patcher.ServiceAddress(patcher.ServicePlacement, i.config.Namespace, i.config.KubeClusterDomain) {
+		sidecar.PlacementAddress = patcher.ServiceAddress(patcher.ServicePlacement, namespace, i.config.KubeClusterDomain)
+	}
+	return nil
+}
+
+// checkAppIDCollision records the app ID of the pod and, if it is already used by another workload in the namespace, logs a warning and returns a patch that annotates the pod together with the warning.
+func (i *injector) checkAppIDCollision(namespace string, appID string, pod *corev1.Pod) (jsonpatch.Patch, string) {
+	workload := getPodWorkloadName(pod)
+	other := i.appIDs.Observe(namespace, appID, workload)
+	if other == "" {
+		return nil, ""
+	}
+
+	warning := fmt.Sprintf("app ID '%s' of %s in namespace '%s' is also used by %s: duplicate app IDs cause routing confusion", appID, workload, namespace, other)
+	log.Warn(warning)
+	return jsonpatch.Patch{
+		patcher.NewPatchOperation("add", patcher.PatchPathAnnotations+"/"+patcher.EscapeJSONPointer(injectorConsts.AppIDCollisionAnnotation), other),
+	}, warning
 }
 
 func mTLSEnabled(daprClient scheme.Interface) bool {
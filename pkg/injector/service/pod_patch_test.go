@@ -0,0 +1,1017 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/dapr/dapr/pkg/client/clientset/versioned/fake"
+	"github.com/dapr/dapr/pkg/injector/annotations"
+	injectorConsts "github.com/dapr/dapr/pkg/injector/consts"
+	"github.com/dapr/dapr/pkg/injector/patcher"
+	securityConsts "github.com/dapr/dapr/pkg/security/consts"
+	"github.com/dapr/kit/ptr"
+)
+
+// newTestInjector returns an injector for tests, with fake clients and certificates.
+func newTestInjector(t *testing.T, cfg Config) *injector {
+	t.Helper()
+
+	if cfg.SidecarImage == "" {
+		cfg.SidecarImage = "test-image"
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = "dapr-system"
+	}
+	if cfg.KubeClusterDomain == "" {
+		cfg.KubeClusterDomain = "cluster.local"
+	}
+
+	i, err := NewInjector(Options{
+		Config:                cfg,
+		DaprClient:            fake.NewSimpleClientset(),
+		KubeClient:            kubernetesfake.NewSimpleClientset(),
+		ControlPlaneNamespace: cfg.Namespace,
+	})
+	require.NoError(t, err)
+
+	inj := i.(*injector)
+	inj.currentTrustAnchors = func() ([]byte, error) {
+		return nil, nil
+	}
+	inj.signDaprdCertificate = func(context.Context, string) ([]byte, []byte, error) {
+		return []byte("test-cert"), []byte("test-key"), nil
+	}
+	return inj
+}
+
+// patchTestPod invokes getPodPatchOperations for the pod and returns the patched pod.
+func patchTestPod(t *testing.T, inj *injector, pod *corev1.Pod) (*corev1.Pod, error) {
+	t.Helper()
+
+	podBytes, err := json.Marshal(pod)
+	require.NoError(t, err)
+
+	patch, _, err := inj.getPodPatchOperations(context.Background(), &admissionv1.AdmissionReview{
+		Request: &admissionv1.AdmissionRequest{
+			Namespace: pod.Namespace,
+			Object:    runtime.RawExtension{Raw: podBytes},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(patch) == 0 {
+		return pod, nil
+	}
+
+	newPod, err := patcher.PatchPod(pod, patch)
+	require.NoError(t, err)
+	return newPod, nil
+}
+
+func getTestDaprdContainer(t *testing.T, pod *corev1.Pod) corev1.Container {
+	t.Helper()
+
+	for _, c := range pod.Spec.Containers {
+		if c.Name == "daprd" {
+			return c
+		}
+	}
+	require.Fail(t, "daprd container not found")
+	return corev1.Container{}
+}
+
+func getArgValue(args []string, name string) string {
+	for i, a := range args {
+		if a == name && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func TestControlPlaneNamespaceOverride(t *testing.T) {
+	getPod := func(an map[string]string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: "default",
+				Annotations: map[string]string{
+					"dapr.io/enabled": "true",
+					"dapr.io/app-id":  "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		return pod
+	}
+
+	getEnv := func(container corev1.Container, name string) string {
+		for _, e := range container.Env {
+			if e.Name == name {
+				return e.Value
+			}
+		}
+		return ""
+	}
+
+	t.Run("default control plane", func(t *testing.T) {
+		inj := newTestInjector(t, Config{})
+		pod, err := patchTestPod(t, inj, getPod(nil))
+		require.NoError(t, err)
+
+		daprd := getTestDaprdContainer(t, pod)
+		assert.Equal(t, "dapr-sentry.dapr-system.svc.cluster.local:443", getArgValue(daprd.Args, "--sentry-address"))
+		assert.Equal(t, "dapr-api.dapr-system.svc.cluster.local:80", getArgValue(daprd.Args, "--control-plane-address"))
+		assert.Equal(t, "dapr-placement-server.dapr-system.svc.cluster.local:50005", getArgValue(daprd.Args, "--placement-host-address"))
+		assert.Equal(t, "dapr-system", getEnv(daprd, securityConsts.ControlPlaneNamespaceEnvVar))
+	})
+
+	t.Run("addresses point at the specified namespace", func(t *testing.T) {
+		inj := newTestInjector(t, Config{AllowedControlPlaneNamespaces: "dapr-tenant-a"})
+		pod, err := patchTestPod(t, inj, getPod(map[string]string{
+			"dapr.io/control-plane-namespace": "dapr-tenant-a",
+		}))
+		require.NoError(t, err)
+
+		daprd := getTestDaprdContainer(t, pod)
+		assert.Equal(t, "dapr-sentry.dapr-tenant-a.svc.cluster.local:443", getArgValue(daprd.Args, "--sentry-address"))
+		assert.Equal(t, "dapr-api.dapr-tenant-a.svc.cluster.local:80", getArgValue(daprd.Args, "--control-plane-address"))
+		assert.Equal(t, "dapr-placement-server.dapr-tenant-a.svc.cluster.local:50005", getArgValue(daprd.Args, "--placement-host-address"))
+		assert.Equal(t, "dapr-tenant-a", getEnv(daprd, securityConsts.ControlPlaneNamespaceEnvVar))
+	})
+
+	t.Run("explicit placement address is preserved", func(t *testing.T) {
+		inj := newTestInjector(t, Config{AllowedControlPlaneNamespaces: "dapr-tenant-a"})
+		pod, err := patchTestPod(t, inj, getPod(map[string]string{
+			"dapr.io/control-plane-namespace": "dapr-tenant-a",
+			"dapr.io/placement-host-address":  "placement:50005",
+		}))
+		require.NoError(t, err)
+
+		daprd := getTestDaprdContainer(t, pod)
+		assert.Equal(t, "placement:50005", getArgValue(daprd.Args, "--placement-host-address"))
+	})
+
+	t.Run("explicit sentry and operator addresses are preserved", func(t *testing.T) {
+		inj := newTestInjector(t, Config{AllowedControlPlaneNamespaces: "dapr-tenant-a", AllowControlPlaneAddresses: "true"})
+		pod, err := patchTestPod(t, inj, getPod(map[string]string{
+			"dapr.io/control-plane-namespace": "dapr-tenant-a",
+			"dapr.io/sentry-address":          "sentry.custom:4000",
+			"dapr.io/operator-address":        "operator.custom:4001",
+		}))
+		require.NoError(t, err)
+
+		daprd := getTestDaprdContainer(t, pod)
+		assert.Equal(t, "sentry.custom:4000", getArgValue(daprd.Args, "--sentry-address"))
+		assert.Equal(t, "operator.custom:4001", getArgValue(daprd.Args, "--control-plane-address"))
+		assert.Equal(t, "dapr-placement-server.dapr-tenant-a.svc.cluster.local:50005", getArgValue(daprd.Args, "--placement-host-address"))
+	})
+
+	t.Run("placement stays skipped", func(t *testing.T) {
+		inj := newTestInjector(t, Config{SkipPlacement: "true", AllowedControlPlaneNamespaces: "dapr-tenant-a"})
+		pod, err := patchTestPod(t, inj, getPod(map[string]string{
+			"dapr.io/control-plane-namespace": "dapr-tenant-a",
+		}))
+		require.NoError(t, err)
+
+		daprd := getTestDaprdContainer(t, pod)
+		assert.NotContains(t, daprd.Args, "--placement-host-address")
+	})
+
+	t.Run("invalid namespace is denied", func(t *testing.T) {
+		inj := newTestInjector(t, Config{})
+		_, err := patchTestPod(t, inj, getPod(map[string]string{
+			"dapr.io/control-plane-namespace": "Not_Valid",
+		}))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "dapr.io/control-plane-namespace")
+	})
+
+	t.Run("namespace not allowed is denied", func(t *testing.T) {
+		inj := newTestInjector(t, Config{AllowedControlPlaneNamespaces: "dapr-tenant-b"})
+		_, err := patchTestPod(t, inj, getPod(map[string]string{
+			"dapr.io/control-plane-namespace": "dapr-tenant-a",
+		}))
+		require.Error(t, err)
+		assert.Equal(t, patcher.DenialReasonInvalidAnnotation, patcher.GetDenialReason(err))
+		assert.Contains(t, err.Error(), "dapr-tenant-a")
+	})
+
+	t.Run("injector namespace is always allowed", func(t *testing.T) {
+		inj := newTestInjector(t, Config{})
+		pod, err := patchTestPod(t, inj, getPod(map[string]string{
+			"dapr.io/control-plane-namespace": "dapr-system",
+		}))
+		require.NoError(t, err)
+
+		daprd := getTestDaprdContainer(t, pod)
+		assert.Equal(t, "dapr-sentry.dapr-system.svc.cluster.local:443", getArgValue(daprd.Args, "--sentry-address"))
+	})
+}
+
+func TestInjectorVersionAnnotation(t *testing.T) {
+	inj := newTestInjector(t, Config{})
+	inj.injectorVersion = "1.12.0"
+
+	pod, err := patchTestPod(t, inj, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myapp",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"dapr.io/enabled": "true",
+				"dapr.io/app-id":  "myapp",
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "main", Image: "app:latest"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1.12.0", pod.Annotations[injectorConsts.InjectorVersionAnnotation])
+}
+
+func TestDefaultPodAnnotationsConfigureSidecar(t *testing.T) {
+	inj := newTestInjector(t, Config{DefaultPodAnnotations: `{"dapr.io/log-level":"debug"}`})
+
+	pod, err := patchTestPod(t, inj, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myapp",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"dapr.io/enabled": "true",
+				"dapr.io/app-id":  "myapp",
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "main", Image: "app:latest"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "debug", pod.Annotations["dapr.io/log-level"])
+	assert.Equal(t, "debug", getArgValue(getTestDaprdContainer(t, pod).Args, "--log-level"))
+}
+
+func TestWindowsPods(t *testing.T) {
+	getPod := func(windows bool) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: "default",
+				Annotations: map[string]string{
+					"dapr.io/enabled": "true",
+					"dapr.io/app-id":  "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+		if windows {
+			pod.Spec.NodeSelector = map[string]string{
+				"kubernetes.io/os": "windows",
+			}
+		}
+		return pod
+	}
+
+	t.Run("Windows pod gets the Windows image", func(t *testing.T) {
+		inj := newTestInjector(t, Config{
+			OnWindowsPod:        "windows",
+			WindowsSidecarImage: "daprio/daprd:windows",
+		})
+		pod, err := patchTestPod(t, inj, getPod(true))
+		require.NoError(t, err)
+
+		daprd := getTestDaprdContainer(t, pod)
+		assert.Equal(t, "daprio/daprd:windows", daprd.Image)
+		require.NotNil(t, daprd.SecurityContext)
+		assert.Nil(t, daprd.SecurityContext.Capabilities)
+		assert.Nil(t, daprd.SecurityContext.ReadOnlyRootFilesystem)
+		assert.Nil(t, daprd.SecurityContext.AllowPrivilegeEscalation)
+	})
+
+	t.Run("Linux pod gets the default image", func(t *testing.T) {
+		inj := newTestInjector(t, Config{
+			OnWindowsPod:        "windows",
+			WindowsSidecarImage: "daprio/daprd:windows",
+		})
+		pod, err := patchTestPod(t, inj, getPod(false))
+		require.NoError(t, err)
+
+		daprd := getTestDaprdContainer(t, pod)
+		assert.Equal(t, "test-image", daprd.Image)
+		require.NotNil(t, daprd.SecurityContext)
+		assert.NotNil(t, daprd.SecurityContext.AllowPrivilegeEscalation)
+	})
+
+	t.Run("image annotation takes precedence", func(t *testing.T) {
+		inj := newTestInjector(t, Config{
+			OnWindowsPod:        "windows",
+			WindowsSidecarImage: "daprio/daprd:windows",
+		})
+		pod := getPod(true)
+		pod.Annotations["dapr.io/sidecar-image"] = "custom"
+		pod, err := patchTestPod(t, inj, pod)
+		require.NoError(t, err)
+		assert.Equal(t, "custom", getTestDaprdContainer(t, pod).Image)
+	})
+
+	t.Run("Windows pod is injected like others by default", func(t *testing.T) {
+		inj := newTestInjector(t, Config{
+			WindowsSidecarImage: "daprio/daprd:windows",
+		})
+		pod, err := patchTestPod(t, inj, getPod(true))
+		require.NoError(t, err)
+		assert.Equal(t, "test-image", getTestDaprdContainer(t, pod).Image)
+	})
+
+	t.Run("Windows pod is skipped", func(t *testing.T) {
+		inj := newTestInjector(t, Config{
+			OnWindowsPod: "skip",
+		})
+		pod, err := patchTestPod(t, inj, getPod(true))
+		require.NoError(t, err)
+		assert.Len(t, pod.Spec.Containers, 1)
+
+		pod, err = patchTestPod(t, inj, getPod(false))
+		require.NoError(t, err)
+		assert.Len(t, pod.Spec.Containers, 2)
+	})
+}
+
+func TestReservedPortConflicts(t *testing.T) {
+	getPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: "default",
+				Annotations: map[string]string{
+					"dapr.io/enabled":  "true",
+					"dapr.io/app-id":   "myapp",
+					"dapr.io/app-port": "8080",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name:  "main",
+						Image: "app:latest",
+						Ports: []corev1.ContainerPort{
+							{ContainerPort: 8080},
+							{ContainerPort: 50001},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("pod is denied", func(t *testing.T) {
+		inj := newTestInjector(t, Config{})
+		_, err := patchTestPod(t, inj, getPod())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "conflicts with the Dapr gRPC port")
+	})
+
+	t.Run("sidecar ports are remapped", func(t *testing.T) {
+		inj := newTestInjector(t, Config{AutoRemapPorts: "true"})
+		pod, err := patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+
+		daprd := getTestDaprdContainer(t, pod)
+		assert.Equal(t, "50003", getArgValue(daprd.Args, "--dapr-grpc-port"))
+		assert.Equal(t, "3500", getArgValue(daprd.Args, "--dapr-http-port"))
+	})
+}
+
+func TestLivenessProbeDefaults(t *testing.T) {
+	getPod := func(an map[string]string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: "default",
+				Annotations: map[string]string{
+					"dapr.io/enabled": "true",
+					"dapr.io/app-id":  "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		return pod
+	}
+
+	cfg := Config{
+		SidecarLivenessProbeDelaySeconds:  10,
+		SidecarLivenessProbePeriodSeconds: 15,
+		SidecarLivenessProbeThreshold:     5,
+	}
+
+	t.Run("config defaults", func(t *testing.T) {
+		inj := newTestInjector(t, cfg)
+		pod, err := patchTestPod(t, inj, getPod(nil))
+		require.NoError(t, err)
+
+		probe := getTestDaprdContainer(t, pod).LivenessProbe
+		require.NotNil(t, probe)
+		assert.Equal(t, int32(10), probe.InitialDelaySeconds)
+		assert.Equal(t, int32(15), probe.PeriodSeconds)
+		assert.Equal(t, int32(5), probe.FailureThreshold)
+	})
+
+	t.Run("config defaults overridden by annotations", func(t *testing.T) {
+		inj := newTestInjector(t, cfg)
+		pod, err := patchTestPod(t, inj, getPod(map[string]string{
+			"dapr.io/sidecar-liveness-probe-delay-seconds":  "1",
+			"dapr.io/sidecar-liveness-probe-period-seconds": "2",
+			"dapr.io/sidecar-liveness-probe-threshold":      "3",
+		}))
+		require.NoError(t, err)
+
+		probe := getTestDaprdContainer(t, pod).LivenessProbe
+		require.NotNil(t, probe)
+		assert.Equal(t, int32(1), probe.InitialDelaySeconds)
+		assert.Equal(t, int32(2), probe.PeriodSeconds)
+		assert.Equal(t, int32(3), probe.FailureThreshold)
+	})
+
+	t.Run("built-in defaults when not configured", func(t *testing.T) {
+		inj := newTestInjector(t, Config{})
+		pod, err := patchTestPod(t, inj, getPod(nil))
+		require.NoError(t, err)
+
+		probe := getTestDaprdContainer(t, pod).LivenessProbe
+		require.NotNil(t, probe)
+		assert.Equal(t, int32(3), probe.InitialDelaySeconds)
+		assert.Equal(t, int32(6), probe.PeriodSeconds)
+		assert.Equal(t, int32(3), probe.FailureThreshold)
+	})
+}
+
+func TestAdditionalCASecrets(t *testing.T) {
+	getPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: "default",
+				Annotations: map[string]string{
+					"dapr.io/enabled": "true",
+					"dapr.io/app-id":  "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+	}
+
+	getVolume := func(pod *corev1.Pod) *corev1.Volume {
+		for _, v := range pod.Spec.Volumes {
+			if v.Name == injectorConsts.AdditionalCAVolumeName {
+				return &v
+			}
+		}
+		return nil
+	}
+
+	t.Run("not mounted by default", func(t *testing.T) {
+		inj := newTestInjector(t, Config{})
+		pod, err := patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+
+		assert.Nil(t, getVolume(pod))
+		for _, e := range getTestDaprdContainer(t, pod).Env {
+			assert.NotEqual(t, injectorConsts.SSLCertDirEnvVar, e.Name)
+		}
+	})
+
+	t.Run("multiple secrets are mounted in a projected volume", func(t *testing.T) {
+		inj := newTestInjector(t, Config{AdditionalCASecrets: "corp-ca, partner-ca"})
+		pod, err := patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+
+		vol := getVolume(pod)
+		require.NotNil(t, vol)
+		require.NotNil(t, vol.Projected)
+		require.Len(t, vol.Projected.Sources, 2)
+		assert.Equal(t, "corp-ca", vol.Projected.Sources[0].Secret.Name)
+		assert.Equal(t, "partner-ca", vol.Projected.Sources[1].Secret.Name)
+
+		daprd := getTestDaprdContainer(t, pod)
+		assert.Contains(t, daprd.VolumeMounts, corev1.VolumeMount{
+			Name:      injectorConsts.AdditionalCAVolumeName,
+			MountPath: injectorConsts.AdditionalCAVolumeMountPath,
+			ReadOnly:  true,
+		})
+		assert.Contains(t, daprd.Env, corev1.EnvVar{
+			Name:  injectorConsts.SSLCertDirEnvVar,
+			Value: injectorConsts.AdditionalCAVolumeMountPath,
+		})
+	})
+}
+
+func TestForcedAnnotationsOverrideUserValues(t *testing.T) {
+	getPod := func(an map[string]string) *corev1.Pod {
+		an["dapr.io/enabled"] = "true"
+		an["dapr.io/app-id"] = "myapp"
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "myapp",
+				Namespace:   "default",
+				Annotations: an,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+	}
+
+	newInjector := func(t *testing.T) *injector {
+		cfg := Config{
+			ForcedAnnotations: `{"dapr.io/log-as-json":"true","dapr.io/log-level":"warn"}`,
+		}
+		require.NoError(t, cfg.parseJSON())
+		return newTestInjector(t, cfg)
+	}
+
+	t.Run("forced values override user-set ones", func(t *testing.T) {
+		pod, err := patchTestPod(t, newInjector(t), getPod(map[string]string{
+			"dapr.io/log-as-json": "false",
+			"dapr.io/log-level":   "debug",
+		}))
+		require.NoError(t, err)
+
+		args := getTestDaprdContainer(t, pod).Args
+		assert.Contains(t, args, "--log-as-json")
+		assert.Equal(t, "warn", getArgValue(args, "--log-level"))
+		assert.Equal(t, "true", pod.Annotations["dapr.io/log-as-json"])
+		assert.Equal(t, "warn", pod.Annotations["dapr.io/log-level"])
+	})
+
+	t.Run("forced values override the defaults", func(t *testing.T) {
+		pod, err := patchTestPod(t, newInjector(t), getPod(map[string]string{}))
+		require.NoError(t, err)
+
+		args := getTestDaprdContainer(t, pod).Args
+		assert.Contains(t, args, "--log-as-json")
+		assert.Equal(t, "warn", getArgValue(args, "--log-level"))
+	})
+
+	t.Run("user values are kept if not forced", func(t *testing.T) {
+		pod, err := patchTestPod(t, newTestInjector(t, Config{}), getPod(map[string]string{
+			"dapr.io/log-level": "debug",
+		}))
+		require.NoError(t, err)
+
+		args := getTestDaprdContainer(t, pod).Args
+		assert.NotContains(t, args, "--log-as-json")
+		assert.Equal(t, "debug", getArgValue(args, "--log-level"))
+	})
+}
+
+func TestNamespaceLogLevels(t *testing.T) {
+	getPod := func(namespace string, an map[string]string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: namespace,
+				Annotations: map[string]string{
+					"dapr.io/enabled": "true",
+					"dapr.io/app-id":  "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		return pod
+	}
+
+	inj := newTestInjector(t, Config{NamespaceLogLevels: "dev-*=debug, prod-*=info, prod-eu=warn"})
+
+	t.Run("namespace matching a prefix", func(t *testing.T) {
+		pod, err := patchTestPod(t, inj, getPod("dev-team1", nil))
+		require.NoError(t, err)
+		assert.Equal(t, "debug", getArgValue(getTestDaprdContainer(t, pod).Args, "--log-level"))
+	})
+
+	t.Run("exact namespace takes precedence over prefixes", func(t *testing.T) {
+		pod, err := patchTestPod(t, inj, getPod("prod-eu", nil))
+		require.NoError(t, err)
+		assert.Equal(t, "warn", getArgValue(getTestDaprdContainer(t, pod).Args, "--log-level"))
+	})
+
+	t.Run("namespace not matching uses the default", func(t *testing.T) {
+		pod, err := patchTestPod(t, inj, getPod("default", nil))
+		require.NoError(t, err)
+		assert.Equal(t, "info", getArgValue(getTestDaprdContainer(t, pod).Args, "--log-level"))
+	})
+
+	t.Run("annotation takes precedence over the namespace", func(t *testing.T) {
+		pod, err := patchTestPod(t, inj, getPod("dev-team1", map[string]string{
+			"dapr.io/log-level": "error",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "error", getArgValue(getTestDaprdContainer(t, pod).Args, "--log-level"))
+	})
+}
+
+func TestDefaultConfigPerNamespace(t *testing.T) {
+	getPod := func(namespace string, an map[string]string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: namespace,
+				Annotations: map[string]string{
+					"dapr.io/enabled": "true",
+					"dapr.io/app-id":  "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		return pod
+	}
+
+	inj := newTestInjector(t, Config{DefaultConfigPerNamespace: "prod-*=prodconfig,prod-eu=euconfig"})
+
+	t.Run("namespace matching a prefix", func(t *testing.T) {
+		pod, err := patchTestPod(t, inj, getPod("prod-us", nil))
+		require.NoError(t, err)
+		assert.Equal(t, "prodconfig", getArgValue(getTestDaprdContainer(t, pod).Args, "--config"))
+	})
+
+	t.Run("exact namespace takes precedence over prefixes", func(t *testing.T) {
+		pod, err := patchTestPod(t, inj, getPod("prod-eu", nil))
+		require.NoError(t, err)
+		assert.Equal(t, "euconfig", getArgValue(getTestDaprdContainer(t, pod).Args, "--config"))
+	})
+
+	t.Run("namespace not matching has no config", func(t *testing.T) {
+		pod, err := patchTestPod(t, inj, getPod("dev", nil))
+		require.NoError(t, err)
+		assert.NotContains(t, getTestDaprdContainer(t, pod).Args, "--config")
+	})
+
+	t.Run("annotation takes precedence over the namespace", func(t *testing.T) {
+		pod, err := patchTestPod(t, inj, getPod("prod-us", map[string]string{
+			"dapr.io/config": "myconfig",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "myconfig", getArgValue(getTestDaprdContainer(t, pod).Args, "--config"))
+	})
+}
+
+func TestEnforceMTLSPerNamespace(t *testing.T) {
+	getPod := func(namespace string, an map[string]string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: namespace,
+				Annotations: map[string]string{
+					"dapr.io/enabled": "true",
+					"dapr.io/app-id":  "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		return pod
+	}
+
+	// mTLS is enabled by default, as there's no Dapr system configuration
+	inj := newTestInjector(t, Config{EnforceMTLSPerNamespace: "prod-*=true,legacy=false"})
+
+	tests := []struct {
+		name       string
+		namespace  string
+		annotation string
+		expect     bool
+	}{
+		{name: "default without enforcement", namespace: "dev", expect: true},
+		{name: "annotation without enforcement", namespace: "dev", annotation: "false", expect: false},
+		{name: "enforced enabled", namespace: "prod-us", expect: true},
+		{name: "enforced enabled takes precedence over the annotation", namespace: "prod-us", annotation: "false", expect: true},
+		{name: "enforced disabled", namespace: "legacy", expect: false},
+		{name: "enforced disabled takes precedence over the annotation", namespace: "legacy", annotation: "true", expect: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			an := map[string]string{}
+			if tc.annotation != "" {
+				an["dapr.io/enable-mtls"] = tc.annotation
+			}
+			pod, err := patchTestPod(t, inj, getPod(tc.namespace, an))
+			require.NoError(t, err)
+
+			args := getTestDaprdContainer(t, pod).Args
+			if tc.expect {
+				assert.Contains(t, args, "--enable-mtls")
+			} else {
+				assert.NotContains(t, args, "--enable-mtls")
+			}
+		})
+	}
+}
+
+func TestPodUpdate(t *testing.T) {
+	getPod := func(containers ...string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: "default",
+				Annotations: map[string]string{
+					"dapr.io/enabled": "true",
+					"dapr.io/app-id":  "myapp",
+				},
+			},
+		}
+		for _, name := range containers {
+			pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: name, Image: name + ":latest"})
+		}
+		return pod
+	}
+	update := func(t *testing.T, inj *injector, pod *corev1.Pod) (jsonpatch.Patch, []string) {
+		t.Helper()
+
+		podBytes, err := json.Marshal(pod)
+		require.NoError(t, err)
+		patch, warnings, err := inj.getPodPatchOperations(context.Background(), &admissionv1.AdmissionReview{
+			Request: &admissionv1.AdmissionRequest{
+				Namespace: pod.Namespace,
+				Operation: admissionv1.Update,
+				Object:    runtime.RawExtension{Raw: podBytes},
+			},
+		})
+		require.NoError(t, err)
+		return patch, warnings
+	}
+
+	inj := newTestInjector(t, Config{})
+
+	t.Run("pod with the sidecar is not changed", func(t *testing.T) {
+		patch, warnings := update(t, inj, getPod("main", "daprd"))
+		assert.Empty(t, patch)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("missing sidecar is reported but not injected", func(t *testing.T) {
+		patch, warnings := update(t, inj, getPod("main"))
+		assert.Empty(t, patch)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "re-create the pod")
+	})
+
+	t.Run("pod without Dapr is not changed", func(t *testing.T) {
+		pod := getPod("main")
+		pod.Annotations = nil
+		patch, warnings := update(t, inj, pod)
+		assert.Empty(t, patch)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("pod that would not be injected is not reported", func(t *testing.T) {
+		skipInj := newTestInjector(t, Config{OnlyInjectForOwnerKinds: "StatefulSet"})
+		patch, warnings := update(t, skipInj, getPod("main"))
+		assert.Empty(t, patch)
+		assert.Empty(t, warnings)
+	})
+}
+
+func TestOnlyInjectForOwnerKinds(t *testing.T) {
+	getPod := func(owners ...metav1.OwnerReference) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: "default",
+				Annotations: map[string]string{
+					"dapr.io/enabled": "true",
+					"dapr.io/app-id":  "myapp",
+				},
+				OwnerReferences: owners,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+	}
+
+	inj := newTestInjector(t, Config{OnlyInjectForOwnerKinds: "Deployment, StatefulSet"})
+
+	t.Run("pod owned by an allowed kind is injected", func(t *testing.T) {
+		pod, err := patchTestPod(t, inj, getPod(metav1.OwnerReference{Kind: "StatefulSet", Name: "myapp", Controller: ptr.Of(true)}))
+		require.NoError(t, err)
+		getTestDaprdContainer(t, pod)
+	})
+
+	t.Run("pod owned by another kind is skipped", func(t *testing.T) {
+		pod, err := patchTestPod(t, inj, getPod(metav1.OwnerReference{Kind: "DaemonSet", Name: "myapp", Controller: ptr.Of(true)}))
+		require.NoError(t, err)
+		assert.Len(t, pod.Spec.Containers, 1)
+	})
+
+	t.Run("bare pod is skipped", func(t *testing.T) {
+		pod, err := patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+		assert.Len(t, pod.Spec.Containers, 1)
+	})
+}
+
+func TestSidecarImageUsedAnnotation(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("1a", 32)
+
+	newInjector := func(t *testing.T) *injector {
+		inj := newTestInjector(t, Config{
+			SidecarImage:        "daprio/daprd:1.12.0",
+			WindowsSidecarImage: "daprio/daprd:1.12.0-windows",
+			OnWindowsPod:        string(patcher.WindowsPodWindows),
+			ImageDigestMap:      `{"daprio/daprd:1.12.0":"` + digest + `","daprio/daprd:1.12.0-windows":"` + digest + `"}`,
+		})
+		require.NoError(t, inj.config.parseJSON())
+		return inj
+	}
+
+	getPod := func(an map[string]string, windows bool) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: "default",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		if windows {
+			pod.Spec.NodeSelector = map[string]string{corev1.LabelOSStable: string(corev1.Windows)}
+		}
+		return pod
+	}
+
+	tests := []struct {
+		name   string
+		pod    *corev1.Pod
+		expect string
+	}{
+		{
+			name:   "default image pinned by digest",
+			pod:    getPod(nil, false),
+			expect: "daprio/daprd:1.12.0@" + digest,
+		},
+		{
+			name:   "windows image pinned by digest",
+			pod:    getPod(nil, true),
+			expect: "daprio/daprd:1.12.0-windows@" + digest,
+		},
+		{
+			name: "image from annotation without a digest",
+			pod: getPod(map[string]string{
+				annotations.KeySidecarImage: "daprio/daprd:edge",
+			}, false),
+			expect: "daprio/daprd:edge",
+		},
+		{
+			name: "image from annotation pinned by digest",
+			pod: getPod(map[string]string{
+				annotations.KeySidecarImage: "daprio/daprd:1.12.0",
+			}, true),
+			expect: "daprio/daprd:1.12.0@" + digest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pod, err := patchTestPod(t, newInjector(t), tc.pod)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expect, pod.Annotations[injectorConsts.SidecarImageUsedAnnotation])
+			assert.Equal(t, tc.expect, getTestDaprdContainer(t, pod).Image)
+		})
+	}
+}
+
+func TestSidecarDownwardAPIEnv(t *testing.T) {
+	getPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: "default",
+				Annotations: map[string]string{
+					annotations.KeyEnabled: "true",
+					annotations.KeyAppID:   "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+	}
+
+	hasEnv := func(container corev1.Container, name string) bool {
+		for _, e := range container.Env {
+			if e.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		inj := newTestInjector(t, Config{})
+		pod, err := patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+
+		daprd := getTestDaprdContainer(t, pod)
+		assert.False(t, hasEnv(daprd, injectorConsts.NodeNameEnvVar))
+		assert.False(t, hasEnv(daprd, injectorConsts.PodIPEnvVar))
+	})
+
+	t.Run("enabled in the configuration", func(t *testing.T) {
+		inj := newTestInjector(t, Config{SidecarDownwardAPIEnv: "true"})
+		pod, err := patchTestPod(t, inj, getPod())
+		require.NoError(t, err)
+
+		daprd := getTestDaprdContainer(t, pod)
+		assert.True(t, hasEnv(daprd, injectorConsts.PodNamespaceEnvVar))
+		assert.True(t, hasEnv(daprd, injectorConsts.NodeNameEnvVar))
+		assert.True(t, hasEnv(daprd, injectorConsts.PodIPEnvVar))
+	})
+}
@@ -0,0 +1,37 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"github.com/dapr/dapr/pkg/injector/patcher"
+)
+
+// getResourceProfile returns the resource profile for the sidecar of pods in the namespace.
+// This is the profile the namespace is mapped to, if any, or the default profile otherwise.
+// Returns false if there's no profile to apply.
+func (i *injector) getResourceProfile(namespace string) (patcher.ResourceProfile, bool) {
+	name := i.config.DefaultResourceProfile
+	if i.namespaceResourceProfiles != nil {
+		if n, ok := i.namespaceResourceProfiles.Get(namespace); ok {
+			name = n
+		}
+	}
+	if name == "" {
+		return patcher.ResourceProfile{}, false
+	}
+
+	// Profile names are validated when the injector is created
+	profile, ok := i.config.GetResourceProfiles()[name]
+	return profile, ok
+}
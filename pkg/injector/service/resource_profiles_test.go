@@ -0,0 +1,204 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dapr/dapr/pkg/injector/patcher"
+)
+
+const testResourceProfiles = `{
+	"small": {"cpuRequest": "50m", "memoryRequest": "64Mi", "memoryLimit": "128Mi"},
+	"large": {"cpuRequest": "500m", "cpuLimit": "2", "memoryRequest": "256Mi", "memoryLimit": "1Gi"}
+}`
+
+func TestResourceProfilesParsing(t *testing.T) {
+	t.Run("valid profiles", func(t *testing.T) {
+		c := &Config{ResourceProfiles: testResourceProfiles}
+		require.NoError(t, c.parseJSON())
+		assert.Equal(t, map[string]patcher.ResourceProfile{
+			"small": {CPURequest: "50m", MemoryRequest: "64Mi", MemoryLimit: "128Mi"},
+			"large": {CPURequest: "500m", CPULimit: "2", MemoryRequest: "256Mi", MemoryLimit: "1Gi"},
+		}, c.GetResourceProfiles())
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		c := &Config{ResourceProfiles: "hi"}
+		err := c.validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "sidecar resource profiles")
+	})
+}
+
+func TestResourceProfilesValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       Config
+		wantError string
+	}{
+		{
+			name: "valid",
+			cfg: Config{
+				ResourceProfiles:          testResourceProfiles,
+				DefaultResourceProfile:    "small",
+				NamespaceResourceProfiles: "prod-*=large",
+			},
+		},
+		{
+			name: "unknown default profile",
+			cfg: Config{
+				ResourceProfiles:       testResourceProfiles,
+				DefaultResourceProfile: "medium",
+			},
+			wantError: "unknown resource profile 'medium'",
+		},
+		{
+			name: "unknown namespace profile",
+			cfg: Config{
+				ResourceProfiles:          testResourceProfiles,
+				NamespaceResourceProfiles: "prod-*=large,dev-*=medium",
+			},
+			wantError: "unknown resource profile 'medium'",
+		},
+		{
+			name: "namespace profile without profiles",
+			cfg: Config{
+				NamespaceResourceProfiles: "dev-*=small",
+			},
+			wantError: "unknown resource profile 'small'",
+		},
+		{
+			name: "invalid namespace mapping",
+			cfg: Config{
+				ResourceProfiles:          testResourceProfiles,
+				NamespaceResourceProfiles: "dev-*",
+			},
+			wantError: "invalid value for namespace resource profiles",
+		},
+		{
+			name: "invalid quantity",
+			cfg: Config{
+				ResourceProfiles: `{"bad": {"cpuLimit": "lots"}}`,
+			},
+			wantError: "invalid resource profile 'bad'",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.validate()
+			if tc.wantError == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantError)
+			}
+		})
+	}
+}
+
+func TestResourceProfileResolution(t *testing.T) {
+	cfg := Config{
+		ResourceProfiles:          testResourceProfiles,
+		DefaultResourceProfile:    "small",
+		NamespaceResourceProfiles: "prod-*=large",
+	}
+	require.NoError(t, cfg.parseJSON())
+
+	getPod := func(namespace string, an map[string]string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myapp",
+				Namespace: namespace,
+				Annotations: map[string]string{
+					"dapr.io/enabled": "true",
+					"dapr.io/app-id":  "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+		for k, v := range an {
+			pod.Annotations[k] = v
+		}
+		return pod
+	}
+
+	t.Run("global default", func(t *testing.T) {
+		inj := newTestInjector(t, cfg)
+		pod, err := patchTestPod(t, inj, getPod("dev", nil))
+		require.NoError(t, err)
+
+		resources := getTestDaprdContainer(t, pod).Resources
+		assert.Equal(t, "50m", resources.Requests.Cpu().String())
+		assert.Equal(t, "64Mi", resources.Requests.Memory().String())
+		assert.Equal(t, "128Mi", resources.Limits.Memory().String())
+		assert.True(t, resources.Limits.Cpu().IsZero())
+	})
+
+	t.Run("namespace profile takes precedence over the default", func(t *testing.T) {
+		inj := newTestInjector(t, cfg)
+		pod, err := patchTestPod(t, inj, getPod("prod-eu", nil))
+		require.NoError(t, err)
+
+		resources := getTestDaprdContainer(t, pod).Resources
+		assert.Equal(t, "500m", resources.Requests.Cpu().String())
+		assert.Equal(t, "2", resources.Limits.Cpu().String())
+		assert.Equal(t, "256Mi", resources.Requests.Memory().String())
+		assert.Equal(t, "1Gi", resources.Limits.Memory().String())
+	})
+
+	t.Run("annotations take precedence over the namespace profile", func(t *testing.T) {
+		inj := newTestInjector(t, cfg)
+		pod, err := patchTestPod(t, inj, getPod("prod-eu", map[string]string{
+			"dapr.io/sidecar-cpu-limit":      "4",
+			"dapr.io/sidecar-memory-request": "512Mi",
+		}))
+		require.NoError(t, err)
+
+		resources := getTestDaprdContainer(t, pod).Resources
+		assert.Equal(t, "500m", resources.Requests.Cpu().String())
+		assert.Equal(t, "4", resources.Limits.Cpu().String())
+		assert.Equal(t, "512Mi", resources.Requests.Memory().String())
+		assert.Equal(t, "1Gi", resources.Limits.Memory().String())
+	})
+
+	t.Run("no profile", func(t *testing.T) {
+		noDefault := cfg
+		noDefault.DefaultResourceProfile = ""
+		inj := newTestInjector(t, noDefault)
+		pod, err := patchTestPod(t, inj, getPod("dev", nil))
+		require.NoError(t, err)
+
+		resources := getTestDaprdContainer(t, pod).Resources
+		assert.Empty(t, resources.Requests)
+		assert.Empty(t, resources.Limits)
+	})
+
+	t.Run("unknown profile is rejected when the injector is created", func(t *testing.T) {
+		unknown := cfg
+		unknown.NamespaceResourceProfiles = "prod-*=huge"
+		_, err := NewInjector(Options{Config: unknown})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown resource profile 'huge'")
+	})
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	configapi "github.com/dapr/dapr/pkg/apis/configuration/v1alpha1"
+	"github.com/dapr/dapr/pkg/config"
+	"github.com/dapr/dapr/pkg/injector/annotations"
+)
+
+// getSecretScopesWarnings returns warnings if the Configuration used by the sidecar doesn't restrict the secrets the app can access.
+// Secrets are broadly scoped if the sidecar has no Configuration, if the Configuration doesn't define secret scopes, or if a scope allows access by default without a list of allowed secrets.
+// If the Configuration can't be fetched, the error is logged and no warning is returned.
+func (i *injector) getSecretScopesWarnings(ctx context.Context, namespace string, configName string) []string {
+	const allSecrets = "the app can access all secrets in all secret stores"
+	if configName == "" {
+		return []string{fmt.Sprintf("annotation %s is not set: %s", annotations.KeyConfig, allSecrets)}
+	}
+
+	// The generated client doesn't accept a context, so the request is made with the REST client to bind it to the admission request
+	cfg := &configapi.Configuration{}
+	err := i.daprClient.ConfigurationV1alpha1().RESTClient().
+		Get().
+		Namespace(namespace).
+		Resource("configurations").
+		Name(configName).
+		Do(ctx).
+		Into(cfg)
+	if apierrors.IsNotFound(err) {
+		return []string{fmt.Sprintf("configuration '%s' does not exist in namespace '%s': %s", configName, namespace, allSecrets)}
+	} else if err != nil {
+		log.Warnf("Could not verify the secret scopes of configuration '%s' in namespace '%s': %v", configName, namespace, err)
+		return nil
+	}
+
+	if cfg.Spec.Secrets == nil || len(cfg.Spec.Secrets.Scopes) == 0 {
+		return []string{fmt.Sprintf("configuration '%s' does not define secret scopes: %s", configName, allSecrets)}
+	}
+
+	var warnings []string
+	for _, scope := range cfg.Spec.Secrets.Scopes {
+		if len(scope.AllowedSecrets) == 0 && !strings.EqualFold(scope.DefaultAccess, config.DenyAccess) {
+			warnings = append(warnings, fmt.Sprintf("secret scope for store '%s' in configuration '%s' allows access to all secrets: set defaultAccess to '%s' or list the allowedSecrets", scope.StoreName, configName, config.DenyAccess))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	restfake "k8s.io/client-go/rest/fake"
+
+	configapi "github.com/dapr/dapr/pkg/apis/configuration/v1alpha1"
+	"github.com/dapr/dapr/pkg/client/clientset/versioned"
+	"github.com/dapr/dapr/pkg/client/clientset/versioned/scheme"
+)
+
+func newJSONResponse(t *testing.T, statusCode int, obj any) *http.Response {
+	t.Helper()
+
+	body, err := json.Marshal(obj)
+	require.NoError(t, err)
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+func TestSecretScopesWarnings(t *testing.T) {
+	newConfiguration := func(name string, secrets *configapi.SecretsSpec) *configapi.Configuration {
+		return &configapi.Configuration{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: configapi.ConfigurationSpec{
+				Secrets: secrets,
+			},
+		}
+	}
+
+	getWarnings := func(t *testing.T, cfg Config, an map[string]string, configurations ...*configapi.Configuration) []string {
+		t.Helper()
+
+		// Configurations are fetched with the REST client, so they are served by a fake HTTP client
+		restClient := &restfake.RESTClient{
+			NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+			Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+				name := path.Base(req.URL.Path)
+				for _, c := range configurations {
+					if c.Name == name {
+						return newJSONResponse(t, http.StatusOK, c), nil
+					}
+				}
+				return newJSONResponse(t, http.StatusNotFound, &apierrors.NewNotFound(configapi.Resource("configurations"), name).ErrStatus), nil
+			}),
+		}
+		inj := newTestInjector(t, cfg)
+		inj.daprClient = versioned.New(restClient)
+
+		an["dapr.io/enabled"] = "true"
+		an["dapr.io/app-id"] = "myapp"
+		an["dapr.io/app-port"] = "3000"
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "myapp",
+				Namespace:   "default",
+				Annotations: an,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+		podBytes, err := json.Marshal(pod)
+		require.NoError(t, err)
+
+		_, warnings, err := inj.getPodPatchOperations(context.Background(), &admissionv1.AdmissionReview{
+			Request: &admissionv1.AdmissionRequest{
+				Namespace: "default",
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: podBytes},
+			},
+		})
+		require.NoError(t, err)
+		return warnings
+	}
+
+	enabled := Config{WarnBroadSecretScopes: "true"}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		warnings := getWarnings(t, Config{}, map[string]string{})
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("no configuration", func(t *testing.T) {
+		warnings := getWarnings(t, enabled, map[string]string{})
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "dapr.io/config")
+	})
+
+	t.Run("configuration not found", func(t *testing.T) {
+		warnings := getWarnings(t, enabled, map[string]string{"dapr.io/config": "myconfig"})
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "does not exist")
+	})
+
+	t.Run("configuration without secret scopes", func(t *testing.T) {
+		warnings := getWarnings(t, enabled, map[string]string{"dapr.io/config": "myconfig"},
+			newConfiguration("myconfig", nil),
+		)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "does not define secret scopes")
+	})
+
+	t.Run("broad and restricted scopes", func(t *testing.T) {
+		warnings := getWarnings(t, enabled, map[string]string{"dapr.io/config": "myconfig"},
+			newConfiguration("myconfig", &configapi.SecretsSpec{
+				Scopes: []configapi.SecretsScope{
+					{StoreName: "allow-by-default"},
+					{StoreName: "explicit-allow", DefaultAccess: "allow", DeniedSecrets: []string{"a"}},
+					{StoreName: "deny-by-default", DefaultAccess: "Deny"},
+					{StoreName: "allow-list", AllowedSecrets: []string{"a"}},
+				},
+			}),
+		)
+		require.Len(t, warnings, 2)
+		assert.Contains(t, warnings[0], "store 'allow-by-default'")
+		assert.Contains(t, warnings[1], "store 'explicit-allow'")
+	})
+
+	t.Run("restricted scopes", func(t *testing.T) {
+		warnings := getWarnings(t, enabled, map[string]string{"dapr.io/config": "myconfig"},
+			newConfiguration("myconfig", &configapi.SecretsSpec{
+				Scopes: []configapi.SecretsScope{
+					{StoreName: "kubernetes", DefaultAccess: "deny"},
+				},
+			}),
+		)
+		assert.Empty(t, warnings)
+	})
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/dapr/dapr/pkg/injector/patcher"
+)
+
+// Resync period for the informer on the pods with a sidecar.
+const sidecarQuotaInformerResync = 10 * time.Minute
+
+// Label selector for the pods that have a sidecar injected.
+const sidecarInjectedSelector = "dapr.io/sidecar-injected=true"
+
+// sidecarQuota counts the pods in the cluster that have a sidecar, so injections can be stopped once a cap is reached.
+// Pods are read from a shared informer cache that only includes pods with the sidecar; until the cache is synced, the count isn't known and pods are denied.
+// Because requests that are being admitted concurrently are not counted until the pods are created, the cap may be exceeded slightly.
+type sidecarQuota struct {
+	maxSidecars int
+	factory     informers.SharedInformerFactory
+	lister      corev1listers.PodLister
+	synced      cache.InformerSynced
+}
+
+func newSidecarQuota(kubeClient kubernetes.Interface, maxSidecars int) *sidecarQuota {
+	factory := informers.NewSharedInformerFactoryWithOptions(kubeClient, sidecarQuotaInformerResync,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = sidecarInjectedSelector
+		}),
+	)
+	informer := factory.Core().V1().Pods()
+	return &sidecarQuota{
+		maxSidecars: maxSidecars,
+		factory:     factory,
+		lister:      informer.Lister(),
+		synced:      informer.Informer().HasSynced,
+	}
+}
+
+// Start starts the informer.
+// It doesn't wait for the cache to be synced, so the webhook server isn't blocked (for example, if the injector lacks permissions to watch pods); pods are denied until the cache is synced.
+func (q *sidecarQuota) Start(ctx context.Context) {
+	if q.factory == nil {
+		return
+	}
+	q.factory.Start(ctx.Done())
+}
+
+// Count returns the number of pods with a sidecar that are not terminated.
+// It returns an error if the cache isn't synced yet, so the count isn't known.
+func (q *sidecarQuota) Count() (int, error) {
+	if q.synced != nil && !q.synced() {
+		return 0, errors.New("the number of pods with a Dapr sidecar is not known yet: the cache of the pods isn't synced")
+	}
+	pods, err := q.lister.List(labels.Everything())
+	if err != nil {
+		return 0, fmt.Errorf("error when listing the pods with a sidecar: %w", err)
+	}
+	n := 0
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Check returns an error if the number of pods with a sidecar has reached the cap.
+// Errors when counting the pods are returned as internal errors, so pods are denied rather than admitted without a count.
+func (q *sidecarQuota) Check() error {
+	n, err := q.Count()
+	if err != nil {
+		return patcher.NewDenialError(patcher.DenialReasonInternal, err)
+	}
+	if n >= q.maxSidecars {
+		return patcher.NewDenialError(patcher.DenialReasonSidecarQuotaExceeded, fmt.Errorf("the cluster has %d pods with a Dapr sidecar, which reached the maximum of %d", n, q.maxSidecars))
+	}
+	return nil
+}
+
+// checkSidecarQuota returns an error if the pod can't be injected because the cap on the number of sidecars has been reached.
+// If the injector is configured to fail open and the cap has been reached, the error is returned as a warning instead, and the pod is admitted without the sidecar.
+// Errors when counting the pods always deny the pod.
+func (i *injector) checkSidecarQuota() (warning string, err error) {
+	err = i.sidecarQuota.Check()
+	if err == nil {
+		return "", nil
+	}
+	if !i.config.GetSidecarQuotaFailOpen() || patcher.GetDenialReason(err) != patcher.DenialReasonSidecarQuotaExceeded {
+		return "", err
+	}
+	log.Warnf("Skipping injection: %v", err)
+	return fmt.Sprintf("the Dapr sidecar was not injected: %v", err), nil
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/dapr/dapr/pkg/injector/patcher"
+)
+
+func TestSidecarQuota(t *testing.T) {
+	newPod := func(name string, injected bool, phase corev1.PodPhase) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Status: corev1.PodStatus{
+				Phase: phase,
+			},
+		}
+		if injected {
+			pod.Labels = map[string]string{"dapr.io/sidecar-injected": "true"}
+		}
+		return pod
+	}
+	startQuota := func(t *testing.T, maxSidecars int, objs ...runtime.Object) *sidecarQuota {
+		q := newSidecarQuota(kubernetesfake.NewSimpleClientset(objs...), maxSidecars)
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		q.Start(ctx)
+		require.True(t, cache.WaitForCacheSync(ctx.Done(), q.synced))
+		return q
+	}
+	existingPods := []runtime.Object{
+		newPod("a", true, corev1.PodRunning),
+		newPod("b", true, corev1.PodPending),
+		newPod("c", true, corev1.PodSucceeded),
+		newPod("d", false, corev1.PodRunning),
+	}
+
+	t.Run("only running pods with a sidecar are counted", func(t *testing.T) {
+		q := startQuota(t, 10, existingPods...)
+		n, err := q.Count()
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+	})
+
+	t.Run("pods are denied until the cache is synced", func(t *testing.T) {
+		q := newSidecarQuota(kubernetesfake.NewSimpleClientset(existingPods...), 10)
+		err := q.Check()
+		require.Error(t, err)
+		assert.Equal(t, patcher.DenialReasonInternal, patcher.GetDenialReason(err))
+		assert.Contains(t, err.Error(), "isn't synced")
+	})
+
+	t.Run("below the cap", func(t *testing.T) {
+		q := startQuota(t, 3, existingPods...)
+		require.NoError(t, q.Check())
+	})
+
+	t.Run("cap reached", func(t *testing.T) {
+		q := startQuota(t, 2, existingPods...)
+		err := q.Check()
+		require.Error(t, err)
+		assert.Equal(t, patcher.DenialReasonSidecarQuotaExceeded, patcher.GetDenialReason(err))
+	})
+}
+
+func TestSidecarQuotaInjection(t *testing.T) {
+	getPod := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Annotations: map[string]string{
+					"dapr.io/enabled": "true",
+					"dapr.io/app-id":  "myapp",
+				},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "app:latest"},
+				},
+			},
+		}
+	}
+	newInjector := func(t *testing.T, cfg Config, existing int) *injector {
+		objs := make([]runtime.Object, existing)
+		for n := 0; n < existing; n++ {
+			pod := getPod(fmt.Sprintf("existing-%d", n))
+			pod.Labels = map[string]string{"dapr.io/sidecar-injected": "true"}
+			objs[n] = pod
+		}
+
+		inj := newTestInjector(t, cfg)
+		require.NotNil(t, inj.sidecarQuota)
+		inj.sidecarQuota = newSidecarQuota(kubernetesfake.NewSimpleClientset(objs...), cfg.MaxInjectedSidecars)
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		inj.sidecarQuota.Start(ctx)
+		require.True(t, cache.WaitForCacheSync(ctx.Done(), inj.sidecarQuota.synced))
+		return inj
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		inj := newTestInjector(t, Config{})
+		assert.Nil(t, inj.sidecarQuota)
+	})
+
+	t.Run("injected below the cap", func(t *testing.T) {
+		inj := newInjector(t, Config{MaxInjectedSidecars: 2}, 1)
+		pod, err := patchTestPod(t, inj, getPod("myapp"))
+		require.NoError(t, err)
+		getTestDaprdContainer(t, pod)
+	})
+
+	t.Run("denied once the cap is reached", func(t *testing.T) {
+		inj := newInjector(t, Config{MaxInjectedSidecars: 2}, 2)
+		_, err := patchTestPod(t, inj, getPod("myapp"))
+		require.Error(t, err)
+		assert.Equal(t, patcher.DenialReasonSidecarQuotaExceeded, patcher.GetDenialReason(err))
+	})
+
+	t.Run("admitted without the sidecar when failing open", func(t *testing.T) {
+		inj := newInjector(t, Config{MaxInjectedSidecars: 2, SidecarQuotaFailOpen: "true"}, 3)
+		pod := getPod("myapp")
+		podBytes, err := json.Marshal(pod)
+		require.NoError(t, err)
+		patch, warnings, err := inj.getPodPatchOperations(context.Background(), &admissionv1.AdmissionReview{
+			Request: &admissionv1.AdmissionRequest{
+				Namespace: pod.Namespace,
+				Object:    runtime.RawExtension{Raw: podBytes},
+			},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, patch)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "reached the maximum of 2")
+	})
+
+	t.Run("denied when failing open if the cache isn't synced", func(t *testing.T) {
+		inj := newTestInjector(t, Config{MaxInjectedSidecars: 2, SidecarQuotaFailOpen: "true"})
+		inj.sidecarQuota = newSidecarQuota(kubernetesfake.NewSimpleClientset(), 2)
+		_, err := patchTestPod(t, inj, getPod("myapp"))
+		require.Error(t, err)
+		assert.Equal(t, patcher.DenialReasonInternal, patcher.GetDenialReason(err))
+	})
+
+	t.Run("pods that are not injected are not affected", func(t *testing.T) {
+		inj := newInjector(t, Config{MaxInjectedSidecars: 1}, 1)
+		pod := getPod("myapp")
+		pod.Annotations = nil
+		_, err := patchTestPod(t, inj, pod)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	otlptracegrpc "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	admissionv1 "k8s.io/api/admission/v1"
+
+	"github.com/dapr/dapr/pkg/injector/patcher"
+)
+
+const (
+	tracerName           = "dapr-sidecar-injector"
+	admissionSpanName    = "SidecarInjector/Admission"
+	tracingServiceName   = "dapr-sidecar-injector"
+	spanAttrNamespace    = "k8s.namespace.name"
+	spanAttrAppID        = "dapr.app_id"
+	spanAttrOperation    = "dapr.injector.operation"
+	spanAttrDecision     = "dapr.injector.decision"
+	spanAttrPatchOps     = "dapr.injector.patch_ops"
+	spanAttrDenialReason = "dapr.injector.denial_reason"
+)
+
+// InitTracing registers the global tracer provider, which exports the spans of the injector with OTLP over gRPC.
+// The exporter is configured with the standard OTEL_EXPORTER_OTLP_* environment variables.
+// It returns a function that flushes and shuts down the tracer provider; if tracing is disabled, it's a no-op.
+func InitTracing(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	if !cfg.GetEnableTracing() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptrace.New(ctx, otlptracegrpc.NewClient())
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(tracingServiceName),
+		)),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// newTracer returns the tracer for the admission requests, which is a no-op tracer if tracing is disabled.
+func newTracer(cfg Config) trace.Tracer {
+	if !cfg.GetEnableTracing() {
+		return trace.NewNoopTracerProvider().Tracer(tracerName)
+	}
+	return otel.Tracer(tracerName)
+}
+
+// startAdmissionSpan starts the span for an admission request.
+// If the request contains a W3C trace context, the span is a child of that.
+func (i *injector) startAdmissionSpan(r *http.Request) (context.Context, trace.Span) {
+	ctx := propagation.TraceContext{}.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return i.tracer.Start(ctx, admissionSpanName, trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// endAdmissionSpan records the outcome of the admission request on the span, then ends it.
+func endAdmissionSpan(span trace.Span, ar *admissionv1.AdmissionReview, appID string, patchOps jsonpatch.Patch, err error) {
+	defer span.End()
+	if !span.IsRecording() {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.Int(spanAttrPatchOps, len(patchOps)),
+	}
+	if ar.Request != nil {
+		attrs = append(attrs,
+			attribute.String(spanAttrNamespace, ar.Request.Namespace),
+			attribute.String(spanAttrOperation, string(ar.Request.Operation)),
+		)
+	}
+	if appID != "" {
+		attrs = append(attrs, attribute.String(spanAttrAppID, appID))
+	}
+
+	switch {
+	case err != nil:
+		attrs = append(attrs,
+			attribute.String(spanAttrDecision, auditDecisionDeny),
+			attribute.String(spanAttrDenialReason, string(patcher.GetDenialReason(err))),
+		)
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	case len(patchOps) == 0:
+		attrs = append(attrs, attribute.String(spanAttrDecision, auditDecisionSkip))
+	default:
+		attrs = append(attrs, attribute.String(spanAttrDecision, auditDecisionInject))
+	}
+	span.SetAttributes(attrs...)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+func TestAdmissionTracing(t *testing.T) {
+	newInjector := func(t *testing.T) (*injector, *tracetest.SpanRecorder) {
+		inj := newTestInjector(t, Config{EnableTracing: "true"})
+		recorder := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+		inj.tracer = tp.Tracer(tracerName)
+		return inj, recorder
+	}
+
+	sendRequest := func(t *testing.T, inj *injector, annotations map[string]string, header http.Header) {
+		t.Helper()
+
+		podBytes, err := json.Marshal(corev1.Pod{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "Pod",
+				APIVersion: "v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-app",
+				Namespace:   "default",
+				Annotations: annotations,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "main", Image: "docker.io/app:latest"},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		requestBytes, err := json.Marshal(admissionv1.AdmissionReview{
+			Request: &admissionv1.AdmissionRequest{
+				UID:       uuid.NewUUID(),
+				Kind:      metav1.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"},
+				Name:      "test-app",
+				Namespace: "default",
+				Operation: admissionv1.Create,
+				UserInfo: authenticationv1.UserInfo{
+					Groups: []string{systemGroup},
+				},
+				Object: runtime.RawExtension{Raw: podBytes},
+			},
+		})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader(requestBytes))
+		req.Header.Set("Content-Type", runtime.ContentTypeJSON)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		inj.handleRequest(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	getAttributes := func(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		res := map[attribute.Key]attribute.Value{}
+		for _, kv := range span.Attributes() {
+			res[kv.Key] = kv.Value
+		}
+		return res
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		inj := newTestInjector(t, Config{})
+		_, span := inj.tracer.Start(context.Background(), "test")
+		assert.False(t, span.IsRecording())
+	})
+
+	t.Run("span for injected pod", func(t *testing.T) {
+		inj, recorder := newInjector(t)
+		sendRequest(t, inj, map[string]string{
+			"dapr.io/enabled": "true",
+			"dapr.io/app-id":  "test-app",
+		}, nil)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, admissionSpanName, spans[0].Name())
+		assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+		assert.False(t, spans[0].Parent().IsValid())
+
+		attrs := getAttributes(spans[0])
+		assert.Equal(t, auditDecisionInject, attrs[spanAttrDecision].AsString())
+		assert.Equal(t, "test-app", attrs[spanAttrAppID].AsString())
+		assert.Equal(t, "default", attrs[spanAttrNamespace].AsString())
+		assert.Equal(t, "CREATE", attrs[spanAttrOperation].AsString())
+		assert.Greater(t, attrs[spanAttrPatchOps].AsInt64(), int64(0))
+	})
+
+	t.Run("span for skipped pod", func(t *testing.T) {
+		inj, recorder := newInjector(t)
+		sendRequest(t, inj, nil, nil)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, auditDecisionSkip, getAttributes(spans[0])[spanAttrDecision].AsString())
+	})
+
+	t.Run("span for denied pod", func(t *testing.T) {
+		inj, recorder := newInjector(t)
+		sendRequest(t, inj, map[string]string{
+			"dapr.io/enabled": "true",
+			"dapr.io/app-id":  "my_app",
+		}, nil)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, otelcodes.Error, spans[0].Status().Code)
+		attrs := getAttributes(spans[0])
+		assert.Equal(t, auditDecisionDeny, attrs[spanAttrDecision].AsString())
+		assert.Equal(t, "InvalidAppID", attrs[spanAttrDenialReason].AsString())
+	})
+
+	t.Run("propagates the incoming trace context", func(t *testing.T) {
+		inj, recorder := newInjector(t)
+		sendRequest(t, inj, map[string]string{
+			"dapr.io/enabled": "true",
+			"dapr.io/app-id":  "test-app",
+		}, http.Header{
+			"Traceparent": []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		})
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+		assert.True(t, spans[0].Parent().IsRemote())
+		assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	})
+}
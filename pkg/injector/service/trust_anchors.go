@@ -0,0 +1,48 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/dapr/dapr/pkg/injector/patcher"
+)
+
+const trustAnchorsLookupTimeout = 5 * time.Second
+
+// trustAnchorsSourceExists returns true if the ConfigMap or Secret with the trust anchors exists in the namespace.
+// The lookup is bound to the context of the admission request.
+func (i *injector) trustAnchorsSourceExists(ctx context.Context, source patcher.TrustAnchorsSource, namespace string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, trustAnchorsLookupTimeout)
+	defer cancel()
+
+	var err error
+	switch source.Kind {
+	case patcher.TrustAnchorsSourceSecret:
+		_, err = i.kubeClient.CoreV1().Secrets(namespace).Get(ctx, source.Name, metav1.GetOptions{})
+	default:
+		_, err = i.kubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, source.Name, metav1.GetOptions{})
+	}
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("error when fetching trust anchors source '%s': %w", source, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2023 The Dapr Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/dapr/dapr/pkg/injector/patcher"
+)
+
+func TestTrustAnchorsSourceExists(t *testing.T) {
+	i := &injector{
+		kubeClient: kubernetesfake.NewSimpleClientset(
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-ca", Namespace: "myns"}},
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret-ca", Namespace: "myns"}},
+		),
+	}
+
+	testCases := []struct {
+		name      string
+		source    patcher.TrustAnchorsSource
+		namespace string
+		expect    bool
+	}{
+		{"existing ConfigMap", patcher.TrustAnchorsSource{Kind: patcher.TrustAnchorsSourceConfigMap, Name: "cm-ca"}, "myns", true},
+		{"existing Secret", patcher.TrustAnchorsSource{Kind: patcher.TrustAnchorsSourceSecret, Name: "secret-ca"}, "myns", true},
+		{"ConfigMap in another namespace", patcher.TrustAnchorsSource{Kind: patcher.TrustAnchorsSourceConfigMap, Name: "cm-ca"}, "otherns", false},
+		{"wrong kind", patcher.TrustAnchorsSource{Kind: patcher.TrustAnchorsSourceSecret, Name: "cm-ca"}, "myns", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exists, err := i.trustAnchorsSourceExists(context.Background(), tc.source, tc.namespace)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, exists)
+		})
+	}
+}